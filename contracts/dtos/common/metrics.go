@@ -7,13 +7,27 @@
 package common
 
 type Metrics struct {
-	MemAlloc       uint64 `json:"memAlloc"`
-	MemFrees       uint64 `json:"memFrees"`
-	MemLiveObjects uint64 `json:"memLiveObjects"`
-	MemMallocs     uint64 `json:"memMallocs"`
-	MemSys         uint64 `json:"memSys"`
-	MemTotalAlloc  uint64 `json:"memTotalAlloc"`
-	CpuBusyAvg     uint8  `json:"cpuBusyAvg"`
+	MemAlloc       uint64     `json:"memAlloc"`
+	MemFrees       uint64     `json:"memFrees"`
+	MemLiveObjects uint64     `json:"memLiveObjects"`
+	MemMallocs     uint64     `json:"memMallocs"`
+	MemSys         uint64     `json:"memSys"`
+	MemTotalAlloc  uint64     `json:"memTotalAlloc"`
+	CpuBusyAvg     uint8      `json:"cpuBusyAvg"`
+	CacheDrift     CacheDrift `json:"cacheDrift"`
+	// Degraded is set while the service runs from the cache snapshot because metadata is unavailable
+	Degraded bool `json:"degraded"`
+	// UnresolvedProfiles are the profiles whose base or included profiles can't be resolved
+	UnresolvedProfiles []string `json:"unresolvedProfiles,omitempty"`
+}
+
+// CacheDrift counts the devices, profiles and provision watchers of the device service cache
+// which differed from core metadata and were corrected by the reconciliation.
+type CacheDrift struct {
+	Devices           uint64 `json:"devices"`
+	Profiles          uint64 `json:"profiles"`
+	ProvisionWatchers uint64 `json:"provisionWatchers"`
+	LastReconciled    int64  `json:"lastReconciled"`
 }
 
 // MetricsResponse defines the providing memory and cpu utilization stats of the service.
@@ -0,0 +1,23 @@
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package dtos
+
+// DiscoveredDevice is a device as reported by a protocol discovery, before it's matched
+// against the ProvisionWatchers.
+type DiscoveredDevice struct {
+	Name        string                        `json:"name" validate:"required"`
+	Protocols   map[string]ProtocolProperties `json:"protocols" validate:"required,gt=0"`
+	Description string                        `json:"description,omitempty"`
+	Labels      []string                      `json:"labels,omitempty"`
+}
+
+// ProvisionWatcherEvaluation explains whether a DiscoveredDevice is matched by a ProvisionWatcher.
+type ProvisionWatcherEvaluation struct {
+	ProvisionWatcher string `json:"provisionWatcher"`
+	Matched          bool   `json:"matched"`
+	// Reasons the device isn't matched
+	Reasons []string `json:"reasons,omitempty"`
+}
@@ -0,0 +1,56 @@
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package dtos
+
+// Statuses of a DiscoveryJob
+const (
+	DiscoveryJobRunning   = "running"
+	DiscoveryJobCompleted = "completed"
+	DiscoveryJobFailed    = "failed"
+	DiscoveryJobCancelled = "cancelled"
+)
+
+// Decisions about a device found by a DiscoveryJob
+const (
+	// DiscoveryDecisionAccepted indicates the device was added by a ProvisionWatcher
+	DiscoveryDecisionAccepted = "accepted"
+	// DiscoveryDecisionRejected indicates no ProvisionWatcher accepted the device
+	DiscoveryDecisionRejected = "rejected"
+	// DiscoveryDecisionExisting indicates the device already exists
+	DiscoveryDecisionExisting = "existing"
+	// DiscoveryDecisionFailed indicates the device was accepted but couldn't be added
+	DiscoveryDecisionFailed = "failed"
+)
+
+// DiscoveryJob is a device discovery run, triggered by the API or by the auto discovery.
+type DiscoveryJob struct {
+	Id string `json:"id"`
+	// Status is running, completed, failed or cancelled
+	Status string `json:"status"`
+	// ProvisionWatcher is the watcher whose scope is discovered, empty for a full discovery
+	ProvisionWatcher string `json:"provisionWatcher,omitempty"`
+	// Progress is the percentage reported by the discovery, 100 once it's completed
+	Progress int    `json:"progress"`
+	Error    string `json:"error,omitempty"`
+	Start    int64  `json:"start"`
+	End      int64  `json:"end,omitempty"`
+	// Devices are the devices found so far with the decision about each
+	Devices []DiscoveredDeviceDecision `json:"devices"`
+}
+
+// DiscoveredDeviceDecision is the decision about a device found by a DiscoveryJob.
+type DiscoveredDeviceDecision struct {
+	Name      string                        `json:"name"`
+	Protocols map[string]ProtocolProperties `json:"protocols,omitempty"`
+	// Decision is accepted, rejected, existing or failed
+	Decision string `json:"decision"`
+	// DeviceName is the name of the added or existing device
+	DeviceName       string `json:"deviceName,omitempty"`
+	ProvisionWatcher string `json:"provisionWatcher,omitempty"`
+	Reason           string `json:"reason,omitempty"`
+	// Attempts is the number of attempts to add the accepted device
+	Attempts int `json:"attempts,omitempty"`
+}
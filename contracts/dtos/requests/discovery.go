@@ -0,0 +1,45 @@
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package requests
+
+import (
+	"encoding/json"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+)
+
+// DiscoveryDryRunRequest defines the Request Content for POST discovery dry run DTO.
+type DiscoveryDryRunRequest struct {
+	common.BaseRequest `json:",inline"`
+	Device             dtos.DiscoveredDevice `json:"device"`
+}
+
+// Validate satisfies the Validator interface
+func (d DiscoveryDryRunRequest) Validate() error {
+	return contracts.Validate(d)
+}
+
+// UnmarshalJSON implements the Unmarshaler interface for the DiscoveryDryRunRequest type
+func (d *DiscoveryDryRunRequest) UnmarshalJSON(b []byte) error {
+	var alias struct {
+		common.BaseRequest
+		Device dtos.DiscoveredDevice
+	}
+	if err := json.Unmarshal(b, &alias); err != nil {
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, "Failed to unmarshal request body as JSON.", err)
+	}
+
+	*d = DiscoveryDryRunRequest(alias)
+
+	// validate DiscoveryDryRunRequest DTO
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package requests
+
+import (
+	"encoding/json"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+)
+
+// TransformDataRequest defines the Request Content for POST transform debug DTO.
+// The DeviceResource is either the one of an existing device, given by DeviceName and
+// ResourceName, or described by Properties and Attributes. Properties, Attributes and
+// Mappings given along with a device override those of its profile.
+type TransformDataRequest struct {
+	common.BaseRequest `json:",inline"`
+	// Value is the raw value, it takes precedence over the route variable for values
+	// which cannot be carried by a path segment, e.g. JSON documents
+	Value string `json:"value,omitempty"`
+	// ValueType is the type of the raw value, default is the type of the DeviceResource
+	ValueType    string              `json:"valueType,omitempty"`
+	DeviceName   string              `json:"deviceName,omitempty"`
+	ResourceName string              `json:"resourceName,omitempty"`
+	Properties   *dtos.PropertyValue `json:"properties,omitempty"`
+	Attributes   map[string]string   `json:"attributes,omitempty"`
+	Mappings     map[string]string   `json:"mappings,omitempty"`
+}
+
+// Validate satisfies the Validator interface
+func (t TransformDataRequest) Validate() error {
+	if t.DeviceName == "" && t.Properties == nil {
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, "either deviceName and resourceName or properties must be specified", nil)
+	}
+	if t.DeviceName != "" && t.ResourceName == "" {
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, "resourceName must be specified along with deviceName", nil)
+	}
+	return contracts.Validate(t)
+}
+
+// UnmarshalJSON implements the Unmarshaler interface for the TransformDataRequest type
+func (t *TransformDataRequest) UnmarshalJSON(b []byte) error {
+	var alias struct {
+		common.BaseRequest
+		Value        string
+		ValueType    string
+		DeviceName   string
+		ResourceName string
+		Properties   *dtos.PropertyValue
+		Attributes   map[string]string
+		Mappings     map[string]string
+	}
+	if err := json.Unmarshal(b, &alias); err != nil {
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, "Failed to unmarshal request body as JSON.", err)
+	}
+
+	*t = TransformDataRequest(alias)
+
+	// validate TransformDataRequest DTO
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	if t.ValueType != "" {
+		valueType, err := contracts.NormalizeValueType(t.ValueType)
+		if err != nil {
+			return errors.NewCommonEdgeXWrapper(err)
+		}
+		t.ValueType = valueType
+	}
+	if t.Properties != nil {
+		valueType, err := contracts.NormalizeValueType(t.Properties.Type)
+		if err != nil {
+			return errors.NewCommonEdgeXWrapper(err)
+		}
+		t.Properties.Type = valueType
+	}
+	return nil
+}
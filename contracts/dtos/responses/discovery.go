@@ -0,0 +1,55 @@
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package responses
+
+import (
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/common"
+)
+
+// DiscoveryJobResponse defines the Response Content for POST discovery and GET discovery job DTOs.
+type DiscoveryJobResponse struct {
+	common.BaseResponse `json:",inline"`
+	Job                 dtos.DiscoveryJob `json:"job"`
+}
+
+func NewDiscoveryJobResponse(requestId string, message string, statusCode int, job dtos.DiscoveryJob) DiscoveryJobResponse {
+	return DiscoveryJobResponse{
+		BaseResponse: common.NewBaseResponse(requestId, message, statusCode),
+		Job:          job,
+	}
+}
+
+// MultiDiscoveryJobsResponse defines the Response Content for GET multiple discovery job DTOs.
+type MultiDiscoveryJobsResponse struct {
+	common.BaseResponse `json:",inline"`
+	Jobs                []dtos.DiscoveryJob `json:"jobs"`
+}
+
+func NewMultiDiscoveryJobsResponse(requestId string, message string, statusCode int, jobs []dtos.DiscoveryJob) MultiDiscoveryJobsResponse {
+	return MultiDiscoveryJobsResponse{
+		BaseResponse: common.NewBaseResponse(requestId, message, statusCode),
+		Jobs:         jobs,
+	}
+}
+
+// DiscoveryDryRunResponse defines the Response Content for POST discovery dry run DTOs.
+// ProvisionWatcher is the watcher which would add the device, empty if it would be rejected.
+type DiscoveryDryRunResponse struct {
+	common.BaseResponse `json:",inline"`
+	Decision            string                            `json:"decision"`
+	ProvisionWatcher    string                            `json:"provisionWatcher,omitempty"`
+	Evaluations         []dtos.ProvisionWatcherEvaluation `json:"evaluations"`
+}
+
+func NewDiscoveryDryRunResponse(requestId string, message string, statusCode int, decision string, provisionWatcher string, evaluations []dtos.ProvisionWatcherEvaluation) DiscoveryDryRunResponse {
+	return DiscoveryDryRunResponse{
+		BaseResponse:     common.NewBaseResponse(requestId, message, statusCode),
+		Decision:         decision,
+		ProvisionWatcher: provisionWatcher,
+		Evaluations:      evaluations,
+	}
+}
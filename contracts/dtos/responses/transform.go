@@ -0,0 +1,35 @@
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package responses
+
+import (
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/common"
+)
+
+// TransformDataResponse defines the Response Content for POST transform debug DTO.
+type TransformDataResponse struct {
+	common.BaseResponse `json:",inline"`
+	Reading             dtos.BaseReading `json:"reading"`
+	// Mapped indicates whether a mapping rule matched the transformed value
+	Mapped    bool             `json:"mapped"`
+	Assertion *AssertionResult `json:"assertion,omitempty"`
+}
+
+// AssertionResult is the result of the assertion of the DeviceResource on the transformed value
+type AssertionResult struct {
+	Assertion string `json:"assertion"`
+	Satisfied bool   `json:"satisfied"`
+}
+
+func NewTransformDataResponse(requestId string, message string, statusCode int, reading dtos.BaseReading, mapped bool, assertion *AssertionResult) TransformDataResponse {
+	return TransformDataResponse{
+		BaseResponse: common.NewBaseResponse(requestId, message, statusCode),
+		Reading:      reading,
+		Mapped:       mapped,
+		Assertion:    assertion,
+	}
+}
@@ -1,6 +1,6 @@
 // -*- Mode: Go; indent-tabs-mode: t -*-
 //
-// Copyright (C) 2020 IOTech Ltd
+// Copyright (C) 2020-2021 IOTech Ltd
 //
 // SPDX-License-Identifier: Apache-2.0
 
@@ -9,6 +9,8 @@ package autodiscovery
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,9 +18,37 @@ import (
 	"github.com/edgexfoundry/go-mod-bootstrap/v2/bootstrap/startup"
 	"github.com/edgexfoundry/go-mod-bootstrap/v2/di"
 
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
+	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
 )
 
+// refreshInterval bounds the wait for the next discovery, so the changes of the schedules of
+// the provision watchers are picked up
+const refreshInterval = time.Minute
+
+// scheduledDiscovery is the global discovery schedule or the schedule of a provision watcher.
+type scheduledDiscovery struct {
+	spec     string
+	schedule Schedule // nil if the spec is invalid
+	next     time.Time
+	scope    dsModels.DiscoveryScope
+	locked   bool
+}
+
+type scheduler struct {
+	discovery dsModels.ProtocolDiscovery
+	// scoped is nil if the driver doesn't implement ScopedDiscovery, the provision watcher
+	// schedules then run full discoveries
+	scoped   dsModels.ScopedDiscovery
+	global   *scheduledDiscovery
+	watchers map[string]*scheduledDiscovery // key is ProvisionWatcher name
+	lc       logger.LoggingClient
+}
+
 func BootstrapHandler(
 	ctx context.Context,
 	wg *sync.WaitGroup,
@@ -27,39 +57,188 @@ func BootstrapHandler(
 	discovery := container.ProtocolDiscoveryFrom(dic.Get)
 	lc := bootstrapContainer.LoggingClientFrom(dic.Get)
 	configuration := container.ConfigurationFrom(dic.Get)
-	var runDiscovery bool = true
 
 	if configuration.Device.Discovery.Enabled == false {
 		lc.Info("AutoDiscovery stopped: disabled by configuration")
-		runDiscovery = false
-	}
-	duration, err := time.ParseDuration(configuration.Device.Discovery.Interval)
-	if err != nil || duration <= 0 {
-		lc.Info("AutoDiscovery stopped: interval error in configuration")
-		runDiscovery = false
+		return true
 	}
 	if discovery == nil {
 		lc.Info("AutoDiscovery stopped: ProtocolDiscovery not implemented")
-		runDiscovery = false
+		return true
 	}
 
-	if runDiscovery {
-		go func() {
-			wg.Add(1)
-			defer wg.Done()
-
-			lc.Info(fmt.Sprintf("Starting auto-discovery with duration %v", duration))
-			DiscoveryWrapper(discovery, lc)
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case <-time.After(duration):
-					DiscoveryWrapper(discovery, lc)
-				}
-			}
-		}()
+	s := &scheduler{
+		discovery: discovery,
+		watchers:  make(map[string]*scheduledDiscovery),
+		lc:        lc,
 	}
+	s.scoped, _ = container.ProtocolDriverFrom(dic.Get).(dsModels.ScopedDiscovery)
+	s.global = globalSchedule(configuration.Device.Discovery, lc)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.run(ctx, dic)
+	}()
 
 	return true
 }
+
+// globalSchedule returns the schedule of Discovery.Schedule, or of Discovery.Interval which
+// also discovers right away, nil if neither is valid.
+func globalSchedule(config common.DiscoveryInfo, lc logger.LoggingClient) *scheduledDiscovery {
+	now := time.Now()
+	if config.Schedule != "" {
+		schedule, err := ParseSchedule(config.Schedule)
+		if err != nil {
+			lc.Error(fmt.Sprintf("AutoDiscovery schedule error in configuration: %v", err))
+			return nil
+		}
+		lc.Info(fmt.Sprintf("Starting auto-discovery with schedule %s", config.Schedule))
+		return &scheduledDiscovery{spec: config.Schedule, schedule: schedule, next: schedule.Next(now)}
+	}
+
+	duration, err := time.ParseDuration(config.Interval)
+	if err != nil || duration <= 0 {
+		lc.Info("AutoDiscovery interval error in configuration, only the provision watcher schedules are run")
+		return nil
+	}
+	lc.Info(fmt.Sprintf("Starting auto-discovery with duration %v", duration))
+	return &scheduledDiscovery{spec: everyPrefix + config.Interval, schedule: everySchedule(duration), next: now}
+}
+
+func (s *scheduler) run(ctx context.Context, dic *di.Container) {
+	for {
+		now := time.Now()
+		s.refreshWatchers(now)
+
+		wait := refreshInterval
+		if next := s.earliest(); !next.IsZero() && next.Sub(now) < wait {
+			wait = next.Sub(now)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		s.runDue(time.Now(), dic)
+	}
+}
+
+// refreshWatchers updates the schedules of the provision watchers from the cache.
+func (s *scheduler) refreshWatchers(now time.Time) {
+	seen := make(map[string]bool)
+	for _, pw := range cache.ProvisionWatchers().All() {
+		spec, hints, ok := watcherSchedule(pw)
+		if !ok {
+			continue
+		}
+		seen[pw.Name] = true
+
+		entry, exists := s.watchers[pw.Name]
+		if !exists || entry.spec != spec {
+			entry = &scheduledDiscovery{spec: spec}
+			if schedule, err := ParseSchedule(spec); err != nil {
+				s.lc.Error(fmt.Sprintf("invalid discovery schedule of provision watcher %s: %v", pw.Name, err))
+			} else {
+				entry.schedule = schedule
+				entry.next = schedule.Next(now)
+				s.lc.Info(fmt.Sprintf("Scheduling the discovery of provision watcher %s with schedule %s", pw.Name, spec))
+			}
+			s.watchers[pw.Name] = entry
+		}
+		entry.scope = dsModels.DiscoveryScope{ProvisionWatcher: pw.Name, Hints: hints}
+		entry.locked = pw.AdminState == models.Locked
+	}
+	for name := range s.watchers {
+		if !seen[name] {
+			delete(s.watchers, name)
+		}
+	}
+}
+
+// earliest returns the time of the next scheduled discovery, the zero time if there's none.
+func (s *scheduler) earliest() time.Time {
+	var earliest time.Time
+	for _, entry := range s.entries() {
+		if !entry.next.IsZero() && (earliest.IsZero() || entry.next.Before(earliest)) {
+			earliest = entry.next
+		}
+	}
+	return earliest
+}
+
+// runDue runs the discoveries which are due, the global one first then the ones of the
+// provision watchers in name order. A full discovery is only run once.
+func (s *scheduler) runDue(now time.Time, dic *di.Container) {
+	locked := container.DeviceServiceFrom(dic.Get).AdminState == models.Locked
+	ranFull := false
+	if s.global != nil && s.due(s.global, now) {
+		if locked {
+			s.lc.Debug("AutoDiscovery skipped: device service locked")
+		} else {
+			DiscoveryWrapper(s.discovery, s.lc)
+			ranFull = true
+		}
+		s.global.next = s.global.schedule.Next(time.Now())
+	}
+
+	names := make([]string, 0, len(s.watchers))
+	for name := range s.watchers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		entry := s.watchers[name]
+		if !s.due(entry, now) {
+			continue
+		}
+		switch {
+		case locked:
+			s.lc.Debug(fmt.Sprintf("AutoDiscovery of provision watcher %s skipped: device service locked", name))
+		case entry.locked:
+			s.lc.Debug(fmt.Sprintf("AutoDiscovery of provision watcher %s skipped: provision watcher locked", name))
+		case s.scoped != nil:
+			ScopedDiscoveryWrapper(s.scoped, entry.scope, s.lc)
+		case !ranFull:
+			s.lc.Debug(fmt.Sprintf("ScopedDiscovery not implemented, running a full discovery for provision watcher %s", name))
+			DiscoveryWrapper(s.discovery, s.lc)
+			ranFull = true
+		}
+		entry.next = entry.schedule.Next(time.Now())
+	}
+}
+
+func (s *scheduler) due(entry *scheduledDiscovery, now time.Time) bool {
+	return entry.schedule != nil && !entry.next.IsZero() && !entry.next.After(now)
+}
+
+func (s *scheduler) entries() []*scheduledDiscovery {
+	entries := make([]*scheduledDiscovery, 0, len(s.watchers)+1)
+	if s.global != nil {
+		entries = append(entries, s.global)
+	}
+	for _, entry := range s.watchers {
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// watcherSchedule returns the discovery schedule and the scoping hints of the provision
+// watcher, false if it has no schedule.
+func watcherSchedule(pw models.ProvisionWatcher) (string, map[string]string, bool) {
+	spec := ""
+	hints := make(map[string]string)
+	for _, label := range pw.Labels {
+		switch {
+		case strings.HasPrefix(label, common.WatcherLabelDiscoverySchedule):
+			spec = strings.TrimSpace(strings.TrimPrefix(label, common.WatcherLabelDiscoverySchedule))
+		case strings.HasPrefix(label, common.WatcherLabelDiscoveryScope):
+			hint := strings.TrimPrefix(label, common.WatcherLabelDiscoveryScope)
+			if i := strings.Index(hint, "="); i > 0 {
+				hints[hint[:i]] = hint[i+1:]
+			}
+		}
+	}
+	return spec, hints, spec != ""
+}
@@ -1,41 +1,284 @@
 // -*- Mode: Go; indent-tabs-mode: t -*-
 //
-// Copyright (C) 2020 IOTech Ltd
+// Copyright (C) 2020-2021 IOTech Ltd
 //
 // SPDX-License-Identifier: Apache-2.0
 
 package autodiscovery
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/google/uuid"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
 	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
 	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
 )
 
-type discoveryLocker struct {
-	busy bool
-	mux  sync.Mutex
+// maxJobs is the number of discovery jobs kept, the oldest ones are dropped
+const maxJobs = 20
+
+type job struct {
+	dto       dtos.DiscoveryJob
+	cancel    context.CancelFunc
+	cancelled bool
+	held      int // the batches of discovered devices being processed
 }
 
-var locker discoveryLocker
+type jobList struct {
+	jobs     []*job // oldest first
+	running  *job
+	finished func(dtos.DiscoveryJob)
+	mux      sync.Mutex
+}
+
+var jobs jobList
+
+// released signals the jobs waiting for their discovered devices to be processed
+var released = sync.NewCond(&jobs.mux)
+
+// ContextDiscovery adapts a dsModels.ContextDiscovery to the ProtocolDiscovery interface, the
+// discovery jobs run it with their context and progress.
+type ContextDiscovery struct {
+	dsModels.ContextDiscovery
+}
+
+// Discover runs the discovery without cancellation.
+func (d ContextDiscovery) Discover() {
+	_ = d.ContextDiscovery.Discover(context.Background(), func(int) {})
+}
 
+// scopedDiscovery runs a ScopedDiscovery within the scope of a ProvisionWatcher.
+type scopedDiscovery struct {
+	dsModels.ScopedDiscovery
+	scope dsModels.DiscoveryScope
+}
+
+func (d scopedDiscovery) Discover(ctx context.Context, progress func(percent int)) error {
+	return d.DiscoverScope(ctx, d.scope, progress)
+}
+
+// DiscoveryWrapper runs a discovery job and waits for it, unless another one is running.
 func DiscoveryWrapper(discovery dsModels.ProtocolDiscovery, lc logger.LoggingClient) {
-	locker.mux.Lock()
-	if locker.busy {
+	ctx, j, err := newJob("")
+	if err != nil {
 		lc.Info("another device discovery process is currently running")
-		locker.mux.Unlock()
 		return
 	}
-	locker.busy = true
-	locker.mux.Unlock()
+	runJob(ctx, j, discovery, lc)
+}
+
+// ScopedDiscoveryWrapper runs a discovery job within the scope of a ProvisionWatcher and waits
+// for it, unless another one is running.
+func ScopedDiscoveryWrapper(discovery dsModels.ScopedDiscovery, scope dsModels.DiscoveryScope, lc logger.LoggingClient) {
+	ctx, j, err := newJob(scope.ProvisionWatcher)
+	if err != nil {
+		lc.Info(fmt.Sprintf("another device discovery process is currently running, skipping the discovery of provision watcher %s", scope.ProvisionWatcher))
+		return
+	}
+	runJob(ctx, j, ContextDiscovery{scopedDiscovery{ScopedDiscovery: discovery, scope: scope}}, lc)
+}
+
+// StartJob starts a discovery job in the background, unless another one is running.
+func StartJob(discovery dsModels.ProtocolDiscovery, lc logger.LoggingClient) (dtos.DiscoveryJob, errors.EdgeX) {
+	ctx, j, err := newJob("")
+	if err != nil {
+		return dtos.DiscoveryJob{}, err
+	}
+	go runJob(ctx, j, discovery, lc)
+
+	jobs.mux.Lock()
+	defer jobs.mux.Unlock()
+	return copyJob(j), nil
+}
+
+func newJob(provisionWatcher string) (context.Context, *job, errors.EdgeX) {
+	jobs.mux.Lock()
+	defer jobs.mux.Unlock()
+
+	if jobs.running != nil {
+		errMsg := fmt.Sprintf("discovery job %s is running", jobs.running.dto.Id)
+		return nil, nil, errors.NewCommonEdgeX(errors.KindStatusConflict, errMsg, nil)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		dto: dtos.DiscoveryJob{
+			Id:               uuid.New().String(),
+			Status:           dtos.DiscoveryJobRunning,
+			ProvisionWatcher: provisionWatcher,
+			Start:            time.Now().UnixNano() / 1e6,
+			Devices:          []dtos.DiscoveredDeviceDecision{},
+		},
+		cancel: cancel,
+	}
+	jobs.jobs = append(jobs.jobs, j)
+	if len(jobs.jobs) > maxJobs {
+		jobs.jobs = jobs.jobs[len(jobs.jobs)-maxJobs:]
+	}
+	jobs.running = j
+	return ctx, j, nil
+}
+
+func runJob(ctx context.Context, j *job, discovery dsModels.ProtocolDiscovery, lc logger.LoggingClient) {
+	lc.Debug(fmt.Sprintf("protocol discovery job %s triggered", j.dto.Id))
+	var err error
+	if cd, ok := discovery.(ContextDiscovery); ok {
+		err = cd.ContextDiscovery.Discover(ctx, func(percent int) {
+			jobs.mux.Lock()
+			defer jobs.mux.Unlock()
+			if percent >= 0 && percent <= 100 {
+				j.dto.Progress = percent
+			}
+		})
+	} else {
+		discovery.Discover()
+	}
+
+	jobs.mux.Lock()
+	// the job ends once the devices it discovered are processed
+	for j.held > 0 {
+		released.Wait()
+	}
+	j.cancel()
+	j.dto.End = time.Now().UnixNano() / 1e6
+	switch {
+	case j.cancelled:
+		j.dto.Status = dtos.DiscoveryJobCancelled
+	case err != nil:
+		j.dto.Status = dtos.DiscoveryJobFailed
+		j.dto.Error = err.Error()
+	default:
+		j.dto.Status = dtos.DiscoveryJobCompleted
+		j.dto.Progress = 100
+	}
+	jobs.running = nil
+	finished, result := jobs.finished, copyJob(j)
+	jobs.mux.Unlock()
 
-	lc.Debug(fmt.Sprintf("protocol discovery triggered"))
-	discovery.Discover()
+	lc.Debug(fmt.Sprintf("protocol discovery job %s %s", result.Id, result.Status))
+	if finished != nil {
+		finished(result)
+	}
+}
+
+// OnJobFinished registers the function called with each discovery job once it's finished.
+func OnJobFinished(f func(dtos.DiscoveryJob)) {
+	jobs.mux.Lock()
+	defer jobs.mux.Unlock()
+
+	jobs.finished = f
+}
+
+// Jobs returns the discovery jobs, the latest first.
+func Jobs() []dtos.DiscoveryJob {
+	jobs.mux.Lock()
+	defer jobs.mux.Unlock()
+
+	result := make([]dtos.DiscoveryJob, 0, len(jobs.jobs))
+	for i := len(jobs.jobs) - 1; i >= 0; i-- {
+		result = append(result, copyJob(jobs.jobs[i]))
+	}
+	return result
+}
+
+// Job returns the discovery job with the given id.
+func Job(id string) (dtos.DiscoveryJob, bool) {
+	jobs.mux.Lock()
+	defer jobs.mux.Unlock()
+
+	if j := findJob(id); j != nil {
+		return copyJob(j), true
+	}
+	return dtos.DiscoveryJob{}, false
+}
+
+// CancelJob cancels the running discovery job with the given id, the devices it finds
+// afterwards aren't added. The ProtocolDiscovery implementations which aren't
+// ContextDiscovery implementations keep running until they're finished.
+func CancelJob(id string) errors.EdgeX {
+	jobs.mux.Lock()
+	defer jobs.mux.Unlock()
+
+	j := findJob(id)
+	if j == nil {
+		errMsg := fmt.Sprintf("discovery job %s not found", id)
+		return errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, errMsg, nil)
+	}
+	if j != jobs.running {
+		errMsg := fmt.Sprintf("discovery job %s is %s", id, j.dto.Status)
+		return errors.NewCommonEdgeX(errors.KindStatusConflict, errMsg, nil)
+	}
+	j.cancelled = true
+	j.cancel()
+	return nil
+}
+
+// HoldLatestJob returns the latest discovery job, the discovered devices are written
+// asynchronously by the ProtocolDiscovery implementations and belong to it. A running job is
+// held running until release is called, so that it ends once its devices are processed.
+func HoldLatestJob() (dtos.DiscoveryJob, func(), bool) {
+	jobs.mux.Lock()
+	defer jobs.mux.Unlock()
+
+	if len(jobs.jobs) == 0 {
+		return dtos.DiscoveryJob{}, func() {}, false
+	}
+	latest := jobs.jobs[len(jobs.jobs)-1]
+	if latest != jobs.running {
+		return copyJob(latest), func() {}, true
+	}
+	latest.held++
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			jobs.mux.Lock()
+			defer jobs.mux.Unlock()
+			latest.held--
+			released.Broadcast()
+		})
+	}
+	return copyJob(latest), release, true
+}
+
+// AcceptingResults returns false if the discovery job with the given id was cancelled, the
+// devices it discovered are then dropped.
+func AcceptingResults(id string) bool {
+	jobs.mux.Lock()
+	defer jobs.mux.Unlock()
+
+	j := findJob(id)
+	return j == nil || !j.cancelled
+}
+
+// RecordDecisions records the decisions about the discovered devices in the discovery job
+// with the given id.
+func RecordDecisions(id string, decisions ...dtos.DiscoveredDeviceDecision) {
+	jobs.mux.Lock()
+	defer jobs.mux.Unlock()
+
+	if j := findJob(id); j != nil {
+		j.dto.Devices = append(j.dto.Devices, decisions...)
+	}
+}
+
+func findJob(id string) *job {
+	for _, j := range jobs.jobs {
+		if j.dto.Id == id {
+			return j
+		}
+	}
+	return nil
+}
 
-	// ReleaseLock
-	locker.mux.Lock()
-	locker.busy = false
-	locker.mux.Unlock()
+func copyJob(j *job) dtos.DiscoveryJob {
+	result := j.dto
+	result.Devices = make([]dtos.DiscoveredDeviceDecision, len(j.dto.Devices))
+	copy(result.Devices, j.dto.Devices)
+	return result
 }
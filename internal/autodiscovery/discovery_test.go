@@ -0,0 +1,159 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package autodiscovery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+type blockingDiscovery struct {
+	started chan struct{}
+}
+
+func (d blockingDiscovery) Discover(ctx context.Context, progress func(percent int)) error {
+	progress(50)
+	close(d.started)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func waitForJob(t *testing.T, id string, status string) dtos.DiscoveryJob {
+	for i := 0; i < 100; i++ {
+		job, ok := Job(id)
+		require.True(t, ok)
+		if job.Status == status {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.FailNow(t, "discovery job not "+status)
+	return dtos.DiscoveryJob{}
+}
+
+func TestDiscoveryJobs(t *testing.T) {
+	lc := logger.NewMockClient()
+	discovery := ContextDiscovery{blockingDiscovery{started: make(chan struct{})}}
+
+	job, err := StartJob(discovery, lc)
+	require.NoError(t, err)
+	assert.Equal(t, dtos.DiscoveryJobRunning, job.Status)
+	<-discovery.ContextDiscovery.(blockingDiscovery).started
+
+	_, err = StartJob(discovery, lc)
+	if assert.Error(t, err) {
+		assert.Equal(t, errors.KindStatusConflict, errors.Kind(err))
+	}
+	running, ok := Job(job.Id)
+	require.True(t, ok)
+	assert.Equal(t, 50, running.Progress)
+
+	RecordDecisions(job.Id, dtos.DiscoveredDeviceDecision{Name: "device", Decision: dtos.DiscoveryDecisionRejected})
+	require.NoError(t, CancelJob(job.Id))
+	assert.False(t, AcceptingResults(job.Id))
+	cancelled := waitForJob(t, job.Id, dtos.DiscoveryJobCancelled)
+	assert.NotZero(t, cancelled.End)
+	assert.Len(t, cancelled.Devices, 1)
+
+	err = CancelJob(job.Id)
+	if assert.Error(t, err) {
+		assert.Equal(t, errors.KindStatusConflict, errors.Kind(err))
+	}
+	err = CancelJob("missing")
+	if assert.Error(t, err) {
+		assert.Equal(t, errors.KindEntityDoesNotExist, errors.Kind(err))
+	}
+
+	completed := ContextDiscovery{completedDiscovery{}}
+	job, err = StartJob(completed, lc)
+	require.NoError(t, err)
+	assert.True(t, AcceptingResults(job.Id))
+	job = waitForJob(t, job.Id, dtos.DiscoveryJobCompleted)
+	assert.Equal(t, 100, job.Progress)
+	assert.Equal(t, job.Id, Jobs()[0].Id)
+	latest, release, ok := HoldLatestJob()
+	release()
+	require.True(t, ok)
+	assert.Equal(t, job.Id, latest.Id)
+
+	ScopedDiscoveryWrapper(scopedDiscoveryStub{}, dsModels.DiscoveryScope{ProvisionWatcher: "watcher"}, lc)
+	assert.Equal(t, "watcher", Jobs()[0].ProvisionWatcher)
+	latest, release, _ = HoldLatestJob()
+	release()
+	assert.Equal(t, "watcher", latest.ProvisionWatcher)
+}
+
+type scopedDiscoveryStub struct{}
+
+func (scopedDiscoveryStub) DiscoverScope(context.Context, dsModels.DiscoveryScope, func(percent int)) error {
+	return nil
+}
+
+type completedDiscovery struct{}
+
+func (completedDiscovery) Discover(context.Context, func(percent int)) error {
+	return nil
+}
+
+func TestOnJobFinished(t *testing.T) {
+	var finished []dtos.DiscoveryJob
+	OnJobFinished(func(job dtos.DiscoveryJob) {
+		finished = append(finished, job)
+	})
+	defer OnJobFinished(nil)
+
+	DiscoveryWrapper(ContextDiscovery{completedDiscovery{}}, logger.NewMockClient())
+	latest, release, ok := HoldLatestJob()
+	release()
+	require.True(t, ok)
+	require.Len(t, finished, 1)
+	assert.Equal(t, latest.Id, finished[0].Id)
+	assert.Equal(t, dtos.DiscoveryJobCompleted, finished[0].Status)
+}
+
+func TestHoldLatestJob(t *testing.T) {
+	lc := logger.NewMockClient()
+	discovery := ContextDiscovery{blockingDiscovery{started: make(chan struct{})}}
+	job, err := StartJob(discovery, lc)
+	require.NoError(t, err)
+	<-discovery.ContextDiscovery.(blockingDiscovery).started
+
+	held, release, ok := HoldLatestJob()
+	require.True(t, ok)
+	assert.Equal(t, job.Id, held.Id)
+	require.NoError(t, CancelJob(job.Id))
+	time.Sleep(50 * time.Millisecond)
+	running, _ := Job(job.Id)
+	assert.Equal(t, dtos.DiscoveryJobRunning, running.Status, "held until its devices are processed")
+	_, err = StartJob(ContextDiscovery{completedDiscovery{}}, lc)
+	assert.Error(t, err)
+
+	RecordDecisions(held.Id, dtos.DiscoveredDeviceDecision{Name: "device", Decision: dtos.DiscoveryDecisionRejected})
+	release()
+	release()
+	cancelled := waitForJob(t, job.Id, dtos.DiscoveryJobCancelled)
+	assert.Len(t, cancelled.Devices, 1)
+
+	// the decisions about the devices of a finished job aren't recorded in the next one
+	next, err := StartJob(ContextDiscovery{completedDiscovery{}}, lc)
+	require.NoError(t, err)
+	waitForJob(t, next.Id, dtos.DiscoveryJobCompleted)
+	RecordDecisions(held.Id, dtos.DiscoveredDeviceDecision{Name: "late", Decision: dtos.DiscoveryDecisionRejected})
+	next, _ = Job(next.Id)
+	assert.Empty(t, next.Devices)
+	cancelled, _ = Job(job.Id)
+	assert.Len(t, cancelled.Devices, 2)
+}
@@ -0,0 +1,195 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package autodiscovery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+)
+
+// everyPrefix prefixes the schedules given as an interval, e.g. "@every 30s"
+const everyPrefix = "@every "
+
+// descriptors are the predefined schedules
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Schedule returns the time of the next discovery after the given time.
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+type everySchedule time.Duration
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// cronSchedule holds a bit per allowed value of each field of a cron expression.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// the day of month and day of week are either matched, unless one of them is "*"
+	domStar, dowStar bool
+}
+
+type field struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{min: 0, max: 59}
+	hourField   = field{min: 0, max: 23}
+	domField    = field{min: 1, max: 31}
+	monthField  = field{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is also Sunday
+	dowField = field{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// ParseSchedule parses a standard cron expression with the minute, hour, day of month, month
+// and day of week fields, a predefined schedule like "@daily", or an interval like "@every 30s".
+// The cron expressions are evaluated in the local time zone.
+func ParseSchedule(spec string) (Schedule, errors.EdgeX) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, everyPrefix) {
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, everyPrefix)))
+		if err != nil || interval <= 0 {
+			return nil, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("invalid interval in schedule %s", spec), err)
+		}
+		return everySchedule(interval), nil
+	}
+	if expr, ok := descriptors[spec]; ok {
+		spec = expr
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("schedule %s doesn't have 5 fields", spec), nil)
+	}
+	var s cronSchedule
+	var err error
+	for i, target := range []struct {
+		bits *uint64
+		f    field
+	}{{&s.minute, minuteField}, {&s.hour, hourField}, {&s.dom, domField}, {&s.month, monthField}, {&s.dow, dowField}} {
+		if *target.bits, err = target.f.parse(fields[i]); err != nil {
+			return nil, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("invalid schedule %s", spec), err)
+		}
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+// parse parses a comma separated list of "*", values or ranges, each with an optional step.
+func (f field) parse(text string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(text, ",") {
+		rangeText, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %s", part)
+			}
+			rangeText = part[:i]
+		}
+
+		var low, high int
+		switch {
+		case rangeText == "*":
+			low, high = f.min, f.max
+		case strings.Contains(rangeText, "-"):
+			bounds := strings.SplitN(rangeText, "-", 2)
+			var err error
+			if low, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if high, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("range %s is reversed", rangeText)
+			}
+		default:
+			value, err := f.value(rangeText)
+			if err != nil {
+				return 0, err
+			}
+			low, high = value, value
+			if step > 1 {
+				// "a/n" runs from a to the maximum
+				high = f.max
+			}
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(text string) (int, error) {
+	if v, ok := f.names[strings.ToLower(text)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %s out of range %d-%d", text, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next returns the first matching minute after the given time, the zero time if there's
+// none within 5 years, e.g. for February 30th.
+func (s cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,76 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package autodiscovery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+)
+
+func TestParseSchedule(t *testing.T) {
+	// Friday
+	now := time.Date(2021, 5, 14, 10, 30, 15, 0, time.UTC)
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"@every 30s", now.Add(30 * time.Second)},
+		{"* * * * *", time.Date(2021, 5, 14, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, 5, 14, 10, 45, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2021, 5, 15, 2, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2021, 5, 14, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2021, 5, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2021, 5, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2021, 5, 16, 0, 0, 0, 0, time.UTC)},
+		{"30 22 * * mon-fri", time.Date(2021, 5, 14, 22, 30, 0, 0, time.UTC)},
+		{"0 9,18 1 jan *", time.Date(2022, 1, 1, 9, 0, 0, 0, time.UTC)},
+		// either the day of month or the day of week
+		{"0 0 1 * mon", time.Date(2021, 5, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, schedule.Next(now))
+		})
+	}
+}
+
+func TestParseScheduleError(t *testing.T) {
+	for _, spec := range []string{"", "@every", "@every -1s", "* * * *", "60 * * * *", "* 5-2 * * *", "*/0 * * * *", "* * * foo *"} {
+		t.Run(spec, func(t *testing.T) {
+			_, err := ParseSchedule(spec)
+			if assert.Error(t, err) {
+				assert.Equal(t, errors.KindContractInvalid, errors.Kind(err))
+			}
+		})
+	}
+}
+
+func TestWatcherSchedule(t *testing.T) {
+	pw := models.ProvisionWatcher{Labels: []string{
+		"meter",
+		common.WatcherLabelDiscoverySchedule + "0 2 * * *",
+		common.WatcherLabelDiscoveryScope + "subnet=192.168.1.0/24",
+		common.WatcherLabelDiscoveryScope + "invalid",
+	}}
+	spec, hints, ok := watcherSchedule(pw)
+	assert.True(t, ok)
+	assert.Equal(t, "0 2 * * *", spec)
+	assert.Equal(t, map[string]string{"subnet": "192.168.1.0/24"}, hints)
+
+	_, _, ok = watcherSchedule(models.ProvisionWatcher{Labels: []string{"meter"}})
+	assert.False(t, ok)
+}
@@ -33,7 +33,8 @@ type Executor struct {
 	autoEvent    models.AutoEvent
 	lastReadings map[string]interface{}
 	duration     time.Duration
-	stop         bool
+	stopped      chan struct{}
+	stopOnce     sync.Once
 	rwMutex      *sync.RWMutex
 }
 
@@ -47,10 +48,9 @@ func (e *Executor) Run(ctx context.Context, wg *sync.WaitGroup, dic *di.Containe
 		select {
 		case <-ctx.Done():
 			return
+		case <-e.stopped:
+			return
 		case <-time.After(e.duration):
-			if e.stop {
-				return
-			}
 			ds := container.DeviceServiceFrom(dic.Get)
 			if ds.AdminState == models.Locked {
 				lc.Info("AutoEvent - stopped for locked device service")
@@ -124,9 +124,11 @@ func compareReadings(e *Executor, readings []dtos.BaseReading, lc logger.Logging
 	return identical
 }
 
-// Stop marks this Executor stopped
+// Stop stops this Executor, it may be called more than once
 func (e *Executor) Stop() {
-	e.stop = true
+	e.stopOnce.Do(func() {
+		close(e.stopped)
+	})
 }
 
 // NewExecutor creates an Executor for an AutoEvent
@@ -142,6 +144,6 @@ func NewExecutor(deviceName string, ae models.AutoEvent) (*Executor, error) {
 		autoEvent:    ae,
 		lastReadings: make(map[string]interface{}),
 		duration:     duration,
-		stop:         false,
+		stopped:      make(chan struct{}),
 		rwMutex:      &sync.RWMutex{}}, nil
 }
@@ -16,6 +16,8 @@ import (
 
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/eventbus"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
 )
 
 type Manager interface {
@@ -32,6 +34,7 @@ type manager struct {
 	mutex           sync.Mutex
 	autoeventBuffer chan bool
 	dic             *di.Container
+	unsubscribe     func()
 }
 
 var (
@@ -41,12 +44,35 @@ var (
 
 // NewManager initiates the AutoEvent manager once
 func NewManager(ctx context.Context, wg *sync.WaitGroup, bufferSize int, dic *di.Container) {
+	if m != nil && m.unsubscribe != nil {
+		m.unsubscribe()
+	}
 	m = &manager{
 		ctx:             ctx,
 		wg:              wg,
 		executorMap:     make(map[string][]*Executor),
 		autoeventBuffer: make(chan bool, bufferSize),
 		dic:             dic}
+	m.unsubscribe = eventbus.Subscribe(eventbus.ProfileTopic, m.profileChanged)
+}
+
+// profileChanged restarts the AutoEvents of the devices using the updated profile, as the
+// last readings kept by the executors were produced with the previous one
+func (m *manager) profileChanged(event interface{}) {
+	change := event.(dsModels.ProfileChange)
+	if change.Type != dsModels.ChangeUpdated {
+		return
+	}
+	for _, d := range cache.Devices().All() {
+		if d.ProfileName == change.After.Name {
+			m.mutex.Lock()
+			_, running := m.executorMap[d.Name]
+			m.mutex.Unlock()
+			if running {
+				m.RestartForDevice(d.Name, nil)
+			}
+		}
+	}
 }
 
 func (m *manager) StartAutoEvents(dic *di.Container) bool {
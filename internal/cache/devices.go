@@ -95,6 +95,7 @@ func (d *deviceCache) All() []models.Device {
 func (d *deviceCache) Add(device models.Device) errors.EdgeX {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
+	defer snapshotChanged()
 
 	return d.add(device)
 }
@@ -114,6 +115,7 @@ func (d *deviceCache) add(device models.Device) errors.EdgeX {
 func (d *deviceCache) Update(device models.Device) errors.EdgeX {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
+	defer snapshotChanged()
 
 	if err := d.removeById(device.Id); err != nil {
 		return err
@@ -126,6 +128,7 @@ func (d *deviceCache) Update(device models.Device) errors.EdgeX {
 func (d *deviceCache) RemoveById(id string) errors.EdgeX {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
+	defer snapshotChanged()
 
 	return d.removeById(id)
 }
@@ -144,6 +147,7 @@ func (d *deviceCache) removeById(id string) errors.EdgeX {
 func (d *deviceCache) RemoveByName(name string) errors.EdgeX {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
+	defer snapshotChanged()
 
 	return d.removeByName(name)
 }
@@ -166,6 +170,7 @@ func (d *deviceCache) removeByName(name string) errors.EdgeX {
 func (d *deviceCache) UpdateAdminState(id string, state models.AdminState) errors.EdgeX {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
+	defer snapshotChanged()
 
 	name, ok := d.nameMap[id]
 	if !ok {
@@ -177,12 +182,17 @@ func (d *deviceCache) UpdateAdminState(id string, state models.AdminState) error
 	return nil
 }
 
+// CheckProfileNotUsed returns true if no device uses the profile and no profile extends or
+// includes it.
 func CheckProfileNotUsed(profileName string) bool {
 	for _, device := range dc.deviceMap {
 		if device.ProfileName == profileName {
 			return false
 		}
 	}
+	if pc != nil && len(pc.Dependents(profileName)) > 0 {
+		return false
+	}
 
 	return true
 }
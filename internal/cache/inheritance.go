@@ -0,0 +1,239 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/clients/interfaces"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+)
+
+// ProfileReferences returns the base profile and the included profiles declared by the
+// reserved labels of the profile.
+func ProfileReferences(profile models.DeviceProfile) (string, []string) {
+	var base string
+	var includes []string
+	for _, label := range profile.Labels {
+		if strings.HasPrefix(label, common.ProfileLabelExtends) {
+			base = strings.TrimPrefix(label, common.ProfileLabelExtends)
+		} else if strings.HasPrefix(label, common.ProfileLabelInclude) {
+			includes = append(includes, strings.TrimPrefix(label, common.ProfileLabelInclude))
+		}
+	}
+	return base, includes
+}
+
+// ResolveProfile flattens the profile with its base profile and included profiles, which
+// are resolved the same way and looked up by name. The base profile is applied first, then
+// the included profiles in their order and the profile itself last. The DeviceResources
+// with the same name are merged: the non-empty properties, the description and the tag
+// override the previous ones and the attributes are merged by key, the ones set to
+// common.ProfileValueUnset are cleared. The DeviceCommands and CoreCommands with the same
+// name are replaced.
+func ResolveProfile(profile models.DeviceProfile, lookup func(name string) (models.DeviceProfile, bool)) (models.DeviceProfile, errors.EdgeX) {
+	return resolveProfile(profile, lookup, map[string]bool{})
+}
+
+func resolveProfile(
+	profile models.DeviceProfile,
+	lookup func(name string) (models.DeviceProfile, bool),
+	resolving map[string]bool) (models.DeviceProfile, errors.EdgeX) {
+	base, includes := ProfileReferences(profile)
+	if base == "" && len(includes) == 0 {
+		return profile, nil
+	}
+
+	resolving[profile.Name] = true
+	defer delete(resolving, profile.Name)
+
+	var merged profileMerger
+	for _, name := range append([]string{base}, includes...) {
+		if name == "" {
+			continue
+		}
+		if resolving[name] {
+			errMsg := fmt.Sprintf("profile %s references profile %s cyclically", profile.Name, name)
+			return models.DeviceProfile{}, errors.NewCommonEdgeX(errors.KindContractInvalid, errMsg, nil)
+		}
+		referenced, ok := lookup(name)
+		if !ok {
+			errMsg := fmt.Sprintf("profile %s references missing profile %s", profile.Name, name)
+			return models.DeviceProfile{}, errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, errMsg, nil)
+		}
+		resolved, err := resolveProfile(referenced, lookup, resolving)
+		if err != nil {
+			errMsg := fmt.Sprintf("failed to resolve profile %s", profile.Name)
+			return models.DeviceProfile{}, errors.NewCommonEdgeX(errors.Kind(err), errMsg, err)
+		}
+		merged.apply(resolved)
+	}
+	merged.apply(profile)
+
+	result := profile
+	result.DeviceResources = merged.resources
+	result.DeviceCommands = merged.commands
+	result.CoreCommands = merged.coreCommands
+	return result, nil
+}
+
+// profileMerger accumulates the resources and commands of the applied profiles, keeping
+// the order in which they are first declared.
+type profileMerger struct {
+	resources    []models.DeviceResource
+	commands     []models.ProfileResource
+	coreCommands []models.Command
+}
+
+func (m *profileMerger) apply(profile models.DeviceProfile) {
+	for _, dr := range profile.DeviceResources {
+		if i := m.resourceIndex(dr.Name); i >= 0 {
+			m.resources[i] = mergeDeviceResource(m.resources[i], dr)
+		} else {
+			m.resources = append(m.resources, mergeDeviceResource(models.DeviceResource{}, dr))
+		}
+	}
+	for _, pr := range profile.DeviceCommands {
+		if i := m.commandIndex(pr.Name); i >= 0 {
+			m.commands[i] = pr
+		} else {
+			m.commands = append(m.commands, pr)
+		}
+	}
+	for _, c := range profile.CoreCommands {
+		if i := m.coreCommandIndex(c.Name); i >= 0 {
+			m.coreCommands[i] = c
+		} else {
+			m.coreCommands = append(m.coreCommands, c)
+		}
+	}
+}
+
+func (m *profileMerger) resourceIndex(name string) int {
+	for i := range m.resources {
+		if m.resources[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *profileMerger) commandIndex(name string) int {
+	for i := range m.commands {
+		if m.commands[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *profileMerger) coreCommandIndex(name string) int {
+	for i := range m.coreCommands {
+		if m.coreCommands[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func mergeDeviceResource(base models.DeviceResource, override models.DeviceResource) models.DeviceResource {
+	result := base
+	result.Name = override.Name
+	mergeValue(&result.Description, override.Description)
+	mergeValue(&result.Tag, override.Tag)
+	result.Properties = mergePropertyValue(base.Properties, override.Properties)
+
+	if len(base.Attributes) > 0 || len(override.Attributes) > 0 {
+		result.Attributes = make(map[string]string, len(base.Attributes)+len(override.Attributes))
+		for k, v := range base.Attributes {
+			result.Attributes[k] = v
+		}
+		for k, v := range override.Attributes {
+			if v == common.ProfileValueUnset {
+				delete(result.Attributes, k)
+			} else {
+				result.Attributes[k] = v
+			}
+		}
+	}
+	return result
+}
+
+// mergeValue overrides the target with the non-empty value, common.ProfileValueUnset clears it
+func mergeValue(target *string, value string) {
+	if value == common.ProfileValueUnset {
+		*target = ""
+	} else if value != "" {
+		*target = value
+	}
+}
+
+func mergePropertyValue(base models.PropertyValue, override models.PropertyValue) models.PropertyValue {
+	result := base
+	if override.DataType != 0 {
+		result.DataType = override.DataType
+	}
+	for _, field := range []struct {
+		target *string
+		value  string
+	}{
+		{&result.Type, override.Type},
+		{&result.ReadWrite, override.ReadWrite},
+		{&result.Units, override.Units},
+		{&result.Minimum, override.Minimum},
+		{&result.Maximum, override.Maximum},
+		{&result.DefaultValue, override.DefaultValue},
+		{&result.Mask, override.Mask},
+		{&result.Shift, override.Shift},
+		{&result.Scale, override.Scale},
+		{&result.Offset, override.Offset},
+		{&result.Base, override.Base},
+		{&result.Assertion, override.Assertion},
+		{&result.MediaType, override.MediaType},
+	} {
+		mergeValue(field.target, field.value)
+	}
+	return result
+}
+
+// FetchProfileReferences fetches from Core Metadata the profiles referenced by the profile,
+// directly or through the referenced profiles, which aren't known yet.
+func FetchProfileReferences(
+	ctx context.Context,
+	profile models.DeviceProfile,
+	known func(name string) bool,
+	dpc interfaces.DeviceProfileClient) ([]models.DeviceProfile, errors.EdgeX) {
+	var fetched []models.DeviceProfile
+	visited := map[string]bool{profile.Name: true}
+	pending := []models.DeviceProfile{profile}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+
+		base, includes := ProfileReferences(current)
+		for _, name := range append([]string{base}, includes...) {
+			if name == "" || visited[name] || known(name) {
+				continue
+			}
+			visited[name] = true
+			res, err := dpc.DeviceProfileByName(ctx, name)
+			if err != nil {
+				errMsg := fmt.Sprintf("failed to get profile %s referenced by profile %s", name, current.Name)
+				return nil, errors.NewCommonEdgeX(errors.Kind(err), errMsg, err)
+			}
+			referenced := dtos.ToDeviceProfileModel(res.Profile)
+			fetched = append(fetched, referenced)
+			pending = append(pending, referenced)
+		}
+	}
+	return fetched, nil
+}
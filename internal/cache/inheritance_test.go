@@ -0,0 +1,197 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+)
+
+func inheritanceProfiles() []models.DeviceProfile {
+	return []models.DeviceProfile{
+		{
+			Id:   "base-id",
+			Name: "meter",
+			DeviceResources: []models.DeviceResource{
+				{Name: "voltage", Description: "voltage",
+					Properties: models.PropertyValue{Type: contracts.ValueTypeUint16, ReadWrite: "R", Scale: "0.1", Units: "V"},
+					Attributes: map[string]string{"register": "1", "function": "3"}},
+				{Name: "current", Properties: models.PropertyValue{Type: contracts.ValueTypeUint16, ReadWrite: "R"}},
+			},
+			DeviceCommands: []models.ProfileResource{
+				{Name: "values", Get: []models.ResourceOperation{{DeviceResource: "voltage"}, {DeviceResource: "current"}}},
+			},
+			CoreCommands: []models.Command{{Name: "values", Get: true}},
+		},
+		{
+			Id:   "group-id",
+			Name: "relay",
+			DeviceResources: []models.DeviceResource{
+				{Name: "relay", Properties: models.PropertyValue{Type: contracts.ValueTypeBool, ReadWrite: "RW"}},
+			},
+			CoreCommands: []models.Command{{Name: "relay", Get: true, Put: true}},
+		},
+		{
+			Id:     "child-id",
+			Name:   "meter-v2",
+			Labels: []string{"power", common.ProfileLabelExtends + "meter", common.ProfileLabelInclude + "relay"},
+			DeviceResources: []models.DeviceResource{
+				{Name: "voltage", Properties: models.PropertyValue{Scale: "0.01"}, Attributes: map[string]string{"register": "10"}},
+			},
+			DeviceCommands: []models.ProfileResource{
+				{Name: "values", Get: []models.ResourceOperation{{DeviceResource: "voltage"}}},
+			},
+		},
+	}
+}
+
+func TestResolveProfile(t *testing.T) {
+	profiles := inheritanceProfiles()
+	lookup := func(name string) (models.DeviceProfile, bool) {
+		for _, p := range profiles {
+			if p.Name == name {
+				return p, true
+			}
+		}
+		return models.DeviceProfile{}, false
+	}
+
+	resolved, err := ResolveProfile(profiles[2], lookup)
+	require.NoError(t, err)
+	assert.Equal(t, "child-id", resolved.Id)
+	assert.Equal(t, profiles[2].Labels, resolved.Labels)
+	require.Len(t, resolved.DeviceResources, 3)
+
+	voltage := resolved.DeviceResources[0]
+	assert.Equal(t, "voltage", voltage.Description)
+	assert.Equal(t, models.PropertyValue{Type: contracts.ValueTypeUint16, ReadWrite: "R", Scale: "0.01", Units: "V"}, voltage.Properties)
+	assert.Equal(t, map[string]string{"register": "10", "function": "3"}, voltage.Attributes)
+	assert.Equal(t, "current", resolved.DeviceResources[1].Name)
+	assert.Equal(t, "relay", resolved.DeviceResources[2].Name)
+	assert.Equal(t, []models.ProfileResource{{Name: "values", Get: []models.ResourceOperation{{DeviceResource: "voltage"}}}}, resolved.DeviceCommands)
+	assert.Equal(t, []models.Command{{Name: "values", Get: true}, {Name: "relay", Get: true, Put: true}}, resolved.CoreCommands)
+	conflicts, warnings := ProfileIssues(resolved)
+	assert.Empty(t, conflicts)
+	assert.Empty(t, warnings)
+
+	// the base profile isn't modified by the overrides
+	assert.Equal(t, "1", profiles[0].DeviceResources[0].Attributes["register"])
+	assert.Equal(t, "0.1", profiles[0].DeviceResources[0].Properties.Scale)
+
+	missing := profiles[2]
+	missing.Labels = []string{common.ProfileLabelExtends + "missing"}
+	_, err = ResolveProfile(missing, lookup)
+	if assert.Error(t, err) {
+		assert.Equal(t, errors.KindEntityDoesNotExist, errors.Kind(err))
+	}
+
+	profiles[0].Labels = []string{common.ProfileLabelInclude + "meter-v2"}
+	_, err = ResolveProfile(profiles[2], lookup)
+	if assert.Error(t, err) {
+		assert.Equal(t, errors.KindContractInvalid, errors.Kind(err))
+	}
+}
+
+func TestProfileCache_Resolved(t *testing.T) {
+	cache := newProfileCache(inheritanceProfiles())
+
+	raw, ok := cache.ForName("meter-v2")
+	require.True(t, ok)
+	assert.Len(t, raw.DeviceResources, 1)
+	resolved, ok := cache.Resolved("meter-v2")
+	require.True(t, ok)
+	assert.Len(t, resolved.DeviceResources, 3)
+
+	dr, ok := cache.DeviceResource("meter-v2", "current")
+	assert.True(t, ok)
+	assert.Equal(t, contracts.ValueTypeUint16, dr.Properties.Type)
+	exists, err := cache.CommandExists("meter-v2", "values", common.GetCmdMethod)
+	assert.True(t, exists)
+	assert.NoError(t, err)
+	_, ok = cache.CoreCommand("meter-v2", "relay")
+	assert.True(t, ok)
+
+	assert.Equal(t, []string{"meter-v2"}, cache.Dependents("meter"))
+	assert.Empty(t, cache.Dependents("meter-v2"))
+
+	// the profiles extending an updated profile are resolved again
+	base := inheritanceProfiles()[0]
+	base.DeviceResources[1].Properties.Type = contracts.ValueTypeUint32
+	require.NoError(t, cache.Update(base))
+	dr, ok = cache.DeviceResource("meter-v2", "current")
+	assert.True(t, ok)
+	assert.Equal(t, contracts.ValueTypeUint32, dr.Properties.Type)
+
+	// a profile whose base is removed keeps its last resolution and is reported
+	assert.Empty(t, cache.Unresolved())
+	require.NoError(t, cache.RemoveByName("meter"))
+	dr, ok = cache.DeviceResource("meter-v2", "current")
+	assert.True(t, ok)
+	assert.Equal(t, contracts.ValueTypeUint32, dr.Properties.Type)
+	_, err = cache.Resolve(raw)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"meter-v2"}, cache.Unresolved())
+
+	// a profile never resolved is kept with its own content
+	orphan := raw
+	orphan.Id, orphan.Name = "orphan-id", "meter-v3"
+	require.NoError(t, cache.Add(orphan))
+	resolved, ok = cache.Resolved("meter-v3")
+	require.True(t, ok)
+	assert.Len(t, resolved.DeviceResources, 1)
+	assert.Equal(t, []string{"meter-v2", "meter-v3"}, cache.Unresolved())
+
+	// the last resolution is dropped once the profile itself changes
+	changed := raw
+	changed.Description = "changed"
+	require.NoError(t, cache.Update(changed))
+	resolved, ok = cache.Resolved("meter-v2")
+	require.True(t, ok)
+	assert.Equal(t, changed, resolved)
+	_, ok = cache.DeviceResource("meter-v2", "current")
+	assert.False(t, ok)
+
+	// the profiles are resolved again once their base is back
+	require.NoError(t, cache.Add(base))
+	dr, ok = cache.DeviceResource("meter-v2", "current")
+	assert.True(t, ok)
+	assert.Equal(t, contracts.ValueTypeUint32, dr.Properties.Type)
+	assert.Empty(t, cache.Unresolved())
+}
+
+func TestResolveProfile_Unset(t *testing.T) {
+	profiles := inheritanceProfiles()
+	lookup := func(name string) (models.DeviceProfile, bool) {
+		for _, p := range profiles {
+			if p.Name == name {
+				return p, true
+			}
+		}
+		return models.DeviceProfile{}, false
+	}
+
+	child := profiles[2]
+	child.DeviceResources = []models.DeviceResource{{
+		Name:        "voltage",
+		Description: common.ProfileValueUnset,
+		Properties:  models.PropertyValue{Scale: common.ProfileValueUnset, Units: ""},
+		Attributes:  map[string]string{"function": common.ProfileValueUnset},
+	}}
+	resolved, err := ResolveProfile(child, lookup)
+	require.NoError(t, err)
+	voltage := resolved.DeviceResources[0]
+	assert.Empty(t, voltage.Description)
+	assert.Equal(t, models.PropertyValue{Type: contracts.ValueTypeUint16, ReadWrite: "R", Units: "V"}, voltage.Properties, "an empty value isn't overridden")
+	assert.Equal(t, map[string]string{"register": "1"}, voltage.Attributes)
+}
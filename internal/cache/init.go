@@ -17,6 +17,7 @@ import (
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/provision"
 	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
 )
 
@@ -58,7 +59,24 @@ func InitCache(serviceName string,
 			dpMap[dcs[i].ProfileName] = struct{}{}
 			dps = append(dps, dtos.ToDeviceProfileModel(dpr.Profile))
 		}
+		// the base and included profiles are needed to resolve the profiles of the devices
+		known := func(name string) bool {
+			_, ok := dpMap[name]
+			return ok
+		}
+		for i, count := 0, len(dps); i < count; i++ {
+			referenced, err := FetchProfileReferences(ctx, dps[i], known, dp)
+			if err != nil {
+				lc.Error(err.Error())
+				continue
+			}
+			for _, profile := range referenced {
+				dpMap[profile.Name] = struct{}{}
+				dps = append(dps, profile)
+			}
+		}
 		newProfileCache(dps)
+		removeInvalidProfiles(lc)
 
 		pwr, err := pwc.ProvisionWatchersByServiceName(ctx, serviceName, 0, -1)
 		if err != nil {
@@ -66,8 +84,33 @@ func InitCache(serviceName string,
 		}
 		var pws []models.ProvisionWatcher
 		for i := range pwr.ProvisionWatchers {
-			pws = append(pws, dtos.ToProvisionWatcherModel(pwr.ProvisionWatchers[i]))
+			pw := dtos.ToProvisionWatcherModel(pwr.ProvisionWatchers[i])
+			if _, err := provision.Compile(pw); err != nil {
+				lc.Error(err.Error())
+				continue
+			}
+			pws = append(pws, pw)
 		}
 		newProvisionWatcherCache(pws)
 	})
 }
+
+// removeInvalidProfiles removes the profiles which can't be resolved or whose resolution
+// has conflicts, until the profiles referencing the removed ones are removed as well. The
+// warnings of the profiles are only logged.
+func removeInvalidProfiles(lc logger.LoggingClient) {
+	for removed := true; removed; {
+		removed = false
+		for _, profile := range pc.All() {
+			resolved, err := pc.Resolve(profile)
+			if err == nil {
+				err = ValidateProfile(resolved, lc)
+			}
+			if err != nil {
+				lc.Error(err.Error())
+				_ = pc.RemoveByName(profile.Name)
+				removed = true
+			}
+		}
+	}
+}
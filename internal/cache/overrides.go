@@ -0,0 +1,167 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+)
+
+const (
+	overrideAttributes = ".attributes."
+	overrideProperties = ".properties."
+)
+
+// DeviceResourceOf returns the DeviceResource of the device's profile with the overrides of
+// the device applied.
+func DeviceResourceOf(device models.Device, resourceName string) (models.DeviceResource, bool) {
+	dr, ok := Profiles().DeviceResource(device.ProfileName, resourceName)
+	if !ok {
+		return dr, ok
+	}
+	return ApplyOverrides(dr, device.Protocols[common.ProtocolOverrides]), true
+}
+
+// WithoutOverrides returns the protocols without the reserved overrides protocol, which is
+// for the SDK only, the protocols handed to the driver are stripped of it.
+func WithoutOverrides(protocols map[string]models.ProtocolProperties) map[string]models.ProtocolProperties {
+	if _, ok := protocols[common.ProtocolOverrides]; !ok {
+		return protocols
+	}
+	stripped := make(map[string]models.ProtocolProperties, len(protocols))
+	for name, properties := range protocols {
+		if name != common.ProtocolOverrides {
+			stripped[name] = properties
+		}
+	}
+	return stripped
+}
+
+// ApplyOverrides returns the DeviceResource with the overrides of the reserved protocol
+// properties applied, the keys are "<resource>.attributes.<attribute>" and
+// "<resource>.properties.<property>", where property is the JSON name of the
+// PropertyValue field, e.g. "voltage.properties.scale". The given DeviceResource is
+// left unchanged.
+func ApplyOverrides(dr models.DeviceResource, overrides models.ProtocolProperties) models.DeviceResource {
+	copied := false
+	for key, value := range overrides {
+		if !strings.HasPrefix(key, dr.Name) {
+			continue
+		}
+		rest := key[len(dr.Name):]
+		if strings.HasPrefix(rest, overrideAttributes) {
+			if !copied {
+				attributes := make(map[string]string, len(dr.Attributes)+1)
+				for k, v := range dr.Attributes {
+					attributes[k] = v
+				}
+				dr.Attributes = attributes
+				copied = true
+			}
+			dr.Attributes[strings.TrimPrefix(rest, overrideAttributes)] = value
+		} else if strings.HasPrefix(rest, overrideProperties) {
+			if field := propertyField(&dr.Properties, strings.TrimPrefix(rest, overrideProperties)); field != nil {
+				*field = value
+			}
+		}
+	}
+	return dr
+}
+
+// propertyField returns the PropertyValue field with the given JSON name, nil if there is
+// no such string field.
+func propertyField(pv *models.PropertyValue, name string) *string {
+	switch name {
+	case "type":
+		return &pv.Type
+	case "readWrite":
+		return &pv.ReadWrite
+	case "units":
+		return &pv.Units
+	case "minimum":
+		return &pv.Minimum
+	case "maximum":
+		return &pv.Maximum
+	case "defaultValue":
+		return &pv.DefaultValue
+	case "mask":
+		return &pv.Mask
+	case "shift":
+		return &pv.Shift
+	case "scale":
+		return &pv.Scale
+	case "offset":
+		return &pv.Offset
+	case "base":
+		return &pv.Base
+	case "assertion":
+		return &pv.Assertion
+	case "mediaType":
+		return &pv.MediaType
+	}
+	return nil
+}
+
+// ValidateOverrides checks the overrides of the device against its profile, the overrides
+// referencing missing resources or unknown properties and the overridden resources which
+// are invalid are reported in the returned error.
+func ValidateOverrides(device models.Device) errors.EdgeX {
+	overrides := device.Protocols[common.ProtocolOverrides]
+	if len(overrides) == 0 {
+		return nil
+	}
+	profile, ok := Profiles().Resolved(device.ProfileName)
+	if !ok {
+		return nil
+	}
+
+	resources := make(map[string]models.DeviceResource, len(profile.DeviceResources))
+	for _, dr := range profile.DeviceResources {
+		resources[dr.Name] = ApplyOverrides(dr, overrides)
+	}
+
+	var issues []string
+	overridden := make(map[string]bool)
+	for _, key := range sortedKeys(overrides) {
+		name, kind, field := splitOverrideKey(key)
+		if _, ok := resources[name]; !ok {
+			issues = append(issues, fmt.Sprintf("override %s references no deviceResource", key))
+			continue
+		}
+		if kind == overrideProperties && propertyField(&models.PropertyValue{}, field) == nil {
+			issues = append(issues, fmt.Sprintf("override %s references unknown property %s", key, field))
+			continue
+		}
+		overridden[name] = true
+	}
+	for _, dr := range profile.DeviceResources {
+		if overridden[dr.Name] {
+			issues = append(issues, deviceResourceIssues(resources[dr.Name], resources)...)
+		}
+	}
+
+	if len(issues) == 0 {
+		return nil
+	}
+	errMsg := fmt.Sprintf("overrides of device %s are invalid: %s", device.Name, strings.Join(issues, "; "))
+	return errors.NewCommonEdgeX(errors.KindContractInvalid, errMsg, nil)
+}
+
+// splitOverrideKey splits the override key into the resource name, the section and the
+// attribute or property name, the resource name is empty if the key has no section.
+func splitOverrideKey(key string) (string, string, string) {
+	for _, kind := range []string{overrideAttributes, overrideProperties} {
+		if i := strings.LastIndex(key, kind); i > 0 {
+			return key[:i], kind, key[i+len(kind):]
+		}
+	}
+	return "", "", ""
+}
@@ -0,0 +1,87 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+)
+
+func TestApplyOverrides(t *testing.T) {
+	dr := validProfile().DeviceResources[1]
+	dr.Attributes = map[string]string{"register": "1", "function": "3"}
+	overrides := models.ProtocolProperties{
+		"status.attributes.register": "101",
+		"status.properties.scale":    "0.01",
+		"status.properties.unknown":  "1",
+		"switch.properties.scale":    "10",
+	}
+
+	overridden := ApplyOverrides(dr, overrides)
+	assert.Equal(t, map[string]string{"register": "101", "function": "3"}, overridden.Attributes)
+	assert.Equal(t, "0.01", overridden.Properties.Scale)
+	assert.Equal(t, dr.Properties.Type, overridden.Properties.Type)
+	// the profile's DeviceResource is left unchanged
+	assert.Equal(t, "1", dr.Attributes["register"])
+	assert.Equal(t, "0.1", dr.Properties.Scale)
+}
+
+func TestDeviceResourceOf(t *testing.T) {
+	newProfileCache([]models.DeviceProfile{validProfile()})
+	device := models.Device{Name: "device", ProfileName: "profile", Protocols: map[string]models.ProtocolProperties{
+		common.ProtocolOverrides: {"status.properties.scale": "0.01"},
+	}}
+
+	dr, ok := DeviceResourceOf(device, "status")
+	require.True(t, ok)
+	assert.Equal(t, "0.01", dr.Properties.Scale)
+	dr, ok = DeviceResourceOf(models.Device{Name: "other", ProfileName: "profile"}, "status")
+	require.True(t, ok)
+	assert.Equal(t, "0.1", dr.Properties.Scale)
+	_, ok = DeviceResourceOf(device, "missing")
+	assert.False(t, ok)
+
+	assert.NoError(t, ValidateOverrides(device))
+	tests := []struct {
+		name      string
+		overrides models.ProtocolProperties
+		expected  string
+	}{
+		{"missing resource", models.ProtocolProperties{"missing.properties.scale": "1"}, "override missing.properties.scale references no deviceResource"},
+		{"unknown property", models.ProtocolProperties{"status.properties.factor": "1"}, "override status.properties.factor references unknown property factor"},
+		{"invalid property", models.ProtocolProperties{"status.properties.scale": "x"}, `deviceResource status: scale "x" is not a number`},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			device.Protocols[common.ProtocolOverrides] = testCase.overrides
+			err := ValidateOverrides(device)
+			if assert.Error(t, err) {
+				assert.Equal(t, errors.KindContractInvalid, errors.Kind(err))
+				assert.Contains(t, err.Error(), testCase.expected)
+			}
+		})
+	}
+}
+
+func TestWithoutOverrides(t *testing.T) {
+	protocols := map[string]models.ProtocolProperties{
+		"modbus-tcp":             {"Address": "10.0.0.1"},
+		common.ProtocolOverrides: {"status.properties.scale": "0.01"},
+	}
+	stripped := WithoutOverrides(protocols)
+	assert.Equal(t, map[string]models.ProtocolProperties{"modbus-tcp": {"Address": "10.0.0.1"}}, stripped)
+	assert.Contains(t, protocols, common.ProtocolOverrides, "the device protocols are left unchanged")
+
+	delete(protocols, common.ProtocolOverrides)
+	assert.Equal(t, protocols, WithoutOverrides(protocols))
+}
@@ -8,6 +8,8 @@ package cache
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
 
@@ -22,6 +24,10 @@ var (
 
 type ProfileCache interface {
 	ForName(name string) (models.DeviceProfile, bool)
+	Resolved(name string) (models.DeviceProfile, bool)
+	Resolve(profile models.DeviceProfile) (models.DeviceProfile, errors.EdgeX)
+	Dependents(name string) []string
+	Unresolved() []string
 	ForId(id string) (models.DeviceProfile, bool)
 	All() []models.DeviceProfile
 	Add(profile models.DeviceProfile) errors.EdgeX
@@ -30,13 +36,19 @@ type ProfileCache interface {
 	RemoveByName(name string) errors.EdgeX
 	DeviceResource(profileName string, resourceName string) (models.DeviceResource, bool)
 	CommandExists(profileName string, cmd string, method string) (bool, errors.EdgeX)
+	CoreCommand(profileName string, cmd string) (models.Command, bool)
 	ResourceOperations(profileName string, cmd string, method string) ([]models.ResourceOperation, errors.EdgeX)
 	ResourceOperation(profileName string, deviceResource string, method string) (models.ResourceOperation, errors.EdgeX)
 }
 
+// profileCache keeps the profiles as they're defined in Core Metadata, the lookup maps are
+// derived from the profiles flattened with their base and included profiles.
 type profileCache struct {
 	deviceProfileMap         map[string]*models.DeviceProfile // key is DeviceProfile name
 	nameMap                  map[string]string                // key is id, and value is DeviceProfile name
+	resolvedMap              map[string]*models.DeviceProfile // key is DeviceProfile name
+	resolvedFromMap          map[string]models.DeviceProfile  // key is DeviceProfile name, the content the resolution was made of
+	unresolved               []string
 	deviceResourceMap        map[string]map[string]models.DeviceResource
 	getResourceOperationsMap map[string]map[string][]models.ResourceOperation
 	setResourceOperationsMap map[string]map[string][]models.ResourceOperation
@@ -48,27 +60,59 @@ func newProfileCache(profiles []models.DeviceProfile) ProfileCache {
 	defaultSize := len(profiles)
 	dpMap := make(map[string]*models.DeviceProfile, defaultSize)
 	nameMap := make(map[string]string, defaultSize)
-	drMap := make(map[string]map[string]models.DeviceResource, defaultSize)
-	getRoMap := make(map[string]map[string][]models.ResourceOperation, defaultSize)
-	setRoMap := make(map[string]map[string][]models.ResourceOperation, defaultSize)
-	cmdMap := make(map[string]map[string]models.Command, defaultSize)
-	for _, dp := range profiles {
-		dpMap[dp.Name] = &dp
+	for i, dp := range profiles {
+		dpMap[dp.Name] = &profiles[i]
 		nameMap[dp.Id] = dp.Name
-		drMap[dp.Name] = deviceResourceSliceToMap(dp.DeviceResources)
-		getRoMap[dp.Name], setRoMap[dp.Name] = profileResourceSliceToMaps(dp.DeviceCommands)
-		cmdMap[dp.Name] = commandSliceToMap(dp.CoreCommands)
 	}
 	pc = &profileCache{
-		deviceProfileMap:         dpMap,
-		nameMap:                  nameMap,
-		deviceResourceMap:        drMap,
-		getResourceOperationsMap: getRoMap,
-		setResourceOperationsMap: setRoMap,
-		commandsMap:              cmdMap}
+		deviceProfileMap: dpMap,
+		nameMap:          nameMap}
+	pc.resolveAll()
 	return pc
 }
 
+// resolveAll rebuilds the flattened profiles and the lookup maps, as a change of a profile
+// affects the profiles extending or including it. A profile whose references can't be
+// resolved anymore, e.g. its base profile was removed, keeps its last resolution as long
+// as its own content is unchanged, otherwise it's kept with its own content. The profiles
+// which failed to resolve are reported by Unresolved.
+func (p *profileCache) resolveAll() {
+	defaultSize := len(p.deviceProfileMap)
+	previous, previousFrom := p.resolvedMap, p.resolvedFromMap
+	p.resolvedMap = make(map[string]*models.DeviceProfile, defaultSize)
+	p.resolvedFromMap = make(map[string]models.DeviceProfile, defaultSize)
+	p.unresolved = nil
+	p.deviceResourceMap = make(map[string]map[string]models.DeviceResource, defaultSize)
+	p.getResourceOperationsMap = make(map[string]map[string][]models.ResourceOperation, defaultSize)
+	p.setResourceOperationsMap = make(map[string]map[string][]models.ResourceOperation, defaultSize)
+	p.commandsMap = make(map[string]map[string]models.Command, defaultSize)
+	for name, profile := range p.deviceProfileMap {
+		resolved, err := ResolveProfile(*profile, p.lookup)
+		from := *profile
+		if err != nil {
+			p.unresolved = append(p.unresolved, name)
+			if last, ok := previous[name]; ok && reflect.DeepEqual(previousFrom[name], *profile) {
+				resolved = *last
+			} else {
+				resolved = *profile
+			}
+		}
+		p.resolvedMap[name] = &resolved
+		p.resolvedFromMap[name] = from
+		p.deviceResourceMap[name] = deviceResourceSliceToMap(resolved.DeviceResources)
+		p.getResourceOperationsMap[name], p.setResourceOperationsMap[name] = profileResourceSliceToMaps(resolved.DeviceCommands)
+		p.commandsMap[name] = commandSliceToMap(resolved.CoreCommands)
+	}
+}
+
+func (p *profileCache) lookup(name string) (models.DeviceProfile, bool) {
+	profile, ok := p.deviceProfileMap[name]
+	if !ok {
+		return models.DeviceProfile{}, ok
+	}
+	return *profile, ok
+}
+
 // ForName returns a profile with the given profile name.
 func (p *profileCache) ForName(name string) (models.DeviceProfile, bool) {
 	p.mutex.Lock()
@@ -81,6 +125,77 @@ func (p *profileCache) ForName(name string) (models.DeviceProfile, bool) {
 	return *profile, ok
 }
 
+// Resolved returns the profile with the given name flattened with its base and included profiles.
+func (p *profileCache) Resolved(name string) (models.DeviceProfile, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	profile, ok := p.resolvedMap[name]
+	if !ok {
+		return models.DeviceProfile{}, ok
+	}
+	return *profile, ok
+}
+
+// Unresolved returns the names of the profiles whose base or included profiles can't be
+// resolved, which are kept with their last resolution or their own content.
+func (p *profileCache) Unresolved() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	names := make([]string, len(p.unresolved))
+	copy(names, p.unresolved)
+	sort.Strings(names)
+	return names
+}
+
+// Resolve flattens the given profile with its base and included profiles from the cache,
+// the given profile takes the place of the cached one with the same name.
+func (p *profileCache) Resolve(profile models.DeviceProfile) (models.DeviceProfile, errors.EdgeX) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return ResolveProfile(profile, func(name string) (models.DeviceProfile, bool) {
+		if name == profile.Name {
+			return profile, true
+		}
+		return p.lookup(name)
+	})
+}
+
+// Dependents returns the names of the profiles extending or including the profile with the
+// given name, directly or through other profiles.
+func (p *profileCache) Dependents(name string) []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var dependents []string
+	for candidate, profile := range p.deviceProfileMap {
+		if candidate != name && p.references(*profile, name, map[string]bool{}) {
+			dependents = append(dependents, candidate)
+		}
+	}
+	sort.Strings(dependents)
+	return dependents
+}
+
+func (p *profileCache) references(profile models.DeviceProfile, name string, visited map[string]bool) bool {
+	visited[profile.Name] = true
+	base, includes := ProfileReferences(profile)
+	for _, referenced := range append([]string{base}, includes...) {
+		if referenced == name {
+			return true
+		}
+		if referenced == "" || visited[referenced] {
+			continue
+		}
+		if next, ok := p.deviceProfileMap[referenced]; ok && p.references(*next, name, visited) {
+			return true
+		}
+	}
+	return false
+}
+
 // ForName returns a profile with the given profile id.
 func (p *profileCache) ForId(id string) (models.DeviceProfile, bool) {
 	p.mutex.Lock()
@@ -114,8 +229,13 @@ func (p *profileCache) All() []models.DeviceProfile {
 func (p *profileCache) Add(profile models.DeviceProfile) errors.EdgeX {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
+	defer snapshotChanged()
 
-	return p.add(profile)
+	if err := p.add(profile); err != nil {
+		return err
+	}
+	p.resolveAll()
+	return nil
 }
 
 func (p *profileCache) add(profile models.DeviceProfile) errors.EdgeX {
@@ -126,9 +246,6 @@ func (p *profileCache) add(profile models.DeviceProfile) errors.EdgeX {
 
 	p.deviceProfileMap[profile.Name] = &profile
 	p.nameMap[profile.Id] = profile.Name
-	p.deviceResourceMap[profile.Name] = deviceResourceSliceToMap(profile.DeviceResources)
-	p.getResourceOperationsMap[profile.Name], p.setResourceOperationsMap[profile.Name] = profileResourceSliceToMaps(profile.DeviceCommands)
-	p.commandsMap[profile.Name] = commandSliceToMap(profile.CoreCommands)
 	return nil
 }
 
@@ -169,20 +286,29 @@ func commandSliceToMap(commands []models.Command) map[string]models.Command {
 func (p *profileCache) Update(profile models.DeviceProfile) errors.EdgeX {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
+	defer snapshotChanged()
 
 	if err := p.removeById(profile.Id); err != nil {
 		return err
 	}
-
-	return p.add(profile)
+	if err := p.add(profile); err != nil {
+		return err
+	}
+	p.resolveAll()
+	return nil
 }
 
 // RemoveById removes the specified profile by id from the cache.
 func (p *profileCache) RemoveById(id string) errors.EdgeX {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
+	defer snapshotChanged()
 
-	return p.removeById(id)
+	if err := p.removeById(id); err != nil {
+		return err
+	}
+	p.resolveAll()
+	return nil
 }
 
 func (p *profileCache) removeById(id string) errors.EdgeX {
@@ -199,8 +325,13 @@ func (p *profileCache) removeById(id string) errors.EdgeX {
 func (p *profileCache) RemoveByName(name string) errors.EdgeX {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
+	defer snapshotChanged()
 
-	return p.removeByName(name)
+	if err := p.removeByName(name); err != nil {
+		return err
+	}
+	p.resolveAll()
+	return nil
 }
 
 func (p *profileCache) removeByName(name string) errors.EdgeX {
@@ -212,10 +343,6 @@ func (p *profileCache) removeByName(name string) errors.EdgeX {
 
 	delete(p.deviceProfileMap, name)
 	delete(p.nameMap, profile.Id)
-	delete(p.deviceResourceMap, name)
-	delete(p.getResourceOperationsMap, name)
-	delete(p.setResourceOperationsMap, name)
-	delete(p.commandsMap, name)
 	return nil
 }
 
@@ -260,6 +387,15 @@ func (p *profileCache) CommandExists(profileName string, cmd string, method stri
 	return true, nil
 }
 
+// CoreCommand returns the CoreCommand of the profile with the given name.
+func (p *profileCache) CoreCommand(profileName string, cmd string) (models.Command, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	command, ok := p.commandsMap[profileName][cmd]
+	return command, ok
+}
+
 // ResourceOperations returns the ResourceOperations with given command and method.
 func (p *profileCache) ResourceOperations(profileName string, cmd string, method string) ([]models.ResourceOperation, errors.EdgeX) {
 	p.mutex.Lock()
@@ -339,3 +475,12 @@ func (p *profileCache) verifyResourceOperationsExists(method string, profileName
 func Profiles() ProfileCache {
 	return pc
 }
+
+// UnresolvedProfiles returns the names of the profiles which can't be resolved, none before
+// the cache is initialized.
+func UnresolvedProfiles() []string {
+	if pc == nil {
+		return nil
+	}
+	return pc.Unresolved()
+}
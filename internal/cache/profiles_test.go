@@ -217,3 +217,20 @@ func TestProfileCache_ResourceOperation(t *testing.T) {
 		t.Error("the input deviceResource name of resource operation is not belong to DeviceProfileRandomBoolGenerator, supposed to get an error")
 	}
 }
+
+func TestProfileCache_CoreCommand(t *testing.T) {
+	profile := models.DeviceProfile{
+		Name:         "profile",
+		CoreCommands: []models.Command{{Name: "status", Get: true}},
+	}
+	dpc := newProfileCache([]models.DeviceProfile{profile})
+
+	command, ok := dpc.CoreCommand(profile.Name, "status")
+	assert.True(t, ok)
+	assert.Equal(t, profile.CoreCommands[0], command)
+
+	_, ok = dpc.CoreCommand(profile.Name, "arbitaryNameXXX")
+	assert.False(t, ok)
+	_, ok = dpc.CoreCommand("arbitaryProfileXXX", "status")
+	assert.False(t, ok)
+}
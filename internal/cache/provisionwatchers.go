@@ -11,6 +11,7 @@ import (
 
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/provision"
 )
 
 var (
@@ -21,6 +22,7 @@ type ProvisionWatcherCache interface {
 	ForName(name string) (models.ProvisionWatcher, bool)
 	ForId(id string) (models.ProvisionWatcher, bool)
 	All() []models.ProvisionWatcher
+	Compiled() []*provision.Watcher
 	Add(device models.ProvisionWatcher) error
 	Update(device models.ProvisionWatcher) error
 	RemoveById(id string) error
@@ -31,7 +33,10 @@ type ProvisionWatcherCache interface {
 type provisionWatcherCache struct {
 	pwMap   map[string]*models.ProvisionWatcher // key is ProvisionWatcher name
 	nameMap map[string]string                   // key is id, and value is ProvisionWatcher name
-	mutex   sync.Mutex
+	// compiled keeps the ProvisionWatchers with their compiled rules, a compiled watcher
+	// is replaced rather than modified as it's used outside of the lock
+	compiled map[string]*provision.Watcher // key is ProvisionWatcher name
+	mutex    sync.Mutex
 }
 
 // ForName returns a provision watcher with the given name.
@@ -77,10 +82,23 @@ func (p *provisionWatcherCache) All() []models.ProvisionWatcher {
 	return watchers
 }
 
-// Add adds a new provision watcher to the cache.
+// Compiled returns the current list of provision watchers in the cache with their compiled rules.
+func (p *provisionWatcherCache) Compiled() []*provision.Watcher {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	watchers := make([]*provision.Watcher, 0, len(p.compiled))
+	for _, watcher := range p.compiled {
+		watchers = append(watchers, watcher)
+	}
+	return watchers
+}
+
+// Add adds a new provision watcher to the cache, its rules must compile.
 func (p *provisionWatcherCache) Add(watcher models.ProvisionWatcher) error {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
+	defer snapshotChanged()
 
 	return p.add(watcher)
 }
@@ -90,9 +108,14 @@ func (p *provisionWatcherCache) add(watcher models.ProvisionWatcher) error {
 		errMsg := fmt.Sprintf("provision watcher %s has already existed in cache", watcher.Name)
 		return errors.NewCommonEdgeX(errors.KindDuplicateName, errMsg, nil)
 	}
+	compiled, err := provision.Compile(watcher)
+	if err != nil {
+		return err
+	}
 
 	p.pwMap[watcher.Name] = &watcher
 	p.nameMap[watcher.Id] = watcher.Name
+	p.compiled[watcher.Name] = compiled
 	return nil
 }
 
@@ -100,7 +123,11 @@ func (p *provisionWatcherCache) add(watcher models.ProvisionWatcher) error {
 func (p *provisionWatcherCache) Update(watcher models.ProvisionWatcher) error {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
+	defer snapshotChanged()
 
+	if _, err := provision.Compile(watcher); err != nil {
+		return err
+	}
 	if err := p.removeById(watcher.Id); err != nil {
 		return err
 	}
@@ -111,6 +138,7 @@ func (p *provisionWatcherCache) Update(watcher models.ProvisionWatcher) error {
 func (p *provisionWatcherCache) RemoveById(id string) error {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
+	defer snapshotChanged()
 
 	return p.removeById(id)
 }
@@ -129,6 +157,7 @@ func (p *provisionWatcherCache) removeById(id string) error {
 func (p *provisionWatcherCache) RemoveByName(name string) error {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
+	defer snapshotChanged()
 
 	return p.removeByName(name)
 }
@@ -142,6 +171,7 @@ func (p *provisionWatcherCache) removeByName(name string) error {
 
 	delete(p.pwMap, name)
 	delete(p.nameMap, watcher.Id)
+	delete(p.compiled, name)
 	return nil
 }
 
@@ -149,6 +179,7 @@ func (p *provisionWatcherCache) removeByName(name string) error {
 func (p *provisionWatcherCache) UpdateAdminState(id string, state models.AdminState) error {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
+	defer snapshotChanged()
 
 	name, ok := p.nameMap[id]
 	if !ok {
@@ -157,18 +188,29 @@ func (p *provisionWatcherCache) UpdateAdminState(id string, state models.AdminSt
 	}
 
 	p.pwMap[name].AdminState = state
+	compiled := *p.compiled[name]
+	compiled.AdminState = state
+	p.compiled[name] = &compiled
 	return nil
 }
 
+// newProvisionWatcherCache creates the cache with the given provision watchers, the ones whose
+// rules don't compile are left out.
 func newProvisionWatcherCache(pws []models.ProvisionWatcher) ProvisionWatcherCache {
 	defaultSize := len(pws)
 	pwMap := make(map[string]*models.ProvisionWatcher, defaultSize)
 	nameMap := make(map[string]string, defaultSize)
+	compiledMap := make(map[string]*provision.Watcher, defaultSize)
 	for i, pw := range pws {
+		compiled, err := provision.Compile(pw)
+		if err != nil {
+			continue
+		}
 		pwMap[pw.Name] = &pws[i]
 		nameMap[pw.Id] = pw.Name
+		compiledMap[pw.Name] = compiled
 	}
-	pwc = &provisionWatcherCache{pwMap: pwMap, nameMap: nameMap}
+	pwc = &provisionWatcherCache{pwMap: pwMap, nameMap: nameMap, compiled: compiledMap}
 	return pwc
 }
 
@@ -162,3 +162,23 @@ func TestProvisionWatcherCache_UpdateAdminState(t *testing.T) {
 		t.Error("succeeded in executing UpdateAdminState, but the value of AdminState was not updated")
 	}
 }
+
+func TestProvisionWatcherCache_Compiled(t *testing.T) {
+	pwc := newProvisionWatcherCache(pws)
+
+	invalid := dtos.ToProvisionWatcherModel(mock.NewProvisionWatcher)
+	invalid.Identifiers = map[string]string{"Address": "range:10"}
+	if err := pwc.Add(invalid); err == nil {
+		t.Error("supposed to get an error when adding a watcher with an invalid rule to cache")
+	}
+	assert.Len(t, pwc.Compiled(), len(pwc.All()))
+
+	if err := pwc.UpdateAdminState(mock.ValidBooleanWatcher.Id, models.Locked); err != nil {
+		t.Error("failed to update AdminState")
+	}
+	for _, w := range pwc.Compiled() {
+		if w.Name == mock.ValidBooleanWatcher.Name {
+			assert.Equal(t, models.AdminState(models.Locked), w.AdminState)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/provision"
+	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
+)
+
+// Snapshot is the content of the caches persisted to disk, so that the device service can
+// start while core metadata is unavailable.
+type Snapshot struct {
+	DeviceService     dtos.DeviceService      `json:"deviceService"`
+	Devices           []dtos.Device           `json:"devices"`
+	Profiles          []dtos.DeviceProfile    `json:"profiles"`
+	ProvisionWatchers []dtos.ProvisionWatcher `json:"provisionWatchers"`
+}
+
+var (
+	// snapshotCh signals a change of the caches, the pending changes are coalesced
+	snapshotCh      = make(chan struct{}, 1)
+	snapshotService models.DeviceService
+	snapshotStarted bool
+	snapshotMutex   sync.Mutex
+)
+
+func snapshotChanged() {
+	select {
+	case snapshotCh <- struct{}{}:
+	default:
+	}
+}
+
+// PersistSnapshot writes the caches and the DeviceService to the file after every change of
+// the caches until the context is done. Calling it again only replaces the DeviceService.
+func PersistSnapshot(ctx context.Context, wg *sync.WaitGroup, path string, service models.DeviceService, lc logger.LoggingClient) {
+	snapshotMutex.Lock()
+	started := snapshotStarted
+	snapshotService, snapshotStarted = service, true
+	snapshotMutex.Unlock()
+
+	snapshotChanged()
+	if started {
+		return
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-snapshotCh:
+				if err := writeSnapshot(path); err != nil {
+					lc.Error(fmt.Sprintf("failed to persist the cache snapshot to %s: %v", path, err))
+				}
+			}
+		}
+	}()
+}
+
+func writeSnapshot(path string) error {
+	snapshotMutex.Lock()
+	service := snapshotService
+	snapshotMutex.Unlock()
+
+	s := Snapshot{DeviceService: dtos.FromDeviceServiceModelToDTO(service)}
+	for _, d := range Devices().All() {
+		s.Devices = append(s.Devices, dtos.FromDeviceModelToDTO(d))
+	}
+	for _, p := range Profiles().All() {
+		s.Profiles = append(s.Profiles, dtos.FromDeviceProfileModelToDTO(p))
+	}
+	for _, pw := range ProvisionWatchers().All() {
+		s.ProvisionWatchers = append(s.ProvisionWatchers, dtos.FromProvisionWatcherModelToDTO(pw))
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	// replace the snapshot atomically so that a crash never leaves a truncated file
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// InitCacheFromSnapshot initializes the caches with the snapshot persisted to the file and
+// returns the DeviceService of the snapshot. The profiles and provision watchers of the
+// snapshot are validated as the ones loaded by InitCache.
+func InitCacheFromSnapshot(path string, lc logger.LoggingClient) (models.DeviceService, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return models.DeviceService{}, err
+	}
+	var s Snapshot
+	if err = json.Unmarshal(data, &s); err != nil {
+		return models.DeviceService{}, fmt.Errorf("invalid cache snapshot %s: %v", path, err)
+	}
+
+	initOnce.Do(func() {
+		var devices []models.Device
+		for _, d := range s.Devices {
+			devices = append(devices, dtos.ToDeviceModel(d))
+		}
+		newDeviceCache(devices)
+
+		var profiles []models.DeviceProfile
+		for _, p := range s.Profiles {
+			profiles = append(profiles, dtos.ToDeviceProfileModel(p))
+		}
+		newProfileCache(profiles)
+		removeInvalidProfiles(lc)
+
+		var watchers []models.ProvisionWatcher
+		for _, dto := range s.ProvisionWatchers {
+			pw := dtos.ToProvisionWatcherModel(dto)
+			if _, err := provision.Compile(pw); err != nil {
+				lc.Error(err.Error())
+				continue
+			}
+			watchers = append(watchers, pw)
+		}
+		newProvisionWatcherCache(watchers)
+	})
+	return dtos.ToDeviceServiceModel(s.DeviceService), nil
+}
@@ -0,0 +1,67 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache.json")
+
+	valid := models.DeviceProfile{Name: "snapshot-valid", DeviceResources: []models.DeviceResource{
+		{Name: "status", Properties: models.PropertyValue{Type: contracts.ValueTypeUint8}},
+		{Name: "bit0", Properties: models.PropertyValue{Type: contracts.ValueTypeBool}, Attributes: map[string]string{common.AttributeDerivedFrom: "status"}},
+	}}
+	invalid := models.DeviceProfile{Name: "snapshot-invalid", DeviceResources: []models.DeviceResource{
+		{Name: "status", Properties: models.PropertyValue{Type: contracts.ValueTypeUint8}},
+		{Name: "status", Properties: models.PropertyValue{Type: contracts.ValueTypeUint16}},
+	}}
+	newDeviceCache(ds)
+	newProfileCache([]models.DeviceProfile{valid, invalid})
+	newProvisionWatcherCache([]models.ProvisionWatcher{
+		{Name: "watcher", ServiceName: "service"},
+		{Name: "invalid-watcher", ServiceName: "service", Identifiers: map[string]string{"Address": "("}},
+	})
+	snapshotService = models.DeviceService{Name: "service"}
+	require.NoError(t, writeSnapshot(path))
+
+	newDeviceCache(nil)
+	newProfileCache(nil)
+	newProvisionWatcherCache(nil)
+	initOnce = sync.Once{}
+	svc, err := InitCacheFromSnapshot(path, logger.NewMockClient())
+	require.NoError(t, err)
+	assert.Equal(t, "service", svc.Name)
+	assert.Equal(t, len(ds), len(Devices().All()))
+	_, ok := Profiles().ForName(valid.Name)
+	assert.True(t, ok)
+	_, ok = Profiles().ForName(invalid.Name)
+	assert.False(t, ok, "the profiles of the snapshot are validated")
+	_, ok = ProvisionWatchers().ForName("watcher")
+	assert.True(t, ok)
+	_, ok = ProvisionWatchers().ForName("invalid-watcher")
+	assert.False(t, ok, "the provision watchers of the snapshot are compiled")
+
+	_, err = InitCacheFromSnapshot(filepath.Join(dir, "missing.json"), logger.NewMockClient())
+	assert.Error(t, err)
+}
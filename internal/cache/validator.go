@@ -0,0 +1,329 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
+)
+
+// valueTypes maps the lower case value types to their names, value types are case insensitive
+var valueTypes = make(map[string]string)
+
+func init() {
+	for _, t := range []string{
+		contracts.ValueTypeBool, contracts.ValueTypeString, contracts.ValueTypeBinary,
+		contracts.ValueTypeUint8, contracts.ValueTypeUint16, contracts.ValueTypeUint32, contracts.ValueTypeUint64,
+		contracts.ValueTypeInt8, contracts.ValueTypeInt16, contracts.ValueTypeInt32, contracts.ValueTypeInt64,
+		contracts.ValueTypeFloat32, contracts.ValueTypeFloat64,
+		contracts.ValueTypeBoolArray, contracts.ValueTypeStringArray,
+		contracts.ValueTypeUint8Array, contracts.ValueTypeUint16Array, contracts.ValueTypeUint32Array, contracts.ValueTypeUint64Array,
+		contracts.ValueTypeInt8Array, contracts.ValueTypeInt16Array, contracts.ValueTypeInt32Array, contracts.ValueTypeInt64Array,
+		contracts.ValueTypeFloat32Array, contracts.ValueTypeFloat64Array,
+	} {
+		valueTypes[strings.ToLower(t)] = t
+	}
+}
+
+// ValidateProfile checks the semantics of the profile before it's accepted into the cache.
+// The conflicts found are reported in the returned error, the warnings are logged.
+func ValidateProfile(profile models.DeviceProfile, lc logger.LoggingClient) errors.EdgeX {
+	conflicts, warnings := ProfileIssues(profile)
+	if len(warnings) > 0 {
+		lc.Warn(fmt.Sprintf("profile %s: %s", profile.Name, strings.Join(warnings, "; ")))
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	errMsg := fmt.Sprintf("profile %s is invalid: %s", profile.Name, strings.Join(conflicts, "; "))
+	return errors.NewCommonEdgeX(errors.KindContractInvalid, errMsg, nil)
+}
+
+// ProfileIssues returns the conflicts of the profile, which make it ambiguous, then the
+// warnings, which only fail the commands or readings they concern. The conflicts are:
+//   - duplicated deviceResource, deviceCommand and coreCommand names
+//   - mappings of a resource which contradict each other
+//
+// The warnings are:
+//   - resourceOperations, coreCommands and derived resources referencing missing resources
+//   - bit ranges of derived resources out of their integer parent or their own type
+//   - Get operations of write-only resources and Set operations of read-only resources
+//   - unknown value types and readWrite values
+//   - mask, shift, base, scale, offset, minimum and maximum which are not numbers, and
+//     a minimum greater than the maximum
+//   - invalid mapping rules
+func ProfileIssues(profile models.DeviceProfile) ([]string, []string) {
+	var conflicts, warnings []string
+	conflict := func(format string, args ...interface{}) {
+		conflicts = append(conflicts, fmt.Sprintf(format, args...))
+	}
+	warn := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	resources := make(map[string]models.DeviceResource, len(profile.DeviceResources))
+	for _, dr := range profile.DeviceResources {
+		if _, ok := resources[dr.Name]; ok {
+			conflict("deviceResource %s is duplicated", dr.Name)
+		}
+		resources[dr.Name] = dr
+	}
+	commands := make(map[string]models.ProfileResource, len(profile.DeviceCommands))
+	for _, pr := range profile.DeviceCommands {
+		if _, ok := commands[pr.Name]; ok {
+			conflict("deviceCommand %s is duplicated", pr.Name)
+		}
+		commands[pr.Name] = pr
+	}
+	coreCommands := make(map[string]bool, len(profile.CoreCommands))
+	for _, c := range profile.CoreCommands {
+		if coreCommands[c.Name] {
+			conflict("coreCommand %s is duplicated", c.Name)
+		}
+		coreCommands[c.Name] = true
+	}
+
+	for _, dr := range profile.DeviceResources {
+		warnings = append(warnings, deviceResourceIssues(dr, resources)...)
+	}
+
+	// the mappings by resource, to find the contradicting ones
+	getMappings := make(map[string]map[string]string)
+	setMappings := make(map[string]map[string]string)
+	for _, pr := range profile.DeviceCommands {
+		for _, ro := range pr.Get {
+			c, w := resourceOperationIssues(pr.Name, "Get", ro, resources, getMappings)
+			conflicts, warnings = append(conflicts, c...), append(warnings, w...)
+		}
+		for _, ro := range pr.Set {
+			c, w := resourceOperationIssues(pr.Name, "Set", ro, resources, setMappings)
+			conflicts, warnings = append(conflicts, c...), append(warnings, w...)
+		}
+	}
+	for _, dr := range profile.DeviceResources {
+		for _, value := range sortedKeys(setMappings[dr.Name]) {
+			raw := setMappings[dr.Name][value]
+			if mapped, ok := getMappings[dr.Name][raw]; ok && mapped != value {
+				conflict("Set mapping of deviceResource %s maps %s to %s but Get mapping maps %s to %s", dr.Name, value, raw, raw, mapped)
+			}
+		}
+	}
+
+	for _, c := range profile.CoreCommands {
+		if dr, ok := resources[c.Name]; ok {
+			if c.Get && dr.Properties.ReadWrite == common.DeviceResourceWriteOnly {
+				warn("coreCommand %s has Get but deviceResource %s is write-only", c.Name, dr.Name)
+			}
+			if c.Put && dr.Properties.ReadWrite == common.DeviceResourceReadOnly {
+				warn("coreCommand %s has Put but deviceResource %s is read-only", c.Name, dr.Name)
+			}
+		} else if pr, ok := commands[c.Name]; ok {
+			if c.Get && len(pr.Get) == 0 {
+				warn("coreCommand %s has Get but deviceCommand %s has no Get operation", c.Name, pr.Name)
+			}
+			if c.Put && len(pr.Set) == 0 {
+				warn("coreCommand %s has Put but deviceCommand %s has no Set operation", c.Name, pr.Name)
+			}
+		} else {
+			warn("coreCommand %s references no deviceCommand or deviceResource", c.Name)
+		}
+	}
+	return conflicts, warnings
+}
+
+func deviceResourceIssues(dr models.DeviceResource, resources map[string]models.DeviceResource) []string {
+	var issues []string
+	report := func(format string, args ...interface{}) {
+		issues = append(issues, fmt.Sprintf("deviceResource %s: "+format, append([]interface{}{dr.Name}, args...)...))
+	}
+
+	pv := dr.Properties
+	// an empty value type is taken as Float64
+	if _, ok := valueTypes[strings.ToLower(pv.Type)]; !ok && pv.Type != "" {
+		report("unknown value type %q", pv.Type)
+	}
+	switch pv.ReadWrite {
+	case common.DeviceResourceReadOnly, common.DeviceResourceWriteOnly, "RW", "WR", "":
+	default:
+		report("unknown readWrite %q", pv.ReadWrite)
+	}
+
+	if pv.Mask != "" {
+		if _, err := strconv.ParseUint(pv.Mask, 10, 64); err != nil {
+			report("mask %q is not an unsigned integer", pv.Mask)
+		}
+	}
+	if pv.Shift != "" {
+		if _, err := strconv.ParseInt(pv.Shift, 10, 64); err != nil {
+			report("shift %q is not an integer", pv.Shift)
+		}
+	}
+	for _, p := range []struct{ name, value string }{{"base", pv.Base}, {"scale", pv.Scale}, {"offset", pv.Offset}} {
+		if p.value == "" {
+			continue
+		}
+		if _, err := strconv.ParseFloat(p.value, 64); err != nil {
+			report("%s %q is not a number", p.name, p.value)
+		}
+	}
+
+	min, minErr := strconv.ParseFloat(pv.Minimum, 64)
+	if pv.Minimum != "" && minErr != nil {
+		report("minimum %q is not a number", pv.Minimum)
+	}
+	max, maxErr := strconv.ParseFloat(pv.Maximum, 64)
+	if pv.Maximum != "" && maxErr != nil {
+		report("maximum %q is not a number", pv.Maximum)
+	}
+	if pv.Minimum != "" && pv.Maximum != "" && minErr == nil && maxErr == nil && min > max {
+		report("minimum %s is greater than maximum %s", pv.Minimum, pv.Maximum)
+	}
+
+	if names, ok := dr.Attributes[common.AttributeTransform]; ok {
+		for _, name := range strings.Split(names, ",") {
+			if strings.TrimSpace(name) == "" {
+				report("empty name in %s %q", common.AttributeTransform, names)
+				break
+			}
+		}
+	}
+	if parent, ok := dr.Attributes[common.AttributeDerivedFrom]; ok {
+		if parentDR, exists := resources[parent]; !exists {
+			report("%s references missing deviceResource %s", common.AttributeDerivedFrom, parent)
+		} else if _, isJSON := dr.Attributes[common.AttributeJSONPath]; !isJSON {
+			issues = append(issues, bitFieldIssues(dr, parentDR)...)
+		}
+	}
+	return issues
+}
+
+// bitFieldIssues checks the ds-bitOffset and ds-bitLength of a derived resource against the width
+// of its integer parent, and the width of its own integer type.
+func bitFieldIssues(dr models.DeviceResource, parent models.DeviceResource) []string {
+	var issues []string
+	report := func(format string, args ...interface{}) {
+		issues = append(issues, fmt.Sprintf("deviceResource %s: "+format, append([]interface{}{dr.Name}, args...)...))
+	}
+
+	parentWidth, ok := IntegerWidth(parent.Properties.Type)
+	if !ok {
+		// the fields of packed structs are described by their layout
+		return nil
+	}
+	offset, length := uint64(0), uint64(1)
+	var err error
+	if v, ok := dr.Attributes[common.AttributeBitOffset]; ok {
+		if offset, err = strconv.ParseUint(v, 10, 8); err != nil {
+			report("%s %q is not an unsigned integer", common.AttributeBitOffset, v)
+			return issues
+		}
+	}
+	if v, ok := dr.Attributes[common.AttributeBitLength]; ok {
+		if length, err = strconv.ParseUint(v, 10, 8); err != nil || length == 0 {
+			report("%s %q is not a positive integer", common.AttributeBitLength, v)
+			return issues
+		}
+	}
+	if offset+length > uint64(parentWidth) {
+		report("bit range [%d, %d) is out of the %d bits of deviceResource %s", offset, offset+length, parentWidth, parent.Name)
+	}
+	if width, ok := IntegerWidth(dr.Properties.Type); ok && width > 1 && length > uint64(width) {
+		report("%s %d exceeds the %d bits of its type %s", common.AttributeBitLength, length, width, dr.Properties.Type)
+	}
+	return issues
+}
+
+// IntegerWidth returns the number of bits of the integer and Bool value types.
+func IntegerWidth(valueType string) (uint, bool) {
+	switch valueTypes[strings.ToLower(valueType)] {
+	case contracts.ValueTypeBool:
+		return 1, true
+	case contracts.ValueTypeUint8, contracts.ValueTypeInt8:
+		return 8, true
+	case contracts.ValueTypeUint16, contracts.ValueTypeInt16:
+		return 16, true
+	case contracts.ValueTypeUint32, contracts.ValueTypeInt32:
+		return 32, true
+	case contracts.ValueTypeUint64, contracts.ValueTypeInt64:
+		return 64, true
+	}
+	return 0, false
+}
+
+func resourceOperationIssues(
+	command string,
+	method string,
+	ro models.ResourceOperation,
+	resources map[string]models.DeviceResource,
+	mappingsByResource map[string]map[string]string) ([]string, []string) {
+	var conflicts, warnings []string
+	conflict := func(format string, args ...interface{}) {
+		conflicts = append(conflicts, fmt.Sprintf("deviceCommand %s %s: "+format, append([]interface{}{command, method}, args...)...))
+	}
+	report := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf("deviceCommand %s %s: "+format, append([]interface{}{command, method}, args...)...))
+	}
+
+	dr, ok := resources[ro.DeviceResource]
+	if !ok {
+		report("references missing deviceResource %s", ro.DeviceResource)
+		return conflicts, warnings
+	}
+	if method == "Get" && dr.Properties.ReadWrite == common.DeviceResourceWriteOnly {
+		report("reads write-only deviceResource %s", dr.Name)
+	}
+	if method == "Set" && dr.Properties.ReadWrite == common.DeviceResourceReadOnly {
+		report("writes read-only deviceResource %s", dr.Name)
+	}
+
+	mappings, ok := mappingsByResource[dr.Name]
+	if !ok {
+		mappings = make(map[string]string)
+		mappingsByResource[dr.Name] = mappings
+	}
+	for _, k := range sortedKeys(ro.Mappings) {
+		v := ro.Mappings[k]
+		if strings.HasPrefix(k, common.SDKReservedPrefix) {
+			continue
+		}
+		if len(k) >= 2 && strings.HasPrefix(k, "/") && strings.HasSuffix(k, "/") {
+			if _, err := regexp.Compile(k[1 : len(k)-1]); err != nil {
+				report("invalid mapping regular expression %s: %v", k, err)
+			}
+			continue
+		}
+		if _, _, ok, err := common.ParseMappingRange(k); err != nil {
+			report("%v", err)
+			continue
+		} else if ok {
+			continue
+		}
+		if previous, ok := mappings[k]; ok && previous != v {
+			conflict("maps %s to %s but another %s operation maps it to %s", k, v, method, previous)
+		}
+		mappings[k] = v
+	}
+	return conflicts, warnings
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,156 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
+)
+
+func validProfile() models.DeviceProfile {
+	return models.DeviceProfile{
+		Name: "profile",
+		DeviceResources: []models.DeviceResource{
+			{Name: "switch", Properties: models.PropertyValue{Type: contracts.ValueTypeInt8, ReadWrite: "RW", Minimum: "0", Maximum: "1"}},
+			{Name: "status", Properties: models.PropertyValue{Type: contracts.ValueTypeUint16, ReadWrite: "R", Scale: "0.1"}},
+			{Name: "alarm", Properties: models.PropertyValue{Type: contracts.ValueTypeBool, ReadWrite: "R"},
+				Attributes: map[string]string{common.AttributeDerivedFrom: "status"}},
+		},
+		DeviceCommands: []models.ProfileResource{
+			{
+				Name: "switch",
+				Get:  []models.ResourceOperation{{DeviceResource: "switch", Mappings: map[string]string{"0": "off", "1": "on"}}},
+				Set:  []models.ResourceOperation{{DeviceResource: "switch", Mappings: map[string]string{"off": "0", "on": "1"}}},
+			},
+			{
+				Name: "state",
+				Get:  []models.ResourceOperation{{DeviceResource: "status", Mappings: map[string]string{"0..10": "low", "/^9+$/": "max"}}},
+			},
+		},
+		CoreCommands: []models.Command{{Name: "switch", Get: true, Put: true}, {Name: "state", Get: true}, {Name: "alarm", Get: true}},
+	}
+}
+
+func TestValidateProfile(t *testing.T) {
+	lc := logger.NewMockClient()
+	conflicts, warnings := ProfileIssues(validProfile())
+	assert.Empty(t, conflicts)
+	assert.Empty(t, warnings)
+	assert.NoError(t, ValidateProfile(validProfile(), lc))
+
+	tests := []struct {
+		name     string
+		modify   func(p *models.DeviceProfile)
+		conflict bool
+		expected string
+	}{
+		{"duplicated resource", func(p *models.DeviceProfile) {
+			p.DeviceResources = append(p.DeviceResources, p.DeviceResources[0])
+		}, true, "deviceResource switch is duplicated"},
+		{"duplicated core command", func(p *models.DeviceProfile) {
+			p.CoreCommands = append(p.CoreCommands, p.CoreCommands[0])
+		}, true, "coreCommand switch is duplicated"},
+		{"contradicting mappings", func(p *models.DeviceProfile) {
+			p.DeviceCommands[0].Set[0].Mappings["on"] = "0"
+		}, true, "Set mapping of deviceResource switch maps on to 0 but Get mapping maps 0 to off"},
+		{"contradicting operations", func(p *models.DeviceProfile) {
+			p.DeviceCommands = append(p.DeviceCommands, models.ProfileResource{
+				Name: "switch2",
+				Get:  []models.ResourceOperation{{DeviceResource: "switch", Mappings: map[string]string{"0": "closed"}}},
+			})
+		}, true, "deviceCommand switch2 Get: maps 0 to closed but another Get operation maps it to off"},
+		{"dangling resource operation", func(p *models.DeviceProfile) {
+			p.DeviceCommands[1].Get[0].DeviceResource = "missing"
+		}, false, "deviceCommand state Get: references missing deviceResource missing"},
+		{"dangling core command", func(p *models.DeviceProfile) {
+			p.CoreCommands[1].Name = "missing"
+		}, false, "coreCommand missing references no deviceCommand or deviceResource"},
+		{"dangling derived resource", func(p *models.DeviceProfile) {
+			p.DeviceResources[2].Attributes[common.AttributeDerivedFrom] = "missing"
+		}, false, "deviceResource alarm: ds-derivedFrom references missing deviceResource missing"},
+		{"bit range out of parent", func(p *models.DeviceProfile) {
+			p.DeviceResources[2].Attributes[common.AttributeBitOffset] = "15"
+			p.DeviceResources[2].Attributes[common.AttributeBitLength] = "2"
+		}, false, "deviceResource alarm: bit range [15, 17) is out of the 16 bits of deviceResource status"},
+		{"bit range out of a parent with a lower case type", func(p *models.DeviceProfile) {
+			p.DeviceResources[1].Properties.Type = "uint8"
+			p.DeviceResources[2].Attributes[common.AttributeBitOffset] = "8"
+		}, false, "deviceResource alarm: bit range [8, 9) is out of the 8 bits of deviceResource status"},
+		{"bit length exceeds own type", func(p *models.DeviceProfile) {
+			p.DeviceResources[2].Properties.Type = contracts.ValueTypeUint8
+			p.DeviceResources[2].Attributes[common.AttributeBitLength] = "9"
+		}, false, "deviceResource alarm: ds-bitLength 9 exceeds the 8 bits of its type Uint8"},
+		{"invalid bit length", func(p *models.DeviceProfile) {
+			p.DeviceResources[2].Attributes[common.AttributeBitLength] = "0"
+		}, false, `deviceResource alarm: ds-bitLength "0" is not a positive integer`},
+		{"write read-only resource", func(p *models.DeviceProfile) {
+			p.DeviceCommands[1].Set = []models.ResourceOperation{{DeviceResource: "status"}}
+		}, false, "deviceCommand state Set: writes read-only deviceResource status"},
+		{"put read-only resource", func(p *models.DeviceProfile) {
+			p.CoreCommands[2].Put = true
+		}, false, "coreCommand alarm has Put but deviceResource alarm is read-only"},
+		{"put without set operation", func(p *models.DeviceProfile) {
+			p.CoreCommands[1].Put = true
+		}, false, "coreCommand state has Put but deviceCommand state has no Set operation"},
+		{"unknown value type", func(p *models.DeviceProfile) {
+			p.DeviceResources[0].Properties.Type = "Int9"
+		}, false, `deviceResource switch: unknown value type "Int9"`},
+		{"invalid scale", func(p *models.DeviceProfile) {
+			p.DeviceResources[1].Properties.Scale = "x0.1"
+		}, false, `deviceResource status: scale "x0.1" is not a number`},
+		{"minimum greater than maximum", func(p *models.DeviceProfile) {
+			p.DeviceResources[0].Properties.Minimum = "2"
+		}, false, "deviceResource switch: minimum 2 is greater than maximum 1"},
+		{"empty transform name", func(p *models.DeviceProfile) {
+			p.DeviceResources[0].Attributes = map[string]string{common.AttributeTransform: "celsius,"}
+		}, false, `deviceResource switch: empty name in ds-transform "celsius,"`},
+		{"invalid mapping range", func(p *models.DeviceProfile) {
+			p.DeviceCommands[1].Get[0].Mappings = map[string]string{"10..0": "low"}
+		}, false, "deviceCommand state Get: invalid mapping rule 10..0: lower bound greater than upper bound"},
+		{"invalid mapping regex", func(p *models.DeviceProfile) {
+			p.DeviceCommands[1].Get[0].Mappings = map[string]string{"/(/": "error"}
+		}, false, "deviceCommand state Get: invalid mapping regular expression /(/"},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			profile := validProfile()
+			testCase.modify(&profile)
+			conflicts, warnings := ProfileIssues(profile)
+			err := ValidateProfile(profile, lc)
+			if !testCase.conflict {
+				assert.Empty(t, conflicts)
+				assert.Contains(t, strings.Join(warnings, "; "), testCase.expected)
+				assert.NoError(t, err, "a warning doesn't reject the profile")
+				return
+			}
+			assert.Contains(t, strings.Join(conflicts, "; "), testCase.expected)
+			if assert.Error(t, err) {
+				assert.Equal(t, errors.KindContractInvalid, errors.Kind(err))
+				assert.Contains(t, err.Error(), testCase.expected)
+			}
+		})
+	}
+}
+
+func TestProfileIssues_ValueTypes(t *testing.T) {
+	profile := validProfile()
+	// the value types are case insensitive and an empty value type is taken as Float64
+	profile.DeviceResources[0].Properties.Type = "int8"
+	profile.DeviceResources[1].Properties.Type = "UINT16"
+	profile.DeviceResources = append(profile.DeviceResources, models.DeviceResource{Name: "temperature", Properties: models.PropertyValue{ReadWrite: "R"}})
+	conflicts, warnings := ProfileIssues(profile)
+	assert.Empty(t, conflicts)
+	assert.Empty(t, warnings)
+}
@@ -9,6 +9,9 @@ package callback
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 
 	bootstrapContainer "github.com/edgexfoundry/go-mod-bootstrap/v2/bootstrap/container"
 	"github.com/edgexfoundry/go-mod-bootstrap/v2/di"
@@ -16,29 +19,97 @@ import (
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/requests"
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/autoevent"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/computed"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/eventbus"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/transformer"
 	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
 )
 
-func UpdateProfile(profileRequest requests.DeviceProfileRequest, lc logger.LoggingClient) errors.EdgeX {
-	_, ok := cache.Profiles().ForName(profileRequest.Profile.Name)
+func UpdateProfile(profileRequest requests.DeviceProfileRequest, dic *di.Container) errors.EdgeX {
+	lc := bootstrapContainer.LoggingClientFrom(dic.Get)
+	before, ok := cache.Profiles().Resolved(profileRequest.Profile.Name)
 	if !ok {
 		errMsg := fmt.Sprintf("failed to find profile %s", profileRequest.Profile.Name)
 		return errors.NewCommonEdgeX(errors.KindInvalidId, errMsg, nil)
 	}
 
-	err := cache.Profiles().Update(dtos.ToDeviceProfileModel(profileRequest.Profile))
+	profile := dtos.ToDeviceProfileModel(profileRequest.Profile)
+	if err := validateResolvedProfile(profile, lc); err != nil {
+		return err
+	}
+	dependents := resolvedProfiles(cache.Profiles().Dependents(profile.Name))
+	err := cache.Profiles().Update(profile)
 	if err != nil {
 		errMsg := fmt.Sprintf("failed to update profile %s", profileRequest.Profile.Name)
 		return errors.NewCommonEdgeX(errors.KindServerError, errMsg, err)
 	}
 
 	lc.Debug(fmt.Sprintf("profile %s updated", profileRequest.Profile.Name))
+	after, _ := cache.Profiles().Resolved(profile.Name)
+	profileUpdated(before, after, dic)
+	dependentsUpdated(dependents, dic)
 	return nil
 }
 
+// validateResolvedProfile validates the profile flattened with its base and included profiles.
+func validateResolvedProfile(profile models.DeviceProfile, lc logger.LoggingClient) errors.EdgeX {
+	resolved, err := cache.Profiles().Resolve(profile)
+	if err != nil {
+		return err
+	}
+	return validateProfile(resolved, lc)
+}
+
+// validateProfile validates the resolved profile, including the custom transforms its
+// DeviceResources refer to, which the cache can't check against the transformer registry.
+func validateProfile(profile models.DeviceProfile, lc logger.LoggingClient) errors.EdgeX {
+	if err := cache.ValidateProfile(profile, lc); err != nil {
+		return err
+	}
+	var issues []string
+	for _, dr := range profile.DeviceResources {
+		for _, name := range transformer.UnregisteredTransforms(dr) {
+			issues = append(issues, fmt.Sprintf("deviceResource %s: transform %s is not registered", dr.Name, name))
+		}
+	}
+	if len(issues) > 0 {
+		errMsg := fmt.Sprintf("profile %s is invalid: %s", profile.Name, strings.Join(issues, "; "))
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, errMsg, nil)
+	}
+	return nil
+}
+
+func resolvedProfiles(names []string) []models.DeviceProfile {
+	profiles := make([]models.DeviceProfile, 0, len(names))
+	for _, name := range names {
+		if profile, ok := cache.Profiles().Resolved(name); ok {
+			profiles = append(profiles, profile)
+		}
+	}
+	return profiles
+}
+
+// dependentsUpdated handles the profiles extending or including an updated profile like
+// updated profiles, the ones which are no longer valid once resolved are reported.
+func dependentsUpdated(before []models.DeviceProfile, dic *di.Container) {
+	lc := bootstrapContainer.LoggingClientFrom(dic.Get)
+	for _, dependent := range before {
+		after, ok := cache.Profiles().Resolved(dependent.Name)
+		if !ok || reflect.DeepEqual(dependent, after) {
+			continue
+		}
+		if err := validateProfile(after, lc); err != nil {
+			lc.Warn(err.Error())
+		}
+		profileUpdated(dependent, after, dic)
+	}
+}
+
 func AddDevice(addDeviceRequest requests.AddDeviceRequest, dic *di.Container) errors.EdgeX {
 	device := dtos.ToDeviceModel(addDeviceRequest.Device)
 	lc := bootstrapContainer.LoggingClientFrom(dic.Get)
@@ -48,6 +119,9 @@ func AddDevice(addDeviceRequest requests.AddDeviceRequest, dic *di.Container) er
 		errMsg := fmt.Sprintf("failed to update device profile %s", device.ProfileName)
 		return errors.NewCommonEdgeX(errors.KindServerError, errMsg, edgexErr)
 	}
+	if edgexErr = cache.ValidateOverrides(device); edgexErr != nil {
+		return edgexErr
+	}
 
 	edgexErr = cache.Devices().Add(device)
 	if edgexErr != nil {
@@ -55,9 +129,10 @@ func AddDevice(addDeviceRequest requests.AddDeviceRequest, dic *di.Container) er
 		return errors.NewCommonEdgeX(errors.KindServerError, errMsg, edgexErr)
 	}
 	lc.Debug(fmt.Sprintf("device %s added", device.Name))
+	eventbus.PublishDeviceChange(nil, &device)
 
 	driver := container.ProtocolDriverFrom(dic.Get)
-	err := driver.AddDevice(device.Name, device.Protocols, device.AdminState)
+	err := driver.AddDevice(device.Name, cache.WithoutOverrides(device.Protocols), device.AdminState)
 	if err == nil {
 		lc.Debug(fmt.Sprintf("Invoked driver.AddDevice callback for %s", device.Name))
 	} else {
@@ -79,6 +154,7 @@ func UpdateDevice(updateDeviceRequest requests.UpdateDeviceRequest, dic *di.Cont
 		return errors.NewCommonEdgeX(errors.KindInvalidId, errMsg, nil)
 	}
 
+	before := device
 	requests.ReplaceDeviceModelFieldsWithDTO(&device, updateDeviceRequest.Device)
 	// TODO: uncomment when core-contracts v2 client is ready.
 	edgexErr := updateAssociatedProfile(device.ProfileName, dic)
@@ -86,6 +162,9 @@ func UpdateDevice(updateDeviceRequest requests.UpdateDeviceRequest, dic *di.Cont
 		errMsg := fmt.Sprintf("failed to update device profile %s", device.ProfileName)
 		return errors.NewCommonEdgeX(errors.KindServerError, errMsg, edgexErr)
 	}
+	if edgexErr = cache.ValidateOverrides(device); edgexErr != nil {
+		return edgexErr
+	}
 
 	edgexErr = cache.Devices().Update(device)
 	if edgexErr != nil {
@@ -93,9 +172,10 @@ func UpdateDevice(updateDeviceRequest requests.UpdateDeviceRequest, dic *di.Cont
 		return errors.NewCommonEdgeX(errors.KindServerError, errMsg, edgexErr)
 	}
 	lc.Debug(fmt.Sprintf("device %s updated", device.Name))
+	eventbus.PublishDeviceChange(&before, &device)
 
 	driver := container.ProtocolDriverFrom(dic.Get)
-	err := driver.UpdateDevice(device.Name, device.Protocols, device.AdminState)
+	err := driver.UpdateDevice(device.Name, cache.WithoutOverrides(device.Protocols), device.AdminState)
 	if err == nil {
 		lc.Debug(fmt.Sprintf("Invoked driver.UpdateDevice callback for %s", device.Name))
 	} else {
@@ -127,9 +207,12 @@ func DeleteDevice(name string, dic *di.Container) errors.EdgeX {
 		return errors.NewCommonEdgeX(errors.KindServerError, errMsg, edgexErr)
 	}
 	lc.Debugf("Removed device: %s", device.Name)
+	computed.Forget(device.Name)
+	transformer.ResetAssertions(device.Name)
+	eventbus.PublishDeviceChange(&device, nil)
 
 	driver := container.ProtocolDriverFrom(dic.Get)
-	err := driver.RemoveDevice(device.Name, device.Protocols)
+	err := driver.RemoveDevice(device.Name, cache.WithoutOverrides(device.Protocols))
 	if err == nil {
 		lc.Debugf("Invoked driver.RemoveDevice callback for %s", device.Name)
 	} else {
@@ -143,9 +226,12 @@ func DeleteDevice(name string, dic *di.Container) errors.EdgeX {
 	// device profile in cache so that if it is updated in metadata, next time the
 	// device using it is added/updated, the cache can receive the updated one as well.
 	if cache.CheckProfileNotUsed(device.ProfileName) {
+		profile, _ := cache.Profiles().Resolved(device.ProfileName)
 		edgexErr = cache.Profiles().RemoveByName(device.ProfileName)
 		if edgexErr != nil {
 			lc.Warn("failed to remove unused profile", edgexErr.DebugMessages())
+		} else {
+			eventbus.PublishProfileChange(&profile, nil)
 		}
 	}
 
@@ -162,6 +248,7 @@ func AddProvisionWatcher(addProvisionWatcherRequest requests.AddProvisionWatcher
 	}
 
 	lc.Debugf("provision watcher %s added", provisionWatcher.Name)
+	eventbus.PublishProvisionWatcherChange(nil, &provisionWatcher)
 	return nil
 }
 
@@ -172,6 +259,7 @@ func UpdateProvisionWatcher(updateProvisionWatcherRequest requests.UpdateProvisi
 		return errors.NewCommonEdgeX(errors.KindInvalidId, errMsg, nil)
 	}
 
+	before := provisionWatcher
 	requests.ReplaceProvisionWatcherModelFieldsWithDTO(&provisionWatcher, updateProvisionWatcherRequest.ProvisionWatcher)
 
 	edgexErr := cache.ProvisionWatchers().Update(provisionWatcher)
@@ -181,10 +269,12 @@ func UpdateProvisionWatcher(updateProvisionWatcherRequest requests.UpdateProvisi
 	}
 
 	lc.Debugf("provision watcher %s updated", provisionWatcher.Name)
+	eventbus.PublishProvisionWatcherChange(&before, &provisionWatcher)
 	return nil
 }
 
 func DeleteProvisionWatcher(name string, lc logger.LoggingClient) errors.EdgeX {
+	provisionWatcher, _ := cache.ProvisionWatchers().ForName(name)
 	err := cache.ProvisionWatchers().RemoveByName(name)
 	if err != nil {
 		errMsg := fmt.Sprintf("failed to remove provision watcher %s", name)
@@ -192,6 +282,52 @@ func DeleteProvisionWatcher(name string, lc logger.LoggingClient) errors.EdgeX {
 	}
 
 	lc.Debugf("removed provision watcher %s", name)
+	eventbus.PublishProvisionWatcherChange(&provisionWatcher, nil)
+	return nil
+}
+
+// serviceMutex serializes the updates of the device service, so that concurrent callbacks
+// don't lose each other's update
+var serviceMutex sync.Mutex
+
+// UpdateDeviceService applies the update of the device service received from metadata at
+// runtime. The AutoEvents are stopped while the service is locked and restarted once it's
+// unlocked, the periodic discovery is skipped while locked.
+func UpdateDeviceService(updateDeviceServiceRequest requests.UpdateDeviceServiceRequest, dic *di.Container) errors.EdgeX {
+	serviceMutex.Lock()
+	defer serviceMutex.Unlock()
+
+	lc := bootstrapContainer.LoggingClientFrom(dic.Get)
+	ds := container.DeviceServiceFrom(dic.Get)
+
+	patch := updateDeviceServiceRequest.Service
+	if (patch.Id != nil && *patch.Id != ds.Id) || (patch.Name != nil && *patch.Name != ds.Name) {
+		errMsg := fmt.Sprintf("failed to update device service, only the running device service %s can be updated", ds.Name)
+		return errors.NewCommonEdgeX(errors.KindInvalidId, errMsg, nil)
+	}
+
+	before := ds
+	requests.ReplaceDeviceServiceModelFieldsWithDTO(&ds, patch)
+	dic.Update(di.ServiceConstructorMap{
+		container.DeviceServiceName: func(get di.Get) interface{} {
+			return ds
+		},
+	})
+	lc.Debug(fmt.Sprintf("device service %s updated", ds.Name))
+
+	if before.AdminState != ds.AdminState {
+		if ds.AdminState == models.Locked {
+			lc.Info(fmt.Sprintf("device service %s locked, stopping AutoEvents", ds.Name))
+			autoevent.GetManager().StopAutoEvents()
+		} else {
+			lc.Info(fmt.Sprintf("device service %s unlocked, restarting AutoEvents", ds.Name))
+			for _, device := range cache.Devices().All() {
+				autoevent.GetManager().RestartForDevice(device.Name, dic)
+			}
+		}
+	}
+
+	eventbus.PublishDeviceServiceChange(&before, &ds)
 	return nil
 }
 
@@ -211,22 +347,105 @@ func updateAssociatedProfile(profileName string, dic *di.Container) errors.EdgeX
 		return errors.NewCommonEdgeX(errors.KindInvalidId, errMsg, nil)
 	}
 	fmt.Printf("%+v\n", resp)
-	_, exist := cache.Profiles().ForName(profileName)
+	profile := dtos.ToDeviceProfileModel(resp.Profile)
+
+	// the base and included profiles missing from the cache are needed to resolve the profile
+	known := func(name string) bool {
+		_, ok := cache.Profiles().ForName(name)
+		return ok
+	}
+	referenced, validationErr := cache.FetchProfileReferences(context.Background(), profile, known, dpc)
+	for i := len(referenced) - 1; i >= 0; i-- {
+		if err := cache.Profiles().Add(referenced[i]); err != nil {
+			lc.Warn(fmt.Sprintf("failed to add profile %s referenced by profile %s: %v", referenced[i].Name, profileName, err))
+		}
+	}
+	if validationErr == nil {
+		validationErr = validateResolvedProfile(profile, lc)
+	}
+
+	before, exist := cache.Profiles().Resolved(profileName)
 	if exist == false {
-		err = cache.Profiles().Add(dtos.ToDeviceProfileModel(resp.Profile))
+		if validationErr != nil {
+			return validationErr
+		}
+		err = cache.Profiles().Add(profile)
 		if err == nil {
 			//provision.CreateDescriptorsFromProfile(&profile, lc, gc, vdc)
 			//lc.Info(fmt.Sprintf("Added device profile: %s", profileName))
+			added, _ := cache.Profiles().Resolved(profileName)
+			eventbus.PublishProfileChange(nil, &added)
 		} else {
 			errMsg := fmt.Sprintf("failed to add profile %s", profileName)
 			return errors.NewCommonEdgeX(errors.KindServerError, errMsg, err)
 		}
 	} else {
-		err := cache.Profiles().Update(dtos.ToDeviceProfileModel(resp.Profile))
+		if validationErr != nil {
+			lc.Warn(fmt.Sprintf("%s, using the original one", validationErr.Error()))
+			return nil
+		}
+		dependents := resolvedProfiles(cache.Profiles().Dependents(profileName))
+		err := cache.Profiles().Update(profile)
 		if err != nil {
 			lc.Warn(fmt.Sprintf("failed to to update profile %s in cache, using the original one", profileName))
+		} else if after, _ := cache.Profiles().Resolved(profileName); !reflect.DeepEqual(before, after) {
+			// the profile is refreshed on every device callback, only actual changes are notified
+			profileUpdated(before, after, dic)
+			dependentsUpdated(dependents, dic)
 		}
 	}
 
 	return nil
 }
+
+// profileUpdated warns about the AutoEvents left on removed resources, invokes the
+// ProfileAwareDriver callback and notifies the subscribers, the AutoEvent manager restarts
+// the AutoEvents of the devices using the profile.
+func profileUpdated(before models.DeviceProfile, after models.DeviceProfile, dic *di.Container) {
+	lc := bootstrapContainer.LoggingClientFrom(dic.Get)
+
+	resources := make(map[string]bool, len(after.DeviceResources)+len(after.DeviceCommands))
+	for _, dr := range after.DeviceResources {
+		resources[dr.Name] = true
+	}
+	for _, dc := range after.DeviceCommands {
+		resources[dc.Name] = true
+	}
+	var removed []string
+	for _, dr := range before.DeviceResources {
+		if !resources[dr.Name] {
+			removed = append(removed, dr.Name)
+		}
+	}
+	for _, dc := range before.DeviceCommands {
+		if !resources[dc.Name] {
+			removed = append(removed, dc.Name)
+		}
+	}
+	if len(removed) > 0 {
+		lc.Warn(fmt.Sprintf("resources %v removed from profile %s", removed, after.Name))
+	}
+
+	var deviceNames []string
+	for _, device := range cache.Devices().All() {
+		if device.ProfileName != after.Name {
+			continue
+		}
+		deviceNames = append(deviceNames, device.Name)
+		for _, autoEvent := range device.AutoEvents {
+			if !resources[autoEvent.Resource] {
+				lc.Warn(fmt.Sprintf("AutoEvent of device %s points at resource %s missing from profile %s", device.Name, autoEvent.Resource, after.Name))
+			}
+		}
+	}
+
+	if driver, ok := container.ProtocolDriverFrom(dic.Get).(dsModels.ProfileAwareDriver); ok {
+		if err := driver.UpdateProfile(after, deviceNames); err != nil {
+			lc.Error(fmt.Sprintf("driver.UpdateProfile callback failed for %s: %v", after.Name, err))
+		} else {
+			lc.Debug(fmt.Sprintf("Invoked driver.UpdateProfile callback for %s", after.Name))
+		}
+	}
+
+	eventbus.PublishProfileChange(&before, &after)
+}
@@ -13,7 +13,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	bootstrapContainer "github.com/edgexfoundry/go-mod-bootstrap/v2/bootstrap/container"
@@ -28,6 +30,24 @@ import (
 	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
 )
 
+// degraded is set while the service runs from the cache snapshot because metadata is unavailable
+var degraded int32
+
+// Degraded returns whether the service started from the cache snapshot and hasn't resynced
+// with metadata yet.
+func Degraded() bool {
+	return atomic.LoadInt32(&degraded) == 1
+}
+
+// SetDegraded sets or clears the degraded mode.
+func SetDegraded(d bool) {
+	var v int32
+	if d {
+		v = 1
+	}
+	atomic.StoreInt32(&degraded, v)
+}
+
 // Clients contains references to dependencies required by the Clients bootstrap implementation.
 type Clients struct {
 }
@@ -59,7 +79,16 @@ func InitDependencyClients(ctx context.Context, startupTimer startup.Timer, dic
 	}
 
 	if CheckDependencyServices(ctx, startupTimer, dic) == false {
-		return false
+		snapshot := container.ConfigurationFrom(dic.Get).Device.CacheSnapshot
+		if snapshot == "" {
+			return false
+		}
+		if _, err := os.Stat(snapshot); err != nil {
+			lc.Error(fmt.Sprintf("dependency services unavailable and no cache snapshot to start from: %v", err))
+			return false
+		}
+		lc.Warn(fmt.Sprintf("dependency services unavailable, starting in degraded mode from the cache snapshot %s", snapshot))
+		SetDegraded(true)
 	}
 
 	initializeClientsClients(dic)
@@ -133,6 +162,12 @@ func checkServiceAvailable(ctx context.Context, serviceId string, startupTimer s
 	return false
 }
 
+// CheckMetadataAvailable pings core metadata once and returns whether it is available.
+func CheckMetadataAvailable(dic *di.Container) bool {
+	lc := bootstrapContainer.LoggingClientFrom(dic.Get)
+	return checkServiceAvailableByPing(common.ClientMetadata, container.ConfigurationFrom(dic.Get), lc) == nil
+}
+
 func checkServiceAvailableByPing(serviceId string, configuration *common.ConfigurationStruct, lc logger.LoggingClient) error {
 	lc.Info(fmt.Sprintf("Check %v service's status by ping...", serviceId))
 	addr := configuration.Clients[serviceId].Url()
@@ -35,6 +35,7 @@ import (
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
 	sdkCommon "github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/computed"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
 	context2 "github.com/tuya/tuya-edge-driver-sdk-go/internal/context"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/transformer"
@@ -132,7 +133,7 @@ func CommandHandler(isRead bool, sendEvent bool, correlationID string, vars map[
 			return res, err
 		}
 	} else {
-		dr, drExists := cache.Profiles().DeviceResource(device.ProfileName, cmd)
+		dr, drExists := cache.DeviceResourceOf(device, cmd)
 		if !drExists {
 			return res, edgexErr.NewCommonEdgeX(edgexErr.KindEntityDoesNotExist, "command not found", nil)
 		}
@@ -161,31 +162,30 @@ func (c *CommandProcessor) ReadDeviceResource() (res responses.EventResponse, e
 		return res, edgexErr.NewCommonEdgeX(edgexErr.KindNotAllowed, errMsg, nil)
 	}
 
-	var req dsModels.CommandRequest
-	var reqs []dsModels.CommandRequest
-
 	// prepare CommandRequest
-	req.DeviceResourceName = c.deviceResource.Name
-	req.Attributes = c.deviceResource.Attributes
-	if c.params != "" {
-		if len(req.Attributes) <= 0 {
-			req.Attributes = make(map[string]string)
-		}
-		req.Attributes[sdkCommon.URLRawQuery] = c.params
+	drs := []models.DeviceResource{*c.deviceResource}
+	expanded, err := c.withDependencies(drs)
+	if err != nil {
+		return res, edgexErr.NewCommonEdgeX(edgexErr.KindServerError, "failed to resolve computed resource", err)
+	}
+	reqs, err := c.readRequests(expanded)
+	if err != nil {
+		return res, edgexErr.NewCommonEdgeX(edgexErr.KindServerError, "failed to prepare CommandRequest", err)
 	}
-	req.Type = c.deviceResource.Properties.Type
-	reqs = append(reqs, req)
 
 	// execute protocol-specific read operation
-	driver := container.ProtocolDriverFrom(c.dic.Get)
-	results, err := driver.HandleReadCommands(c.device.Name, c.device.Protocols, reqs)
+	results, err := c.handleReadCommands(reqs)
 	if err != nil {
 		errMsg := fmt.Sprintf("error reading DeviceResourece %s for %s: %v", c.deviceResource.Name, c.device.Name, err)
 		return res, edgexErr.NewCommonEdgeX(edgexErr.KindServerError, errMsg, err)
 	}
+	results, err = c.readResults(expanded, results)
+	if err != nil {
+		return res, edgexErr.NewCommonEdgeX(edgexErr.KindServerError, "failed to decode derived resources", err)
+	}
 
 	// convert CommandValue to Event
-	event, err := c.commandValuesToEvent(results, c.deviceResource.Name)
+	event, err := c.commandValuesToEvent(drs, results, c.deviceResource.Name)
 	if err != nil {
 		return res, edgexErr.NewCommonEdgeX(edgexErr.KindServerError, "failed to convert CommandValue to Event", err)
 	}
@@ -212,11 +212,11 @@ func (c *CommandProcessor) ReadCommand() (res responses.EventResponse, e edgexEr
 	}
 
 	// prepare CommandRequests
-	reqs := make([]dsModels.CommandRequest, len(ros))
+	drs := make([]models.DeviceResource, len(ros))
 	for i, op := range ros {
 		drName := op.DeviceResource
 		// check the deviceResource in ResourceOperation actually exist
-		dr, ok := cache.Profiles().DeviceResource(c.device.ProfileName, drName)
+		dr, ok := cache.DeviceResourceOf(*c.device, drName)
 		if !ok {
 			errMsg := fmt.Sprintf("deviceResource %s in GET commnd %s for %s not defined", drName, c.cmd, c.device.Name)
 			return res, edgexErr.NewCommonEdgeX(edgexErr.KindServerError, errMsg, nil)
@@ -227,27 +227,30 @@ func (c *CommandProcessor) ReadCommand() (res responses.EventResponse, e edgexEr
 			errMsg := fmt.Sprintf("deviceResource %s in GET command %s is marked as write-only", drName, c.cmd)
 			return res, edgexErr.NewCommonEdgeX(edgexErr.KindNotAllowed, errMsg, nil)
 		}
-		reqs[i].DeviceResourceName = dr.Name
-		reqs[i].Attributes = dr.Attributes
-		if c.params != "" {
-			if len(reqs[i].Attributes) <= 0 {
-				reqs[i].Attributes = make(map[string]string)
-			}
-			reqs[i].Attributes[sdkCommon.URLRawQuery] = c.params
-		}
-		reqs[i].Type = dr.Properties.Type
+		drs[i] = dr
+	}
+	expanded, eerr := c.withDependencies(drs)
+	if eerr != nil {
+		return res, edgexErr.NewCommonEdgeX(edgexErr.KindServerError, "failed to resolve computed resources", eerr)
+	}
+	reqs, eerr := c.readRequests(expanded)
+	if eerr != nil {
+		return res, edgexErr.NewCommonEdgeX(edgexErr.KindServerError, "failed to prepare CommandRequests", eerr)
 	}
 
 	// execute protocol-specific read operation
-	driver := container.ProtocolDriverFrom(c.dic.Get)
-	results, eerr := driver.HandleReadCommands(c.device.Name, c.device.Protocols, reqs)
+	results, eerr := c.handleReadCommands(reqs)
 	if eerr != nil {
-		errMsg := fmt.Sprintf("error reading DeviceCommand %s for %s: %v", c.cmd, c.device.Name, err)
+		errMsg := fmt.Sprintf("error reading DeviceCommand %s for %s: %v", c.cmd, c.device.Name, eerr)
 		return res, edgexErr.NewCommonEdgeX(edgexErr.KindServerError, errMsg, eerr)
 	}
+	results, eerr = c.readResults(expanded, results)
+	if eerr != nil {
+		return res, edgexErr.NewCommonEdgeX(edgexErr.KindServerError, "failed to decode derived resources", eerr)
+	}
 
 	// convert CommandValue to Event
-	event, err := c.commandValuesToEvent(results, c.cmd)
+	event, err := c.commandValuesToEvent(drs, results, c.cmd)
 	if err != nil {
 		return res, edgexErr.NewCommonEdgeX(edgexErr.KindServerError, "failed to transform CommandValue to Event", err)
 	}
@@ -261,7 +264,7 @@ func (c *CommandProcessor) WriteDeviceResource() edgexErr.EdgeX {
 	lc.Debug(fmt.Sprintf("Application - writeDeviceResource: writting deviceResource: %s", c.deviceResource.Name), sdkCommon.CorrelationHeader, c.correlationID)
 
 	// check provided deviceResource is not read-only
-	if c.deviceResource.Properties.ReadWrite == sdkCommon.DeviceResourceReadOnly {
+	if c.deviceResource.Properties.ReadWrite == sdkCommon.DeviceResourceReadOnly || computed.IsComputed(*c.deviceResource) {
 		errMsg := fmt.Sprintf("deviceResource %s is marked as read-only", c.deviceResource.Name)
 		return edgexErr.NewCommonEdgeX(edgexErr.KindNotAllowed, errMsg, nil)
 	}
@@ -300,6 +303,12 @@ func (c *CommandProcessor) WriteDeviceResource() edgexErr.EdgeX {
 	reqs[0].Attributes = c.deviceResource.Attributes
 	reqs[0].Type = cv.Type
 
+	// check the elements of an array against the Minimum and Maximum of the deviceResource
+	err = transformer.CheckArrayRange(cv, c.deviceResource.Properties)
+	if err != nil {
+		return edgexErr.NewCommonEdgeX(edgexErr.KindContractInvalid, "write value out of range", err)
+	}
+
 	// transform write value
 	configuration := container.ConfigurationFrom(c.dic.Get)
 	if configuration.Device.DataTransform {
@@ -308,10 +317,22 @@ func (c *CommandProcessor) WriteDeviceResource() edgexErr.EdgeX {
 			return edgexErr.NewCommonEdgeX(edgexErr.KindServerError, "failed to transform write value", nil)
 		}
 	}
+	cv, err = transformer.ApplyWriteTransforms(cv, *c.deviceResource)
+	if err != nil {
+		return edgexErr.NewCommonEdgeX(edgexErr.KindServerError, "failed to transform write value", err)
+	}
+	reqs[0].Type = cv.Type
+
+	// writing a derived resource is a read-modify-write of its parent
+	reqs, cvs, unlock, err := c.foldDerivedWrites(reqs, []*dsModels.CommandValue{cv})
+	if err != nil {
+		return edgexErr.NewCommonEdgeX(edgexErr.KindServerError, "failed to write derived resource", err)
+	}
+	defer unlock()
 
 	// execute protocol-specific write operation
 	driver := container.ProtocolDriverFrom(c.dic.Get)
-	err = driver.HandleWriteCommands(c.device.Name, c.device.Protocols, reqs, []*dsModels.CommandValue{cv})
+	err = driver.HandleWriteCommands(c.device.Name, cache.WithoutOverrides(c.device.Protocols), reqs, cvs)
 	if err != nil {
 		errMsg := fmt.Sprintf("error writing DeviceResourece %s for %s: %v", c.deviceResource.Name, c.device.Name, err)
 		return edgexErr.NewCommonEdgeX(edgexErr.KindServerError, errMsg, err)
@@ -349,14 +370,14 @@ func (c *CommandProcessor) WriteCommand() edgexErr.EdgeX {
 	for _, ro := range ros {
 		drName := ro.DeviceResource
 		// check the deviceResource in ResourceOperation actually exist
-		dr, ok := cache.Profiles().DeviceResource(c.device.ProfileName, drName)
+		dr, ok := cache.DeviceResourceOf(*c.device, drName)
 		if !ok {
 			errMsg := fmt.Sprintf("deviceResource %s in PUT commnd %s for %s not defined", drName, c.cmd, c.device.Name)
 			return edgexErr.NewCommonEdgeX(edgexErr.KindServerError, errMsg, nil)
 		}
 
 		// check the deviceResource isn't read-only
-		if dr.Properties.ReadWrite == sdkCommon.DeviceResourceReadOnly {
+		if dr.Properties.ReadWrite == sdkCommon.DeviceResourceReadOnly || computed.IsComputed(dr) {
 			errMsg := fmt.Sprintf("deviceResource %s in PUT command %s is marked as read-only", drName, c.cmd)
 			return edgexErr.NewCommonEdgeX(edgexErr.KindNotAllowed, errMsg, nil)
 		}
@@ -381,9 +402,19 @@ func (c *CommandProcessor) WriteCommand() edgexErr.EdgeX {
 		}
 		// write value mapping
 		if len(ro.Mappings) > 0 {
-			newValue, ok := ro.Mappings[string(valueStr)]
+			// a string value is matched without its JSON quotes, an unmatched value is
+			// written as without mappings
+			key := string(valueStr)
+			if s, isString := value.(string); isString {
+				key = s
+			}
+			newValue, ok, err := transformer.MapValue(key, ro.Mappings)
+			if err != nil {
+				errMsg := fmt.Sprintf("ResourceOperation %s mapping value (%v) failed", ro.DeviceResource, value)
+				return edgexErr.NewCommonEdgeX(edgexErr.KindContractInvalid, errMsg, err)
+			}
 			if ok {
-				value = newValue
+				valueStr = []byte(newValue)
 			} else {
 				lc.Warn(fmt.Sprintf("ResourceOperation %s mapping value (%s) failed with the mapping table: %v", ro.DeviceResource, value, ro.Mappings))
 			}
@@ -401,12 +432,18 @@ func (c *CommandProcessor) WriteCommand() edgexErr.EdgeX {
 	// prepare CommandRequests
 	reqs := make([]dsModels.CommandRequest, len(cvs))
 	for i, cv := range cvs {
-		dr, _ := cache.Profiles().DeviceResource(c.device.ProfileName, cv.DeviceResourceName)
+		dr, _ := cache.DeviceResourceOf(*c.device, cv.DeviceResourceName)
 
 		reqs[i].DeviceResourceName = cv.DeviceResourceName
 		reqs[i].Attributes = dr.Attributes
 		reqs[i].Type = cv.Type
 
+		// check the elements of an array against the Minimum and Maximum of the deviceResource
+		err = transformer.CheckArrayRange(cv, dr.Properties)
+		if err != nil {
+			return edgexErr.NewCommonEdgeX(edgexErr.KindContractInvalid, "write values out of range", err)
+		}
+
 		// transform write value
 		if configuration.Device.DataTransform {
 			err = transformer.TransformWriteParameter(cv, dr.Properties, lc)
@@ -414,11 +451,23 @@ func (c *CommandProcessor) WriteCommand() edgexErr.EdgeX {
 				return edgexErr.NewCommonEdgeX(edgexErr.KindServerError, "failed to transform write values", err)
 			}
 		}
+		cvs[i], err = transformer.ApplyWriteTransforms(cv, dr)
+		if err != nil {
+			return edgexErr.NewCommonEdgeX(edgexErr.KindServerError, "failed to transform write values", err)
+		}
+		reqs[i].Type = cvs[i].Type
 	}
 
+	// writing derived resources is a read-modify-write of their parents
+	reqs, cvs, unlock, err := c.foldDerivedWrites(reqs, cvs)
+	if err != nil {
+		return edgexErr.NewCommonEdgeX(edgexErr.KindServerError, "failed to write derived resources", err)
+	}
+	defer unlock()
+
 	// execute protocol-specific write operation
 	driver := container.ProtocolDriverFrom(c.dic.Get)
-	err = driver.HandleWriteCommands(c.device.Name, c.device.Protocols, reqs, cvs)
+	err = driver.HandleWriteCommands(c.device.Name, cache.WithoutOverrides(c.device.Protocols), reqs, cvs)
 	if err != nil {
 		errMsg := fmt.Sprintf("error writing DeviceResourece for %s: %v", c.device.Name, err)
 		return edgexErr.NewCommonEdgeX(edgexErr.KindServerError, errMsg, err)
@@ -427,7 +476,7 @@ func (c *CommandProcessor) WriteCommand() edgexErr.EdgeX {
 	return nil
 }
 
-func (c *CommandProcessor) commandValuesToEvent(cvs []*dsModels.CommandValue, cmd string) (dtos.Event, edgexErr.EdgeX) {
+func (c *CommandProcessor) commandValuesToEvent(drs []models.DeviceResource, cvs []*dsModels.CommandValue, cmd string) (dtos.Event, edgexErr.EdgeX) {
 	var err error
 	var transformsOK = true
 	lc := bootstrapContainer.LoggingClientFrom(c.dic.Get)
@@ -435,33 +484,118 @@ func (c *CommandProcessor) commandValuesToEvent(cvs []*dsModels.CommandValue, cm
 	configuration := container.ConfigurationFrom(c.dic.Get)
 	readings := make([]dtos.BaseReading, 0, configuration.Device.MaxCmdOps)
 
+	// the inputs of computed resources which are not requested are not reported
+	requested := make(map[string]bool, len(drs))
+	var computedDRs []models.DeviceResource
+	for _, dr := range drs {
+		requested[dr.Name] = true
+		if computed.IsComputed(dr) {
+			computedDRs = append(computedDRs, dr)
+		}
+	}
+
+	transformed := make([]*dsModels.CommandValue, 0, len(cvs)+len(computedDRs))
+	arrived := make([]string, 0, len(cvs))
 	for _, cv := range cvs {
 		// double check the CommandValue return from ProtocolDriver match device command
-		dr, ok := cache.Profiles().DeviceResource(c.device.ProfileName, cv.DeviceResourceName)
+		dr, ok := cache.DeviceResourceOf(*c.device, cv.DeviceResourceName)
 		if !ok {
 			return dtos.Event{}, edgexErr.NewCommonEdgeXWrapper(fmt.Errorf("no deviceResource %s for %s in CommandValue (%s)", cv.DeviceResourceName, c.device.Name, cv.String()))
 		}
 
-		// perform data transformation
-		if configuration.Device.DataTransform {
+		// extract the value of the deviceResource from a JSON document, a document missing
+		// the value only drops the reading of that deviceResource
+		cv, err = transformer.ExtractJSONPath(cv, dr)
+		if err != nil {
+			lc.Error(fmt.Sprintf("dropping the reading of deviceResource %s: %v", dr.Name, err), sdkCommon.CorrelationHeader, c.correlationID)
+			computed.Drop(c.device.Name, dr.Name)
+			continue
+		}
+		// decode the raw payload returned for a typed deviceResource
+		cv, err = transformer.DecodeBinaryValue(cv, dr)
+		if err != nil {
+			return dtos.Event{}, edgexErr.NewCommonEdgeXWrapper(err)
+		}
+
+		// perform the custom transforms of the driver then data transformation
+		cv, err = transformer.ApplyReadTransforms(cv, dr)
+		if err == nil && configuration.Device.DataTransform {
 			err = transformer.TransformReadResult(cv, dr.Properties, lc)
 			lc.Debug(fmt.Sprintf("command value: %+v", cv))
-			if err != nil {
-				lc.Error(fmt.Sprintf("failed to transform CommandValue (%s): %v", cv.String(), err), sdkCommon.CorrelationHeader, c.correlationID)
-
-				if errors.As(err, &transformer.OverflowError{}) {
-					cv = dsModels.NewStringValue(cv.DeviceResourceName, cv.Origin, transformer.Overflow)
-				} else if errors.As(err, &transformer.NaNError{}) {
-					cv = dsModels.NewStringValue(cv.DeviceResourceName, cv.Origin, transformer.NaN)
-				} else {
-					transformsOK = false
-				}
+		}
+		if err == nil {
+			err = transformer.CheckArrayRange(cv, dr.Properties)
+		}
+		if err != nil {
+			lc.Error(fmt.Sprintf("failed to transform CommandValue (%s): %v", cv.String(), err), sdkCommon.CorrelationHeader, c.correlationID)
+
+			if errors.As(err, &transformer.OverflowError{}) {
+				cv = dsModels.NewStringValue(cv.DeviceResourceName, cv.Origin, transformer.Overflow)
+			} else if errors.As(err, &transformer.NaNError{}) {
+				cv = dsModels.NewStringValue(cv.DeviceResourceName, cv.Origin, transformer.NaN)
+			} else {
+				transformsOK = false
 			}
 		}
 
+		// a rejected value must not leave its predecessor as the input of computed resources
+		if err != nil {
+			computed.Drop(c.device.Name, cv.DeviceResourceName)
+		} else {
+			computed.Remember(c.device.Name, cv)
+		}
+		arrived = append(arrived, cv.DeviceResourceName)
+		if len(computedDRs) > 0 && !requested[cv.DeviceResourceName] {
+			continue
+		}
+		transformed = append(transformed, cv)
+	}
+
+	// evaluate computed resources with the values just read
+	for _, dr := range computedDRs {
+		cv, err := computed.Evaluate(c.device.Name, dr, time.Now().UnixNano())
+		if err != nil {
+			lc.Error(fmt.Sprintf("failed to evaluate computed deviceResource %s: %v", dr.Name, err), sdkCommon.CorrelationHeader, c.correlationID)
+
+			if errors.As(err, &transformer.OverflowError{}) {
+				cv = dsModels.NewStringValue(dr.Name, time.Now().UnixNano(), transformer.Overflow)
+			} else if errors.As(err, &transformer.NaNError{}) {
+				cv = dsModels.NewStringValue(dr.Name, time.Now().UnixNano(), transformer.NaN)
+			} else {
+				transformsOK = false
+				continue
+			}
+		}
+		transformed = append(transformed, cv)
+	}
+
+	// re-evaluate the other computed resources whose inputs were just read, as it's done
+	// for the async readings, the inputs not read take their last known values
+	for _, dr := range computed.Dependents(*c.device, arrived) {
+		if requested[dr.Name] {
+			continue
+		}
+		cv, err := computed.Evaluate(c.device.Name, dr, time.Now().UnixNano())
+		if err != nil {
+			if errors.As(err, &transformer.OverflowError{}) {
+				cv = dsModels.NewStringValue(dr.Name, time.Now().UnixNano(), transformer.Overflow)
+			} else if errors.As(err, &transformer.NaNError{}) {
+				cv = dsModels.NewStringValue(dr.Name, time.Now().UnixNano(), transformer.NaN)
+			} else {
+				// e.g. another input hasn't been read yet
+				lc.Debug(fmt.Sprintf("computed deviceResource %s not evaluated: %v", dr.Name, err), sdkCommon.CorrelationHeader, c.correlationID)
+				continue
+			}
+		}
+		transformed = append(transformed, cv)
+	}
+
+	for _, cv := range transformed {
+		dr, _ := cache.DeviceResourceOf(*c.device, cv.DeviceResourceName)
+
 		// assertion
 		dc := container.MetadataDeviceClientFrom(c.dic.Get)
-		err = transformer.CheckAssertion(cv, dr.Properties.Assertion, c.device, lc, dc)
+		alarm, err := transformer.CheckAssertion(cv, dr, c.device, lc, dc)
 		if err != nil {
 			cv = dsModels.NewStringValue(cv.DeviceResourceName, cv.Origin, fmt.Sprintf("Assertion failed for device resource: %s, with value: %s", cv.DeviceResourceName, cv.String()))
 		}
@@ -471,8 +605,11 @@ func (c *CommandProcessor) commandValuesToEvent(cvs []*dsModels.CommandValue, cm
 			// this allows SDK to directly read deviceResource without deviceCommands defined.
 			lc.Debug(fmt.Sprintf("failed to read ResourceOperation: %v", exrr), sdkCommon.CorrelationHeader, c.correlationID)
 		} else if len(ro.Mappings) > 0 {
-			newCV, ok := transformer.MapCommandValue(cv, ro.Mappings)
-			if ok {
+			newCV, ok, err := transformer.MapCommandValue(cv, ro.Mappings)
+			if err != nil {
+				lc.Error(fmt.Sprintf("ResourceOperation (%s) mapping value (%s) failed: %v", ro.DeviceResource, cv.String(), err), sdkCommon.CorrelationHeader, c.correlationID)
+				transformsOK = false
+			} else if ok {
 				cv = newCV
 			} else {
 				lc.Warn(fmt.Sprintf("ResourceOperation (%s) mapping value (%s) failed with the mapping table: %v", ro.DeviceResource, cv.String(), ro.Mappings), sdkCommon.CorrelationHeader, c.correlationID)
@@ -483,6 +620,9 @@ func (c *CommandProcessor) commandValuesToEvent(cvs []*dsModels.CommandValue, cm
 
 		reading := commandValueToReading(cv, c.device.Name, c.device.ProfileName, dr.Properties.MediaType, "")
 		readings = append(readings, reading)
+		if alarm != nil {
+			readings = append(readings, commandValueToReading(alarm, c.device.Name, c.device.ProfileName, "", ""))
+		}
 
 		if cv.Type == contracts.ValueTypeBinary {
 			lc.Debug(fmt.Sprintf("device: %s DeviceResource: %v reading: binary value", c.device.Name, cv.DeviceResourceName), sdkCommon.CorrelationHeader, c.correlationID)
@@ -0,0 +1,211 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	bootstrapContainer "github.com/edgexfoundry/go-mod-bootstrap/v2/bootstrap/container"
+	"github.com/edgexfoundry/go-mod-bootstrap/v2/di"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	edgexErr "github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	sdkCommon "github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/mock"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/transformer"
+	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+var initCacheOnce sync.Once
+
+// initTestCache initializes the caches once with an empty snapshot, the tests add their own
+// profiles and devices.
+func initTestCache(t *testing.T) {
+	initCacheOnce.Do(func() {
+		dir, err := ioutil.TempDir("", "command-test")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "snapshot.json")
+		require.NoError(t, ioutil.WriteFile(path, []byte("{}"), 0600))
+		_, err = cache.InitCacheFromSnapshot(path, logger.NewMockClient())
+		require.NoError(t, err)
+	})
+}
+
+// addTestDevice adds the profile and a device of the profile to the caches.
+func addTestDevice(t *testing.T, profile models.DeviceProfile) models.Device {
+	initTestCache(t)
+	require.NoError(t, cache.Profiles().Add(profile))
+	device := models.Device{Name: profile.Name + "-device", ProfileName: profile.Name, AdminState: models.Unlocked, OperatingState: models.Up}
+	require.NoError(t, cache.Devices().Add(device))
+	return device
+}
+
+// registerDriver keeps the last written value of each DeviceResource, the writes are applied
+// after the write delay.
+type registerDriver struct {
+	dsModels.ProtocolDriver
+	values     map[string]*dsModels.CommandValue
+	writeDelay time.Duration
+	mutex      sync.Mutex
+}
+
+func newRegisterDriver(values ...*dsModels.CommandValue) *registerDriver {
+	d := &registerDriver{values: make(map[string]*dsModels.CommandValue)}
+	for _, cv := range values {
+		d.values[cv.DeviceResourceName] = cv
+	}
+	return d
+}
+
+func (d *registerDriver) HandleReadCommands(_ string, _ map[string]models.ProtocolProperties, reqs []dsModels.CommandRequest) ([]*dsModels.CommandValue, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	res := make([]*dsModels.CommandValue, len(reqs))
+	for i, req := range reqs {
+		cv := *d.values[req.DeviceResourceName]
+		res[i] = &cv
+	}
+	return res, nil
+}
+
+func (d *registerDriver) HandleWriteCommands(_ string, _ map[string]models.ProtocolProperties, _ []dsModels.CommandRequest, params []*dsModels.CommandValue) error {
+	time.Sleep(d.writeDelay)
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, cv := range params {
+		d.values[cv.DeviceResourceName] = cv
+	}
+	return nil
+}
+
+func (d *registerDriver) value(name string) *dsModels.CommandValue {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.values[name]
+}
+
+func newTestContainer(driver dsModels.ProtocolDriver) *di.Container {
+	config := &sdkCommon.ConfigurationStruct{}
+	config.Device.MaxCmdOps = 128
+	return di.NewContainer(di.ServiceConstructorMap{
+		container.ConfigurationName: func(get di.Get) interface{} {
+			return config
+		},
+		bootstrapContainer.LoggingClientInterfaceName: func(get di.Get) interface{} {
+			return logger.NewMockClient()
+		},
+		container.ProtocolDriverName: func(get di.Get) interface{} {
+			return driver
+		},
+		container.DeviceServiceName: func(get di.Get) interface{} {
+			return models.DeviceService{Name: "command-test", AdminState: models.Unlocked}
+		},
+		container.MetadataDeviceClientName: func(get di.Get) interface{} {
+			return &mock.DeviceClientMock{}
+		},
+	})
+}
+
+func TestReadMissingJSONPath(t *testing.T) {
+	jsonPath := func(name string, path string) models.DeviceResource {
+		return models.DeviceResource{Name: name, Properties: models.PropertyValue{Type: contracts.ValueTypeFloat64, ReadWrite: "R"},
+			Attributes: map[string]string{sdkCommon.AttributeDerivedFrom: "doc", sdkCommon.AttributeJSONPath: path}}
+	}
+	device := addTestDevice(t, models.DeviceProfile{
+		Name: "jsonpath-read",
+		DeviceResources: []models.DeviceResource{
+			{Name: "doc", Properties: models.PropertyValue{Type: contracts.ValueTypeString, ReadWrite: "R"}},
+			jsonPath("temperature", "$.temperature"),
+			jsonPath("humidity", "$.humidity"),
+		},
+		DeviceCommands: []models.ProfileResource{
+			{Name: "climate", Get: []models.ResourceOperation{{DeviceResource: "temperature"}, {DeviceResource: "humidity"}}},
+		},
+	})
+	doc := dsModels.NewStringValue("doc", 0, `{"temperature": 21.5}`)
+
+	values := read(t, device, "climate", newRegisterDriver(doc))
+	_, ok := values["temperature"]
+	assert.True(t, ok)
+	_, ok = values["humidity"]
+	assert.False(t, ok, "only the reading missing from the document is dropped")
+}
+
+func TestWriteMappedString(t *testing.T) {
+	device := addTestDevice(t, models.DeviceProfile{
+		Name: "mapping-write",
+		DeviceResources: []models.DeviceResource{
+			{Name: "mode", Properties: models.PropertyValue{Type: contracts.ValueTypeString, ReadWrite: "RW"}},
+		},
+		DeviceCommands: []models.ProfileResource{
+			{Name: "mode", Set: []models.ResourceOperation{{DeviceResource: "mode", Mappings: map[string]string{"eco": "1", "/^boost-.*$/": "2"}}}},
+		},
+	})
+	driver := newRegisterDriver()
+	dic := newTestContainer(driver)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{"exact rule", "eco", "1"},
+		{"regex rule", "boost-high", "2"},
+		{"no rule written as without mappings", "manual", `"manual"`},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := NewCommandProcessor(&device, nil, "", "mode", fmt.Sprintf(`{"mode":"%s"}`, testCase.value), dic)
+			require.NoError(t, c.WriteCommand())
+			written, err := driver.value("mode").StringValue()
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, written)
+		})
+	}
+}
+
+func TestArrayRange(t *testing.T) {
+	device := addTestDevice(t, models.DeviceProfile{
+		Name: "array-range",
+		DeviceResources: []models.DeviceResource{
+			{Name: "waveform", Properties: models.PropertyValue{Type: contracts.ValueTypeInt16Array, ReadWrite: "RW", Minimum: "-10", Maximum: "10"}},
+			{Name: "level", Properties: models.PropertyValue{Type: contracts.ValueTypeInt16, ReadWrite: "RW", Minimum: "-10", Maximum: "10"}},
+		},
+		DeviceCommands: []models.ProfileResource{
+			{Name: "waveform", Get: []models.ResourceOperation{{DeviceResource: "waveform"}}, Set: []models.ResourceOperation{{DeviceResource: "waveform"}}},
+			{Name: "level", Set: []models.ResourceOperation{{DeviceResource: "level"}}},
+		},
+	})
+	waveform, err := dsModels.NewInt16ArrayValue("waveform", 0, []int16{-10, 11})
+	require.NoError(t, err)
+	driver := newRegisterDriver(waveform)
+
+	values := read(t, device, "waveform", driver)
+	assert.Equal(t, transformer.Overflow, values["waveform"], "the elements of an array reading are checked")
+
+	dic := newTestContainer(driver)
+	c := NewCommandProcessor(&device, nil, "", "waveform", `{"waveform":[0,-11]}`, dic)
+	err = c.WriteCommand()
+	if assert.Error(t, err, "the elements of an array write are checked") {
+		assert.Equal(t, edgexErr.KindContractInvalid, edgexErr.Kind(err))
+	}
+	c = NewCommandProcessor(&device, nil, "", "level", `{"level":11}`, dic)
+	assert.NoError(t, c.WriteCommand(), "the scalar writes aren't checked")
+}
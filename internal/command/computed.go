@@ -0,0 +1,52 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package command
+
+import (
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/computed"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+// withDependencies returns the given DeviceResources completed with the inputs of the
+// computed resources among them, each input is listed once and before its dependents.
+func (c *CommandProcessor) withDependencies(drs []models.DeviceResource) ([]models.DeviceResource, error) {
+	listed := make(map[string]bool, len(drs))
+	for _, dr := range drs {
+		listed[dr.Name] = true
+	}
+
+	expanded := make([]models.DeviceResource, 0, len(drs))
+	for _, dr := range drs {
+		if computed.IsComputed(dr) {
+			deps, err := computed.Dependencies(*c.device, dr)
+			if err != nil {
+				return nil, err
+			}
+			for _, dep := range deps {
+				if !listed[dep.Name] {
+					listed[dep.Name] = true
+					expanded = append(expanded, dep)
+				}
+			}
+		}
+		expanded = append(expanded, dr)
+	}
+	return expanded, nil
+}
+
+// handleReadCommands executes the protocol-specific read operation, the ProtocolDriver
+// isn't invoked if there is nothing to read, e.g. a computed resource over constants only.
+func (c *CommandProcessor) handleReadCommands(reqs []dsModels.CommandRequest) ([]*dsModels.CommandValue, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+	driver := container.ProtocolDriverFrom(c.dic.Get)
+	return driver.HandleReadCommands(c.device.Name, cache.WithoutOverrides(c.device.Protocols), reqs)
+}
@@ -0,0 +1,101 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package command
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	sdkCommon "github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/transformer"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+func computedProfile(name string) models.DeviceProfile {
+	return models.DeviceProfile{
+		Name: name,
+		DeviceResources: []models.DeviceResource{
+			{Name: "voltage", Properties: models.PropertyValue{Type: contracts.ValueTypeFloat64, ReadWrite: "R"}},
+			{Name: "current", Properties: models.PropertyValue{Type: contracts.ValueTypeFloat64, ReadWrite: "R"}},
+			{Name: "power", Properties: models.PropertyValue{Type: contracts.ValueTypeFloat64, ReadWrite: "R"},
+				Attributes: map[string]string{sdkCommon.AttributeExpression: "voltage * current"}},
+		},
+		DeviceCommands: []models.ProfileResource{
+			{Name: "electric", Get: []models.ResourceOperation{{DeviceResource: "voltage"}, {DeviceResource: "current"}}},
+			{Name: "voltage", Get: []models.ResourceOperation{{DeviceResource: "voltage"}}},
+			{Name: "current", Get: []models.ResourceOperation{{DeviceResource: "current"}}},
+			{Name: "power", Get: []models.ResourceOperation{{DeviceResource: "power"}}},
+		},
+	}
+}
+
+func float64Value(t *testing.T, name string, v float64) *dsModels.CommandValue {
+	cv, err := dsModels.NewFloat64Value(name, 0, v)
+	require.NoError(t, err)
+	return cv
+}
+
+// readingValues returns the reading values of the event by resource name.
+func readingValues(event dtos.Event) map[string]string {
+	values := make(map[string]string, len(event.Readings))
+	for _, r := range event.Readings {
+		values[r.ResourceName] = r.Value
+	}
+	return values
+}
+
+// read reads the command of the device as an AutoEvent does.
+func read(t *testing.T, device models.Device, cmd string, driver *registerDriver) map[string]string {
+	vars := map[string]string{sdkCommon.NameVar: device.Name, sdkCommon.CommandVar: cmd}
+	dic := newTestContainer(driver)
+	container.ConfigurationFrom(dic.Get).Device.DataTransform = true
+	res, err := CommandHandler(true, false, "", vars, "", dic)
+	require.NoError(t, err)
+	return readingValues(res.Event)
+}
+
+func TestReadDependentComputedResources(t *testing.T) {
+	device := addTestDevice(t, computedProfile("computed-read"))
+	driver := newRegisterDriver(float64Value(t, "voltage", 220), float64Value(t, "current", 2))
+	power := func(v float64) string {
+		return commandValueToReading(float64Value(t, "power", v), device.Name, device.ProfileName, "", "").Value
+	}
+
+	values := read(t, device, "electric", driver)
+	assert.Len(t, values, 3)
+	assert.Equal(t, power(440), values["power"], "the computed resource over the inputs of the command is reported")
+
+	driver.values["voltage"] = float64Value(t, "voltage", 230)
+	values = read(t, device, "voltage", driver)
+	assert.Len(t, values, 2)
+	assert.Equal(t, power(460), values["power"], "reading an input re-evaluates its dependents with the last known values")
+
+	values = read(t, device, "power", driver)
+	assert.Equal(t, map[string]string{"power": power(460)}, values, "the inputs of a requested computed resource are not reported")
+}
+
+func TestReadRejectedComputedInput(t *testing.T) {
+	device := addTestDevice(t, computedProfile("computed-rejected"))
+	driver := newRegisterDriver(float64Value(t, "voltage", 220), float64Value(t, "current", 2))
+	read(t, device, "electric", driver)
+
+	driver.values["voltage"] = float64Value(t, "voltage", math.NaN())
+	values := read(t, device, "voltage", driver)
+	assert.Equal(t, map[string]string{"voltage": transformer.NaN}, values, "a rejected input is not replaced by its stale value")
+
+	driver.values["current"] = float64Value(t, "current", 3)
+	values = read(t, device, "current", driver)
+	_, ok := values["power"]
+	assert.False(t, ok, "the dropped input isn't used by the later evaluations")
+}
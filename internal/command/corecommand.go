@@ -0,0 +1,46 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package command
+
+import (
+	"fmt"
+
+	"github.com/edgexfoundry/go-mod-bootstrap/v2/di"
+
+	edgexErr "github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
+)
+
+// CheckCoreCommand verifies that the command is exposed by the CoreCommands of the device's
+// profile with the Get or Put flag, when StrictCoreCommands is enabled. Only the external
+// callers are restricted, the SDK's own callers like the AutoEvents aren't.
+func CheckCoreCommand(deviceName string, cmd string, isRead bool, dic *di.Container) edgexErr.EdgeX {
+	if !container.ConfigurationFrom(dic.Get).Device.StrictCoreCommands {
+		return nil
+	}
+	device, ok := cache.Devices().ForName(deviceName)
+	if !ok {
+		// reported by the CommandHandler
+		return nil
+	}
+
+	coreCommand, ok := cache.Profiles().CoreCommand(device.ProfileName, cmd)
+	if !ok {
+		errMsg := fmt.Sprintf("command %s is not a coreCommand of profile %s", cmd, device.ProfileName)
+		return edgexErr.NewCommonEdgeX(edgexErr.KindNotAllowed, errMsg, nil)
+	}
+	if isRead && !coreCommand.Get {
+		errMsg := fmt.Sprintf("coreCommand %s of profile %s doesn't allow Get", cmd, device.ProfileName)
+		return edgexErr.NewCommonEdgeX(edgexErr.KindNotAllowed, errMsg, nil)
+	}
+	if !isRead && !coreCommand.Put {
+		errMsg := fmt.Sprintf("coreCommand %s of profile %s doesn't allow Put", cmd, device.ProfileName)
+		return edgexErr.NewCommonEdgeX(edgexErr.KindNotAllowed, errMsg, nil)
+	}
+	return nil
+}
@@ -0,0 +1,454 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	sdkCommon "github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/computed"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
+	"github.com/tuya/tuya-edge-driver-sdk-go/pkg/codec"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+// bitField describes a derived resource which occupies a range of bits of its parent
+// DeviceResource, e.g. a single flag of a 16-bit status word.
+type bitField struct {
+	parent string
+	offset uint
+	length uint
+}
+
+// derivedBitField parses the SDK reserved attributes of the given DeviceResource, the
+// returned bool indicates whether the DeviceResource is a derived resource.
+func derivedBitField(dr models.DeviceResource) (bitField, bool, error) {
+	parent := dr.Attributes[sdkCommon.AttributeDerivedFrom]
+	if parent == "" {
+		return bitField{}, false, nil
+	}
+
+	f := bitField{parent: parent, length: 1}
+	if v, ok := dr.Attributes[sdkCommon.AttributeBitOffset]; ok {
+		offset, err := strconv.ParseUint(v, 10, 8)
+		if err != nil {
+			return f, true, fmt.Errorf("invalid %s %s of deviceResource %s: %v", sdkCommon.AttributeBitOffset, v, dr.Name, err)
+		}
+		f.offset = uint(offset)
+	}
+	if v, ok := dr.Attributes[sdkCommon.AttributeBitLength]; ok {
+		length, err := strconv.ParseUint(v, 10, 8)
+		if err != nil {
+			return f, true, fmt.Errorf("invalid %s %s of deviceResource %s: %v", sdkCommon.AttributeBitLength, v, dr.Name, err)
+		}
+		f.length = uint(length)
+	}
+	if f.length == 0 || f.offset+f.length > 64 {
+		return f, true, fmt.Errorf("bit range [%d, %d) of deviceResource %s is out of 64 bits", f.offset, f.offset+f.length, dr.Name)
+	}
+
+	return f, true, nil
+}
+
+func (f bitField) valueMask() uint64 {
+	if f.length == 64 {
+		return ^uint64(0)
+	}
+	return (uint64(1) << f.length) - 1
+}
+
+// extract returns the bits of the field from the raw parent value, signed fields are sign-extended.
+func (f bitField) extract(raw uint64, valueType string) uint64 {
+	v := (raw >> f.offset) & f.valueMask()
+	if isSignedType(valueType) && f.length < 64 && v&(uint64(1)<<(f.length-1)) != 0 {
+		v |= ^f.valueMask()
+	}
+	return v
+}
+
+// insert replaces the bits of the field in the raw parent value and leaves the other bits untouched.
+func (f bitField) insert(raw uint64, v uint64, valueType string) (uint64, error) {
+	if isSignedType(valueType) {
+		// a negative value carries its sign in the bits above the field
+		upper := v &^ (f.valueMask() >> 1)
+		if upper != 0 && upper != ^(f.valueMask()>>1) {
+			return raw, fmt.Errorf("value %d does not fit in %d bits", int64(v), f.length)
+		}
+	} else if v&^f.valueMask() != 0 {
+		return raw, fmt.Errorf("value %d does not fit in %d bits", v, f.length)
+	}
+
+	mask := f.valueMask() << f.offset
+	return (raw &^ mask) | ((v << f.offset) & mask), nil
+}
+
+func isSignedType(valueType string) bool {
+	switch valueType {
+	case contracts.ValueTypeInt8, contracts.ValueTypeInt16, contracts.ValueTypeInt32, contracts.ValueTypeInt64:
+		return true
+	}
+	return false
+}
+
+// commandValueToUint64 returns the raw bits of an integer or Bool CommandValue.
+func commandValueToUint64(cv *dsModels.CommandValue) (uint64, error) {
+	switch cv.Type {
+	case contracts.ValueTypeBool:
+		v, err := cv.BoolValue()
+		if v {
+			return 1, err
+		}
+		return 0, err
+	case contracts.ValueTypeUint8:
+		v, err := cv.Uint8Value()
+		return uint64(v), err
+	case contracts.ValueTypeUint16:
+		v, err := cv.Uint16Value()
+		return uint64(v), err
+	case contracts.ValueTypeUint32:
+		v, err := cv.Uint32Value()
+		return uint64(v), err
+	case contracts.ValueTypeUint64:
+		return cv.Uint64Value()
+	case contracts.ValueTypeInt8:
+		v, err := cv.Int8Value()
+		return uint64(uint8(v)), err
+	case contracts.ValueTypeInt16:
+		v, err := cv.Int16Value()
+		return uint64(uint16(v)), err
+	case contracts.ValueTypeInt32:
+		v, err := cv.Int32Value()
+		return uint64(uint32(v)), err
+	case contracts.ValueTypeInt64:
+		v, err := cv.Int64Value()
+		return uint64(v), err
+	default:
+		return 0, fmt.Errorf("CommandValue (%s) is not an integer or bool value", cv.String())
+	}
+}
+
+// uint64ToCommandValue creates a CommandValue of the given integer or Bool type from raw bits.
+func uint64ToCommandValue(resourceName string, origin int64, valueType string, v uint64) (*dsModels.CommandValue, error) {
+	switch valueType {
+	case contracts.ValueTypeBool:
+		return dsModels.NewBoolValue(resourceName, origin, v != 0)
+	case contracts.ValueTypeUint8:
+		return dsModels.NewUint8Value(resourceName, origin, uint8(v))
+	case contracts.ValueTypeUint16:
+		return dsModels.NewUint16Value(resourceName, origin, uint16(v))
+	case contracts.ValueTypeUint32:
+		return dsModels.NewUint32Value(resourceName, origin, uint32(v))
+	case contracts.ValueTypeUint64:
+		return dsModels.NewUint64Value(resourceName, origin, v)
+	case contracts.ValueTypeInt8:
+		return dsModels.NewInt8Value(resourceName, origin, int8(v))
+	case contracts.ValueTypeInt16:
+		return dsModels.NewInt16Value(resourceName, origin, int16(v))
+	case contracts.ValueTypeInt32:
+		return dsModels.NewInt32Value(resourceName, origin, int32(v))
+	case contracts.ValueTypeInt64:
+		return dsModels.NewInt64Value(resourceName, origin, int64(v))
+	default:
+		return nil, fmt.Errorf("value type %s is not supported by derived resource %s", valueType, resourceName)
+	}
+}
+
+// parentResource returns the parent DeviceResource of a derived resource.
+func (c *CommandProcessor) parentResource(f bitField, drName string) (models.DeviceResource, error) {
+	parent, ok := cache.DeviceResourceOf(*c.device, f.parent)
+	if !ok {
+		return parent, fmt.Errorf("parent deviceResource %s of %s not defined", f.parent, drName)
+	}
+	if _, derived, _ := derivedBitField(parent); derived {
+		return parent, fmt.Errorf("parent deviceResource %s of %s is a derived resource", f.parent, drName)
+	}
+	return parent, nil
+}
+
+// isParentResource returns whether a derived resource of the device's profile refers to the
+// DeviceResource with the given name.
+func (c *CommandProcessor) isParentResource(name string) bool {
+	profile, ok := cache.Profiles().Resolved(c.device.ProfileName)
+	if !ok {
+		return false
+	}
+	for _, dr := range profile.DeviceResources {
+		if dr.Attributes[sdkCommon.AttributeDerivedFrom] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// readRequests prepares the CommandRequests sent to the ProtocolDriver for the given
+// DeviceResources. Derived resources are replaced by their parents, each resource is
+// requested only once no matter how many derived resources refer to it.
+func (c *CommandProcessor) readRequests(drs []models.DeviceResource) ([]dsModels.CommandRequest, error) {
+	reqs := make([]dsModels.CommandRequest, 0, len(drs))
+	requested := make(map[string]bool, len(drs))
+	for _, dr := range drs {
+		// computed resources are evaluated by the SDK, their inputs are requested instead
+		if computed.IsComputed(dr) {
+			continue
+		}
+		f, derived, err := derivedBitField(dr)
+		if err != nil {
+			return nil, err
+		}
+		if derived {
+			if dr, err = c.parentResource(f, dr.Name); err != nil {
+				return nil, err
+			}
+		}
+		if requested[dr.Name] {
+			continue
+		}
+		requested[dr.Name] = true
+		reqs = append(reqs, c.commandRequest(dr))
+	}
+	return reqs, nil
+}
+
+// commandRequest prepares the CommandRequest of a single DeviceResource.
+func (c *CommandProcessor) commandRequest(dr models.DeviceResource) dsModels.CommandRequest {
+	req := dsModels.CommandRequest{
+		DeviceResourceName: dr.Name,
+		Attributes:         dr.Attributes,
+		Type:               dr.Properties.Type,
+	}
+	if c.params != "" {
+		if len(req.Attributes) <= 0 {
+			req.Attributes = make(map[string]string)
+		}
+		req.Attributes[sdkCommon.URLRawQuery] = c.params
+	}
+	return req
+}
+
+// readResults returns the CommandValues of the given DeviceResources in order, the
+// values of derived resources are decoded from the raw values of their parents.
+// The results of the ProtocolDriver are returned untouched if no derived resource is
+// involved.
+func (c *CommandProcessor) readResults(drs []models.DeviceResource, results []*dsModels.CommandValue) ([]*dsModels.CommandValue, error) {
+	anyDerived := false
+	for _, dr := range drs {
+		if _, derived, _ := derivedBitField(dr); derived {
+			anyDerived = true
+			break
+		}
+	}
+	if !anyDerived {
+		return results, nil
+	}
+
+	resultMap := make(map[string]*dsModels.CommandValue, len(results))
+	for _, cv := range results {
+		if cv != nil {
+			resultMap[cv.DeviceResourceName] = cv
+		}
+	}
+
+	cvs := make([]*dsModels.CommandValue, 0, len(drs))
+	for _, dr := range drs {
+		f, derived, _ := derivedBitField(dr)
+		if !derived {
+			if cv, ok := resultMap[dr.Name]; ok {
+				cvs = append(cvs, cv)
+			}
+			continue
+		}
+
+		parentCV, ok := resultMap[f.parent]
+		if !ok {
+			return nil, fmt.Errorf("no value of parent deviceResource %s returned for %s", f.parent, dr.Name)
+		}
+		if _, ok := dr.Attributes[sdkCommon.AttributeJSONPath]; ok {
+			// the JSON document is read once for all its derived resources,
+			// each of them extracts its own value in the read transforms
+			doc := *parentCV
+			doc.DeviceResourceName = dr.Name
+			cvs = append(cvs, &doc)
+			continue
+		}
+		if parentCV.Type == contracts.ValueTypeBinary {
+			// a field of a packed struct
+			cv, err := codec.DecodeCommandValue(parentCV, dr.Name, dr.Properties.Type, dr.Attributes)
+			if err != nil {
+				return nil, err
+			}
+			cvs = append(cvs, cv)
+			continue
+		}
+		raw, err := commandValueToUint64(parentCV)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode derived resource %s: %v", dr.Name, err)
+		}
+		cv, err := uint64ToCommandValue(dr.Name, parentCV.Origin, dr.Properties.Type, f.extract(raw, dr.Properties.Type))
+		if err != nil {
+			return nil, err
+		}
+		cvs = append(cvs, cv)
+	}
+	return cvs, nil
+}
+
+// parentLocks serializes the read-modify-writes of each parent DeviceResource, so that the
+// concurrent writes of sibling derived resources, or of the parent itself, don't overwrite
+// each other. The key is "<device>/<parent>".
+var parentLocks sync.Map
+
+// lockParents locks the given parents of the device in name order and returns the function
+// unlocking them.
+func lockParents(deviceName string, parents []models.DeviceResource) func() {
+	names := make([]string, 0, len(parents))
+	seen := make(map[string]bool, len(parents))
+	for _, parent := range parents {
+		if !seen[parent.Name] {
+			seen[parent.Name] = true
+			names = append(names, parent.Name)
+		}
+	}
+	sort.Strings(names)
+
+	locks := make([]*sync.Mutex, 0, len(names))
+	for _, name := range names {
+		l, _ := parentLocks.LoadOrStore(deviceName+"/"+name, &sync.Mutex{})
+		lock := l.(*sync.Mutex)
+		lock.Lock()
+		locks = append(locks, lock)
+	}
+	return func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].Unlock()
+		}
+	}
+}
+
+// fieldWrite is the written value of a derived resource.
+type fieldWrite struct {
+	field bitField
+	dr    models.DeviceResource
+	cv    *dsModels.CommandValue
+}
+
+// foldDerivedWrites turns the writes of derived resources into a masked
+// read-modify-write of their parents: the current value of each parent is read
+// once, the bits of all written fields (or their bytes if the parent is a packed
+// struct) are replaced and the parent is written back. The parents stay locked until
+// the returned function is called, once the folded values are written. The direct writes of
+// parents take the same locks so that they don't interleave with a read-modify-write.
+func (c *CommandProcessor) foldDerivedWrites(reqs []dsModels.CommandRequest, cvs []*dsModels.CommandValue) ([]dsModels.CommandRequest, []*dsModels.CommandValue, func(), error) {
+	noop := func() {}
+	var parents, writtenParents []models.DeviceResource
+	writes := make(map[string][]fieldWrite)
+	foldedReqs := make([]dsModels.CommandRequest, 0, len(reqs))
+	foldedCVs := make([]*dsModels.CommandValue, 0, len(cvs))
+	for i, cv := range cvs {
+		dr, ok := cache.DeviceResourceOf(*c.device, cv.DeviceResourceName)
+		f, derived, err := derivedBitField(dr)
+		if err != nil {
+			return nil, nil, noop, err
+		}
+		if !ok || !derived {
+			if ok && c.isParentResource(dr.Name) {
+				writtenParents = append(writtenParents, dr)
+			}
+			foldedReqs = append(foldedReqs, reqs[i])
+			foldedCVs = append(foldedCVs, cv)
+			continue
+		}
+
+		parent, err := c.parentResource(f, dr.Name)
+		if err != nil {
+			return nil, nil, noop, err
+		}
+		if _, ok := writes[parent.Name]; !ok {
+			parents = append(parents, parent)
+		}
+		writes[parent.Name] = append(writes[parent.Name], fieldWrite{field: f, dr: dr, cv: cv})
+	}
+	if len(parents) == 0 && len(writtenParents) == 0 {
+		return reqs, cvs, noop, nil
+	}
+
+	unlock := lockParents(c.device.Name, append(writtenParents, parents...))
+	driver := container.ProtocolDriverFrom(c.dic.Get)
+	for _, parent := range parents {
+		req := c.commandRequest(parent)
+		results, err := driver.HandleReadCommands(c.device.Name, cache.WithoutOverrides(c.device.Protocols), []dsModels.CommandRequest{req})
+		if err != nil {
+			unlock()
+			return nil, nil, noop, fmt.Errorf("failed to read parent deviceResource %s: %v", parent.Name, err)
+		}
+		if len(results) == 0 || results[0] == nil {
+			unlock()
+			return nil, nil, noop, fmt.Errorf("no value of parent deviceResource %s returned", parent.Name)
+		}
+		var cv *dsModels.CommandValue
+		if results[0].Type == contracts.ValueTypeBinary {
+			cv, err = packStructFields(parent.Name, results[0].BinValue, writes[parent.Name])
+		} else {
+			cv, err = insertBitFields(parent, results[0], writes[parent.Name])
+		}
+		if err != nil {
+			unlock()
+			return nil, nil, noop, err
+		}
+		foldedReqs = append(foldedReqs, req)
+		foldedCVs = append(foldedCVs, cv)
+	}
+	return foldedReqs, foldedCVs, unlock, nil
+}
+
+// insertBitFields replaces the bits of the written fields in the current integer value of their parent.
+func insertBitFields(parent models.DeviceResource, current *dsModels.CommandValue, writes []fieldWrite) (*dsModels.CommandValue, error) {
+	raw, err := commandValueToUint64(current)
+	if err != nil {
+		return nil, err
+	}
+	width, _ := cache.IntegerWidth(parent.Properties.Type)
+	for _, w := range writes {
+		if w.field.offset+w.field.length > width {
+			return nil, fmt.Errorf("bit range [%d, %d) of deviceResource %s is out of the %d bits of parent deviceResource %s", w.field.offset, w.field.offset+w.field.length, w.dr.Name, width, parent.Name)
+		}
+		v, err := commandValueToUint64(w.cv)
+		if err != nil {
+			return nil, err
+		}
+		if raw, err = w.field.insert(raw, v, w.dr.Properties.Type); err != nil {
+			return nil, fmt.Errorf("failed to write bits of parent deviceResource %s: %v", parent.Name, err)
+		}
+	}
+	return uint64ToCommandValue(parent.Name, time.Now().UnixNano(), parent.Properties.Type, raw)
+}
+
+// packStructFields encodes the written fields into a copy of the current payload of their parent packed struct.
+func packStructFields(parentName string, current []byte, writes []fieldWrite) (*dsModels.CommandValue, error) {
+	payload := make([]byte, len(current))
+	copy(payload, current)
+	for _, w := range writes {
+		l, err := codec.LayoutFromAttributes(w.dr.Attributes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid layout of deviceResource %s: %v", w.dr.Name, err)
+		}
+		v, err := codec.Value(w.cv)
+		if err != nil {
+			return nil, err
+		}
+		if err = codec.EncodeInto(payload, v, w.cv.Type, l); err != nil {
+			return nil, fmt.Errorf("failed to write field %s of parent deviceResource %s: %v", w.dr.Name, parentName, err)
+		}
+	}
+	return dsModels.NewBinaryValue(parentName, time.Now().UnixNano(), payload)
+}
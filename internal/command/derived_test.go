@@ -0,0 +1,244 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package command
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	sdkCommon "github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+func derivedResource(name string, valueType string, parent string, offset, length string) models.DeviceResource {
+	attributes := map[string]string{sdkCommon.AttributeDerivedFrom: parent}
+	if offset != "" {
+		attributes[sdkCommon.AttributeBitOffset] = offset
+	}
+	if length != "" {
+		attributes[sdkCommon.AttributeBitLength] = length
+	}
+	return models.DeviceResource{Name: name, Properties: models.PropertyValue{Type: valueType, ReadWrite: "RW"}, Attributes: attributes}
+}
+
+func TestDerivedBitField(t *testing.T) {
+	tests := []struct {
+		name        string
+		dr          models.DeviceResource
+		expected    bitField
+		derived     bool
+		expectError bool
+	}{
+		{"not derived", models.DeviceResource{Name: "status"}, bitField{}, false, false},
+		{"default length", derivedResource("alarm", contracts.ValueTypeBool, "status", "3", ""), bitField{parent: "status", offset: 3, length: 1}, true, false},
+		{"field", derivedResource("mode", contracts.ValueTypeUint8, "status", "4", "4"), bitField{parent: "status", offset: 4, length: 4}, true, false},
+		{"invalid offset", derivedResource("mode", contracts.ValueTypeUint8, "status", "x", ""), bitField{}, true, true},
+		{"zero length", derivedResource("mode", contracts.ValueTypeUint8, "status", "", "0"), bitField{}, true, true},
+		{"out of 64 bits", derivedResource("mode", contracts.ValueTypeUint8, "status", "60", "8"), bitField{}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, derived, err := derivedBitField(tt.dr)
+			assert.Equal(t, tt.derived, derived)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, f)
+		})
+	}
+}
+
+func TestBitFieldExtract(t *testing.T) {
+	tests := []struct {
+		name      string
+		field     bitField
+		raw       uint64
+		valueType string
+		expected  uint64
+	}{
+		{"single bit set", bitField{offset: 3, length: 1}, 0x08, contracts.ValueTypeBool, 1},
+		{"single bit clear", bitField{offset: 2, length: 1}, 0x08, contracts.ValueTypeBool, 0},
+		{"unsigned field", bitField{offset: 4, length: 4}, 0xA5, contracts.ValueTypeUint8, 0xA},
+		{"signed positive", bitField{offset: 4, length: 4}, 0x75, contracts.ValueTypeInt8, 7},
+		{"signed negative is sign-extended", bitField{offset: 4, length: 4}, 0xF5, contracts.ValueTypeInt8, uint64(0xFFFFFFFFFFFFFFFF)},
+		{"full 64 bits", bitField{offset: 0, length: 64}, 0x8000000000000001, contracts.ValueTypeInt64, 0x8000000000000001},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.field.extract(tt.raw, tt.valueType))
+		})
+	}
+
+	cv, err := uint64ToCommandValue("mode", 0, contracts.ValueTypeInt8, bitField{offset: 4, length: 4}.extract(0xE5, contracts.ValueTypeInt8))
+	require.NoError(t, err)
+	v, err := cv.Int8Value()
+	require.NoError(t, err)
+	assert.Equal(t, int8(-2), v)
+}
+
+func TestBitFieldInsert(t *testing.T) {
+	tests := []struct {
+		name        string
+		field       bitField
+		raw         uint64
+		value       uint64
+		valueType   string
+		expected    uint64
+		expectError bool
+	}{
+		{"set bit", bitField{offset: 3, length: 1}, 0xF0, 1, contracts.ValueTypeBool, 0xF8, false},
+		{"clear bit", bitField{offset: 4, length: 1}, 0xF0, 0, contracts.ValueTypeBool, 0xE0, false},
+		{"replace field", bitField{offset: 4, length: 4}, 0xA5, 0x3, contracts.ValueTypeUint8, 0x35, false},
+		{"negative field", bitField{offset: 4, length: 4}, 0x05, uint64(0xFFFFFFFFFFFFFFFE), contracts.ValueTypeInt8, 0xE5, false},
+		{"unsigned overflow", bitField{offset: 4, length: 4}, 0x05, 0x10, contracts.ValueTypeUint8, 0x05, true},
+		{"signed overflow", bitField{offset: 4, length: 4}, 0x05, 8, contracts.ValueTypeInt8, 0x05, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := tt.field.insert(tt.raw, tt.value, tt.valueType)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expected, raw)
+		})
+	}
+}
+
+func TestInsertBitFields(t *testing.T) {
+	parent := models.DeviceResource{Name: "status", Properties: models.PropertyValue{Type: contracts.ValueTypeUint8}}
+	current, err := dsModels.NewUint8Value("status", 0, 0x0F)
+	require.NoError(t, err)
+	on, err := dsModels.NewBoolValue("alarm", 0, true)
+	require.NoError(t, err)
+	off, err := dsModels.NewBoolValue("ready", 0, false)
+	require.NoError(t, err)
+
+	cv, err := insertBitFields(parent, current, []fieldWrite{
+		{field: bitField{parent: "status", offset: 7, length: 1}, dr: derivedResource("alarm", contracts.ValueTypeBool, "status", "7", ""), cv: on},
+		{field: bitField{parent: "status", offset: 0, length: 1}, dr: derivedResource("ready", contracts.ValueTypeBool, "status", "0", ""), cv: off},
+	})
+	require.NoError(t, err)
+	v, err := cv.Uint8Value()
+	require.NoError(t, err)
+	assert.Equal(t, uint8(0x8E), v)
+
+	_, err = insertBitFields(parent, current, []fieldWrite{
+		{field: bitField{parent: "status", offset: 8, length: 1}, dr: derivedResource("alarm", contracts.ValueTypeBool, "status", "8", ""), cv: on},
+	})
+	assert.Error(t, err, "a field out of the width of its parent")
+}
+
+func bitFieldProfile(name string, bits int) models.DeviceProfile {
+	profile := models.DeviceProfile{
+		Name: name,
+		DeviceResources: []models.DeviceResource{
+			{Name: "status", Properties: models.PropertyValue{Type: contracts.ValueTypeUint16, ReadWrite: "RW"}},
+			derivedResource("mode", contracts.ValueTypeInt8, "status", "12", "4"),
+		},
+	}
+	for i := 0; i < bits; i++ {
+		profile.DeviceResources = append(profile.DeviceResources, derivedResource(fmt.Sprintf("bit%d", i), contracts.ValueTypeBool, "status", fmt.Sprint(i), ""))
+	}
+	return profile
+}
+
+func TestReadDerivedResources(t *testing.T) {
+	profile := bitFieldProfile("bitfield-read", 2)
+	device := addTestDevice(t, profile)
+	status, err := dsModels.NewUint16Value("status", 0, 0xE001)
+	require.NoError(t, err)
+	driver := newRegisterDriver(status)
+	c := NewCommandProcessor(&device, nil, "", "", "", newTestContainer(driver))
+
+	drs := []models.DeviceResource{profile.DeviceResources[1], profile.DeviceResources[2], profile.DeviceResources[3]}
+	reqs, err := c.readRequests(drs)
+	require.NoError(t, err)
+	require.Len(t, reqs, 1, "the parent is read once for all its derived resources")
+	assert.Equal(t, "status", reqs[0].DeviceResourceName)
+
+	results, err := driver.HandleReadCommands(device.Name, nil, reqs)
+	require.NoError(t, err)
+	cvs, err := c.readResults(drs, results)
+	require.NoError(t, err)
+	require.Len(t, cvs, 3)
+	mode, err := cvs[0].Int8Value()
+	require.NoError(t, err)
+	assert.Equal(t, int8(-2), mode)
+	bit0, err := cvs[1].BoolValue()
+	require.NoError(t, err)
+	assert.True(t, bit0)
+	bit1, err := cvs[2].BoolValue()
+	require.NoError(t, err)
+	assert.False(t, bit1)
+}
+
+func TestConcurrentBitFieldWrites(t *testing.T) {
+	const bits = 8
+	profile := bitFieldProfile("bitfield-write", bits)
+	device := addTestDevice(t, profile)
+	status, err := dsModels.NewUint16Value("status", 0, 0)
+	require.NoError(t, err)
+	driver := newRegisterDriver(status)
+	// widen the window between the read and the write of the parent
+	driver.writeDelay = 5 * time.Millisecond
+	dic := newTestContainer(driver)
+
+	var wg sync.WaitGroup
+	for i := 0; i < bits; i++ {
+		dr := profile.DeviceResources[2+i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := NewCommandProcessor(&device, &dr, "", "", fmt.Sprintf(`{"%s":true}`, dr.Name), dic)
+			assert.NoError(t, c.WriteDeviceResource())
+		}()
+	}
+	wg.Wait()
+
+	v, err := driver.value("status").Uint16Value()
+	require.NoError(t, err)
+	assert.Equal(t, uint16(0xFF), v, "no write of a sibling bit is lost")
+}
+
+func TestParentWriteDuringBitFieldWrite(t *testing.T) {
+	profile := bitFieldProfile("bitfield-parent-write", 1)
+	device := addTestDevice(t, profile)
+	status, err := dsModels.NewUint16Value("status", 0, 0)
+	require.NoError(t, err)
+	driver := newRegisterDriver(status)
+	driver.writeDelay = 10 * time.Millisecond
+	dic := newTestContainer(driver)
+	parent, bit0 := profile.DeviceResources[0], profile.DeviceResources[2]
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c := NewCommandProcessor(&device, &parent, "", "", `{"status":128}`, dic)
+		assert.NoError(t, c.WriteDeviceResource())
+	}()
+	// the bit is written while the write of the parent is in progress
+	time.Sleep(2 * time.Millisecond)
+	c := NewCommandProcessor(&device, &bit0, "", "", `{"bit0":true}`, dic)
+	require.NoError(t, c.WriteDeviceResource())
+	wg.Wait()
+
+	v, err := driver.value("status").Uint16Value()
+	require.NoError(t, err)
+	assert.Equal(t, uint16(0x81), v, "the bit is written over the written parent")
+}
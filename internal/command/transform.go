@@ -0,0 +1,148 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package command
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	bootstrapContainer "github.com/edgexfoundry/go-mod-bootstrap/v2/bootstrap/container"
+	"github.com/edgexfoundry/go-mod-bootstrap/v2/di"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/requests"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/responses"
+	edgexErr "github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	sdkCommon "github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/computed"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/transformer"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+// TransformDataHandler applies the read pipeline of a DeviceResource to the raw value as if
+// it were read from the device: JSON path extraction, binary decoding, custom transforms,
+// data transformation, assertion and mapping. The device is not accessed and the assertion
+// doesn't affect its OperatingState. Binary raw values are base64 encoded.
+func TransformDataHandler(value string, req requests.TransformDataRequest, correlationID string, dic *di.Container) (responses.TransformDataResponse, edgexErr.EdgeX) {
+	var deviceName, profileName string
+	var mappings map[string]string
+	dr := models.DeviceResource{Name: req.ResourceName}
+	if req.DeviceName != "" {
+		device, ok := cache.Devices().ForName(req.DeviceName)
+		if !ok {
+			return responses.TransformDataResponse{}, edgexErr.NewCommonEdgeX(edgexErr.KindEntityDoesNotExist, fmt.Sprintf("device %s not found", req.DeviceName), nil)
+		}
+		if dr, ok = cache.DeviceResourceOf(device, req.ResourceName); !ok {
+			errMsg := fmt.Sprintf("deviceResource %s not found in profile %s", req.ResourceName, device.ProfileName)
+			return responses.TransformDataResponse{}, edgexErr.NewCommonEdgeX(edgexErr.KindEntityDoesNotExist, errMsg, nil)
+		}
+		if ro, err := cache.Profiles().ResourceOperation(device.ProfileName, dr.Name, sdkCommon.GetCmdMethod); err == nil {
+			mappings = ro.Mappings
+		}
+		deviceName, profileName = device.Name, device.ProfileName
+	}
+	if computed.IsComputed(dr) {
+		errMsg := fmt.Sprintf("computed deviceResource %s has no raw value", dr.Name)
+		return responses.TransformDataResponse{}, edgexErr.NewCommonEdgeX(edgexErr.KindContractInvalid, errMsg, nil)
+	}
+
+	// the DeviceResource described by the request overrides the one of the profile
+	if req.Properties != nil {
+		dr.Properties = dtos.ToPropertyValueModel(*req.Properties)
+	}
+	if len(req.Attributes) > 0 {
+		attributes := make(map[string]string, len(dr.Attributes)+len(req.Attributes))
+		for k, v := range dr.Attributes {
+			attributes[k] = v
+		}
+		for k, v := range req.Attributes {
+			attributes[k] = v
+		}
+		dr.Attributes = attributes
+	}
+	if len(req.Mappings) > 0 {
+		mappings = req.Mappings
+	}
+	if req.Value != "" {
+		value = req.Value
+	}
+
+	cv, err := rawCommandValue(dr, req.ValueType, value)
+	if err != nil {
+		return responses.TransformDataResponse{}, edgexErr.NewCommonEdgeX(edgexErr.KindContractInvalid, "failed to create CommandValue from the raw value", err)
+	}
+
+	if cv, err = transformer.ExtractJSONPath(cv, dr); err == nil {
+		cv, err = transformer.DecodeBinaryValue(cv, dr)
+	}
+	if err != nil {
+		return responses.TransformDataResponse{}, edgexErr.NewCommonEdgeX(edgexErr.KindContractInvalid, "failed to decode the raw value", err)
+	}
+
+	lc := bootstrapContainer.LoggingClientFrom(dic.Get)
+	configuration := container.ConfigurationFrom(dic.Get)
+	cv, err = transformer.ApplyReadTransforms(cv, dr)
+	if err == nil && configuration.Device.DataTransform {
+		err = transformer.TransformReadResult(cv, dr.Properties, lc)
+	}
+	if errors.As(err, &transformer.OverflowError{}) {
+		cv = dsModels.NewStringValue(cv.DeviceResourceName, cv.Origin, transformer.Overflow)
+	} else if errors.As(err, &transformer.NaNError{}) {
+		cv = dsModels.NewStringValue(cv.DeviceResourceName, cv.Origin, transformer.NaN)
+	} else if err != nil {
+		return responses.TransformDataResponse{}, edgexErr.NewCommonEdgeX(edgexErr.KindContractInvalid, "failed to transform the raw value", err)
+	}
+
+	var assertion *responses.AssertionResult
+	if rule := dr.Properties.Assertion; rule != "" {
+		a, err := transformer.ParseAssertion(rule)
+		if err != nil {
+			return responses.TransformDataResponse{}, edgexErr.NewCommonEdgeX(edgexErr.KindContractInvalid, "invalid assertion", err)
+		}
+		assertion = &responses.AssertionResult{Assertion: rule, Satisfied: a.Check(cv.ValueToString(), false, 0)}
+	}
+
+	mapped := false
+	if len(mappings) > 0 {
+		newCV, ok, err := transformer.MapCommandValue(cv, mappings)
+		if err != nil {
+			return responses.TransformDataResponse{}, edgexErr.NewCommonEdgeX(edgexErr.KindContractInvalid, "failed to map the transformed value", err)
+		} else if ok {
+			cv = newCV
+			mapped = true
+		}
+	}
+
+	reading := commandValueToReading(cv, deviceName, profileName, dr.Properties.MediaType, "")
+	return responses.NewTransformDataResponse(correlationID, "", http.StatusOK, reading, mapped, assertion), nil
+}
+
+// rawCommandValue creates the CommandValue of the DeviceResource from the raw value of the
+// given type, default is the type of the DeviceResource
+func rawCommandValue(dr models.DeviceResource, valueType string, value string) (*dsModels.CommandValue, error) {
+	if valueType == "" {
+		valueType = dr.Properties.Type
+	}
+	if valueType == contracts.ValueTypeBinary {
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, err
+		}
+		return dsModels.NewBinaryValue(dr.Name, time.Now().UnixNano(), b)
+	}
+
+	raw := dr
+	raw.Properties.Type = valueType
+	return createCommandValueFromDeviceResource(&raw, value)
+}
@@ -35,6 +35,9 @@ const (
 	APIIdCommandRoute       = contracts.ApiDeviceRoute + "/{id}/{command}"
 	APINameCommandRoute     = contracts.ApiDeviceRoute + "/name/{name}/{command}"
 	APIDiscoveryRoute       = contracts.ApiBase + "/discovery"
+	APIDiscoveryJobsRoute   = APIDiscoveryRoute + "/jobs"
+	APIDiscoveryJobRoute    = APIDiscoveryJobsRoute + "/{id}"
+	APIDiscoveryDryRunRoute = APIDiscoveryRoute + "/dryrun"
 	APITransformRoute       = contracts.ApiBase + "/debug/transformData/{transformData}"
 
 	APIV2SecretRoute = contracts.ApiBase + "/secret"
@@ -42,6 +45,7 @@ const (
 	IdVar        string = "id"
 	NameVar      string = "name"
 	CommandVar   string = "command"
+	TransformVar string = "transformData"
 	GetCmdMethod string = "get"
 	SetCmdMethod string = "set"
 
@@ -53,6 +57,83 @@ const (
 	SDKReservedPrefix = "ds-"
 )
 
+// Constants related to the DeviceResource attributes reserved by the SDK, they carry the
+// SDKReservedPrefix so that they don't clash with the attributes of the ProtocolDrivers
+const (
+	// AttributeDerivedFrom names the parent DeviceResource of a derived (bitfield) resource
+	AttributeDerivedFrom = SDKReservedPrefix + "derivedFrom"
+	// AttributeBitOffset is the position of the lowest bit of a derived resource in its parent, LSB is 0
+	AttributeBitOffset = SDKReservedPrefix + "bitOffset"
+	// AttributeBitLength is the number of bits of a derived resource, default is 1
+	AttributeBitLength = SDKReservedPrefix + "bitLength"
+	// AttributeExpression is the expression of a computed resource over its sibling DeviceResources
+	AttributeExpression = SDKReservedPrefix + "expression"
+	// AttributeJSONPath selects the value of a DeviceResource in the JSON document read from the device
+	AttributeJSONPath = SDKReservedPrefix + "jsonPath"
+	// AttributeAssertionHysteresis is the deadband a violated numeric assertion requires to be satisfied again
+	AttributeAssertionHysteresis = SDKReservedPrefix + "assertionHysteresis"
+	// AttributeAssertionAlarm is the name of the Bool alarm reading emitted on assertion violation and recovery
+	AttributeAssertionAlarm = SDKReservedPrefix + "assertionAlarm"
+	// AttributeAssertionOperatingState set to "false" keeps the device's OperatingState on assertion violation
+	AttributeAssertionOperatingState = SDKReservedPrefix + "assertionOperatingState"
+	// AttributeTransform lists the custom transforms registered by the ProtocolDriver, separated by commas
+	AttributeTransform = SDKReservedPrefix + "transform"
+)
+
+// Constants related to the DeviceProfile labels reserved by the SDK
+const (
+	// ProfileLabelExtends prefixes the name of the base profile a profile extends, e.g. "ds-extends:meter"
+	ProfileLabelExtends = SDKReservedPrefix + "extends:"
+	// ProfileLabelInclude prefixes the name of a profile whose resources and commands are included
+	ProfileLabelInclude = SDKReservedPrefix + "include:"
+)
+
+// ProfileValueUnset set as a property, attribute, description or tag of a DeviceResource
+// clears the value inherited from the base and included profiles, as an empty value is
+// taken as not overridden
+const ProfileValueUnset = SDKReservedPrefix + "unset"
+
+// WatcherLabelNameTemplate prefixes the text/template of the names of the devices added by a
+// ProvisionWatcher, executed over the protocol properties, e.g. "ds-name-template:{{.mac}}-{{.port}}"
+const WatcherLabelNameTemplate = SDKReservedPrefix + "name-template:"
+
+// Constants related to the ProvisionWatcher labels scheduling discoveries
+const (
+	// WatcherLabelDiscoverySchedule prefixes the schedule of the discoveries scoped to a
+	// ProvisionWatcher, a cron expression or an interval, e.g. "ds-discovery-schedule:0 2 * * *"
+	WatcherLabelDiscoverySchedule = SDKReservedPrefix + "discovery-schedule:"
+	// WatcherLabelDiscoveryScope prefixes a scoping hint passed to the ScopedDiscovery
+	// implementations, e.g. "ds-discovery-scope:subnet=192.168.1.0/24"
+	WatcherLabelDiscoveryScope = SDKReservedPrefix + "discovery-scope:"
+)
+
+// Constants related to the ProvisionWatcher identifiers reserved by the SDK
+const (
+	// WatcherIdentifierLabels matches the labels of the discovered devices, any label must match
+	WatcherIdentifierLabels = SDKReservedPrefix + "labels"
+	// WatcherIdentifierDescription matches the description of the discovered devices
+	WatcherIdentifierDescription = SDKReservedPrefix + "description"
+)
+
+// Constants related to the device labels reserved by the SDK
+const (
+	// DeviceLabelDiscoveryDown labels a device marked DOWN as it was missed by the discoveries,
+	// the device is marked UP again once rediscovered
+	DeviceLabelDiscoveryDown = SDKReservedPrefix + "discovery-down"
+	// DeviceLabelAssertionDown labels a device marked DOWN by the violation of an assertion, the
+	// device is marked UP again once all its assertions are satisfied
+	DeviceLabelAssertionDown = SDKReservedPrefix + "assertion-down"
+)
+
+// AssertionExpressionPrefix prefixes the assertions using the comparison, range and regular
+// expression syntax, the other assertions are matched as exact strings
+const AssertionExpressionPrefix = SDKReservedPrefix + "assert:"
+
+// ProtocolOverrides is the reserved protocol of a device whose properties override the
+// attributes and properties of the DeviceResources of its profile, keyed by
+// "<resource>.attributes.<attribute>" or "<resource>.properties.<property>"
+const ProtocolOverrides = SDKReservedPrefix + "overrides"
+
 // SDKVersion indicates the version of the SDK - will be overwritten by build
 var SDKVersion string = "0.0.0"
 
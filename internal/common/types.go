@@ -89,8 +89,20 @@ type DeviceInfo struct {
 	// UpdateLastConnected specifies whether to update device's LastConnected
 	// timestamp in metadata.
 	UpdateLastConnected bool
+	// TransformDebug enables the debug API which applies the transforms, assertion
+	// and mappings of a DeviceResource to a posted raw value.
+	TransformDebug bool
+	// StrictCoreCommands restricts the commands reachable through the API to the
+	// CoreCommands of the profile with the matching Get or Put flag.
+	StrictCoreCommands bool
+	// CacheSnapshot specifies the file the cached devices, profiles and provision
+	// watchers are persisted to, so that the service can start from it while
+	// metadata is unavailable. Empty disables the snapshot.
+	CacheSnapshot string
 
 	Discovery DiscoveryInfo
+
+	CacheReconcile ReconcileInfo
 }
 
 // DiscoveryInfo is a struct which contains configuration of device auto discovery.
@@ -100,6 +112,28 @@ type DiscoveryInfo struct {
 	// Interval indicates how often the discovery process will be triggered.
 	// It represents as a duration string.
 	Interval string
+	// Schedule is the cron expression of the discovery, e.g. "0 2 * * *" every night at 2am,
+	// or a predefined schedule like "@daily". It takes precedence over Interval.
+	Schedule string
+	// IdentityKey is the protocol property identifying a device across discoveries,
+	// e.g. its MAC address. The existing devices rediscovered with other protocol
+	// properties are updated instead of added. Empty disables the matching.
+	IdentityKey string
+	// MarkDownAfter is the number of discovery rounds in a row after which a device
+	// with an identity key which isn't discovered is marked DOWN. 0 disables it.
+	MarkDownAfter int
+	// AddRetries is the number of times the discovered devices which failed to be added
+	// with a server or communication error are retried. 0 disables the retries.
+	AddRetries int
+}
+
+// ReconcileInfo is a struct which contains configuration of the cache reconciliation with metadata.
+type ReconcileInfo struct {
+	// Enabled controls whether or not the cache is periodically reconciled with metadata.
+	Enabled bool
+	// Interval indicates how often the cache is reconciled.
+	// It represents as a duration string.
+	Interval string
 }
 
 // DeviceConfig is the definition of Devices which will be auto created when the Device Service starts up
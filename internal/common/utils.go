@@ -11,7 +11,9 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"math"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -107,6 +109,28 @@ func CompareStrings(a []string, b []string) bool {
 	return true
 }
 
+// HasLabel returns whether the labels contain the given label.
+func HasLabel(labels []string, label string) bool {
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// WithoutLabel returns the labels without the given label, never nil so that an update
+// clears the labels.
+func WithoutLabel(labels []string, label string) []string {
+	kept := make([]string, 0, len(labels))
+	for _, l := range labels {
+		if l != label {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
+
 func CompareStrStrMap(a map[string]string, b map[string]string) bool {
 	if len(a) != len(b) {
 		return false
@@ -177,3 +201,34 @@ func UpdateLastConnected(device models.Device, configuration *ConfigurationStruc
 		lc.Error("Failed to update last connected value for device: " + device.Name)
 	}
 }
+
+// ParseMappingRange parses the range mapping rules like "0..10", "..0" or "10..", the returned
+// bool is false for the other keys, e.g. a regular expression or an exact value containing "..".
+// A range whose lower bound is greater than its upper bound is an error.
+func ParseMappingRange(key string) (float64, float64, bool, error) {
+	i := strings.Index(key, "..")
+	if i < 0 || strings.HasPrefix(key, "/") {
+		return 0, 0, false, nil
+	}
+
+	lower, upper := strings.TrimSpace(key[:i]), strings.TrimSpace(key[i+2:])
+	if lower == "" && upper == "" {
+		return 0, 0, false, nil
+	}
+	min, max := math.Inf(-1), math.Inf(1)
+	var err error
+	if lower != "" {
+		if min, err = strconv.ParseFloat(lower, 64); err != nil {
+			return 0, 0, false, nil
+		}
+	}
+	if upper != "" {
+		if max, err = strconv.ParseFloat(upper, 64); err != nil {
+			return 0, 0, false, nil
+		}
+	}
+	if min > max {
+		return min, max, false, fmt.Errorf("invalid mapping rule %s: lower bound greater than upper bound", key)
+	}
+	return min, max, true, nil
+}
@@ -0,0 +1,301 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package computed implements the computed DeviceResources, which are not provided by the
+// ProtocolDriver but evaluated from an expression over the sibling DeviceResources of the
+// same device, e.g. "power = voltage * current".
+package computed
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/transformer"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+var (
+	// compiled caches the compiled expressions by source
+	compiled sync.Map
+
+	// lastValues keeps the last known value of each input resource by device name
+	lastValues = make(map[string]map[string]float64)
+	mutex      sync.RWMutex
+)
+
+// ExpressionOf returns the compiled expression of the given DeviceResource, the returned
+// bool indicates whether the DeviceResource is a computed resource.
+func ExpressionOf(dr models.DeviceResource) (*Expression, bool, error) {
+	source, ok := dr.Attributes[common.AttributeExpression]
+	if !ok {
+		return nil, false, nil
+	}
+	if e, ok := compiled.Load(source); ok {
+		return e.(*Expression), true, nil
+	}
+
+	e, err := Parse(source)
+	if err != nil {
+		return nil, true, fmt.Errorf("computed deviceResource %s: %v", dr.Name, err)
+	}
+	compiled.Store(source, e)
+	return e, true, nil
+}
+
+// IsComputed returns whether the given DeviceResource is a computed resource.
+func IsComputed(dr models.DeviceResource) bool {
+	_, ok := dr.Attributes[common.AttributeExpression]
+	return ok
+}
+
+// Dependencies returns the DeviceResources of the device referred by the expression of a computed
+// resource, they must be defined in the same profile and must not be computed resources themselves.
+func Dependencies(device models.Device, dr models.DeviceResource) ([]models.DeviceResource, error) {
+	e, ok, err := ExpressionOf(dr)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	deps := make([]models.DeviceResource, 0, len(e.Variables()))
+	for _, name := range e.Variables() {
+		dep, ok := cache.DeviceResourceOf(device, name)
+		if !ok {
+			return nil, fmt.Errorf("deviceResource %s referred by computed deviceResource %s not defined", name, dr.Name)
+		}
+		if IsComputed(dep) {
+			return nil, fmt.Errorf("computed deviceResource %s refers to another computed deviceResource %s", dr.Name, name)
+		}
+		if dep.Properties.ReadWrite == common.DeviceResourceWriteOnly {
+			return nil, fmt.Errorf("deviceResource %s referred by computed deviceResource %s is write-only", name, dr.Name)
+		}
+		deps = append(deps, dep)
+	}
+	return deps, nil
+}
+
+// Dependents returns the computed resources of the device's profile which refer to at least
+// one of the given DeviceResources.
+func Dependents(device models.Device, resourceNames []string) []models.DeviceResource {
+	profile, ok := cache.Profiles().Resolved(device.ProfileName)
+	if !ok {
+		return nil
+	}
+
+	var dependents []models.DeviceResource
+	for _, dr := range profile.DeviceResources {
+		dr = cache.ApplyOverrides(dr, device.Protocols[common.ProtocolOverrides])
+		e, ok, err := ExpressionOf(dr)
+		if err != nil || !ok {
+			continue
+		}
+		if refersAny(e, resourceNames) {
+			dependents = append(dependents, dr)
+		}
+	}
+	return dependents
+}
+
+func refersAny(e *Expression, resourceNames []string) bool {
+	for _, v := range e.Variables() {
+		for _, name := range resourceNames {
+			if v == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Remember records the value of an input resource of the device. A value which isn't
+// numeric, e.g. the Overflow or NaN reported for a rejected reading, drops the recorded
+// value so that the computed resources aren't evaluated with a stale input.
+func Remember(deviceName string, cv *dsModels.CommandValue) {
+	v, err := ValueOf(cv)
+	if err != nil {
+		Drop(deviceName, cv.DeviceResourceName)
+		return
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	values, ok := lastValues[deviceName]
+	if !ok {
+		values = make(map[string]float64)
+		lastValues[deviceName] = values
+	}
+	values[cv.DeviceResourceName] = v
+}
+
+// Drop drops the recorded value of an input resource of the device.
+func Drop(deviceName string, resourceName string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	delete(lastValues[deviceName], resourceName)
+}
+
+// Forget drops the recorded values of the device.
+func Forget(deviceName string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	delete(lastValues, deviceName)
+}
+
+// Evaluate evaluates the computed resource with the last known values of its inputs and
+// returns the result as a CommandValue of the resource's value type. The NaN and overflow
+// results are reported with the transformer.NaNError and transformer.OverflowError.
+func Evaluate(deviceName string, dr models.DeviceResource, origin int64) (*dsModels.CommandValue, error) {
+	e, ok, err := ExpressionOf(dr)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("deviceResource %s is not a computed resource", dr.Name)
+	}
+
+	mutex.RLock()
+	v, err := e.Evaluate(lastValues[deviceName])
+	mutex.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate computed deviceResource %s: %v", dr.Name, err)
+	}
+
+	return newCommandValue(dr, origin, v)
+}
+
+// ValueOf returns the numeric value of a scalar CommandValue, Bool values are 0 or 1.
+func ValueOf(cv *dsModels.CommandValue) (float64, error) {
+	var v interface{}
+	var err error
+	switch cv.Type {
+	case contracts.ValueTypeBool:
+		var b bool
+		if b, err = cv.BoolValue(); b {
+			return 1, err
+		}
+		return 0, err
+	case contracts.ValueTypeUint8:
+		v, err = cv.Uint8Value()
+	case contracts.ValueTypeUint16:
+		v, err = cv.Uint16Value()
+	case contracts.ValueTypeUint32:
+		v, err = cv.Uint32Value()
+	case contracts.ValueTypeUint64:
+		v, err = cv.Uint64Value()
+	case contracts.ValueTypeInt8:
+		v, err = cv.Int8Value()
+	case contracts.ValueTypeInt16:
+		v, err = cv.Int16Value()
+	case contracts.ValueTypeInt32:
+		v, err = cv.Int32Value()
+	case contracts.ValueTypeInt64:
+		v, err = cv.Int64Value()
+	case contracts.ValueTypeFloat32:
+		v, err = cv.Float32Value()
+	case contracts.ValueTypeFloat64:
+		v, err = cv.Float64Value()
+	default:
+		return 0, fmt.Errorf("CommandValue (%s) is not a numeric value", cv.String())
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	switch n := v.(type) {
+	case uint8:
+		return float64(n), nil
+	case uint16:
+		return float64(n), nil
+	case uint32:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	case int8:
+		return float64(n), nil
+	case int16:
+		return float64(n), nil
+	case int32:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case float32:
+		return float64(n), nil
+	default:
+		return n.(float64), nil
+	}
+}
+
+// newCommandValue converts the result of an expression to the value type of the DeviceResource,
+// integer results are rounded to the nearest integer.
+func newCommandValue(dr models.DeviceResource, origin int64, v float64) (*dsModels.CommandValue, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil, fmt.Errorf("computed deviceResource %s: %w", dr.Name, transformer.NaNError{})
+	}
+
+	valueType := dr.Properties.Type
+	if valueType == "" {
+		valueType = contracts.ValueTypeFloat64
+	}
+	if valueType != contracts.ValueTypeFloat32 && valueType != contracts.ValueTypeFloat64 {
+		v = math.Round(v)
+	}
+
+	var min, max float64
+	switch valueType {
+	case contracts.ValueTypeBool:
+		return dsModels.NewBoolValue(dr.Name, origin, v != 0)
+	case contracts.ValueTypeUint8:
+		min, max = 0, math.MaxUint8
+	case contracts.ValueTypeUint16:
+		min, max = 0, math.MaxUint16
+	case contracts.ValueTypeUint32:
+		min, max = 0, math.MaxUint32
+	case contracts.ValueTypeUint64:
+		min, max = 0, math.MaxUint64
+	case contracts.ValueTypeInt8:
+		min, max = math.MinInt8, math.MaxInt8
+	case contracts.ValueTypeInt16:
+		min, max = math.MinInt16, math.MaxInt16
+	case contracts.ValueTypeInt32:
+		min, max = math.MinInt32, math.MaxInt32
+	case contracts.ValueTypeInt64:
+		min, max = math.MinInt64, math.MaxInt64
+	case contracts.ValueTypeFloat32:
+		min, max = -math.MaxFloat32, math.MaxFloat32
+	case contracts.ValueTypeFloat64:
+		return dsModels.NewFloat64Value(dr.Name, origin, v)
+	default:
+		return nil, fmt.Errorf("value type %s is not supported by computed deviceResource %s", valueType, dr.Name)
+	}
+	if v < min || v > max {
+		return nil, fmt.Errorf("computed deviceResource %s: %w", dr.Name, transformer.NewOverflowError(v, v))
+	}
+
+	switch valueType {
+	case contracts.ValueTypeUint8:
+		return dsModels.NewUint8Value(dr.Name, origin, uint8(v))
+	case contracts.ValueTypeUint16:
+		return dsModels.NewUint16Value(dr.Name, origin, uint16(v))
+	case contracts.ValueTypeUint32:
+		return dsModels.NewUint32Value(dr.Name, origin, uint32(v))
+	case contracts.ValueTypeUint64:
+		return dsModels.NewUint64Value(dr.Name, origin, uint64(v))
+	case contracts.ValueTypeInt8:
+		return dsModels.NewInt8Value(dr.Name, origin, int8(v))
+	case contracts.ValueTypeInt16:
+		return dsModels.NewInt16Value(dr.Name, origin, int16(v))
+	case contracts.ValueTypeInt32:
+		return dsModels.NewInt32Value(dr.Name, origin, int32(v))
+	case contracts.ValueTypeInt64:
+		return dsModels.NewInt64Value(dr.Name, origin, int64(v))
+	default:
+		return dsModels.NewFloat32Value(dr.Name, origin, float32(v))
+	}
+}
@@ -0,0 +1,398 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package computed
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expression is a compiled arithmetic expression over the values of DeviceResources.
+//
+// The supported syntax is numeric literals, DeviceResource names, the binary operators
+// + - * / % ^ (power, right associative), unary + and -, parentheses and the functions
+// listed in the functions table, e.g. "voltage * current" or "dewpoint(temp, humidity)".
+type Expression struct {
+	source    string
+	root      node
+	variables []string
+}
+
+type node interface {
+	eval(vars map[string]float64) (float64, error)
+}
+
+type numberNode float64
+
+type variableNode string
+
+type unaryNode struct {
+	op      byte
+	operand node
+}
+
+type binaryNode struct {
+	op          byte
+	left, right node
+}
+
+type callNode struct {
+	name string
+	fn   function
+	args []node
+}
+
+type function struct {
+	// arity is the number of arguments, -1 means one or more
+	arity int
+	call  func(args []float64) float64
+}
+
+var functions = map[string]function{
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"exp":   {1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"log":   {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"log10": {1, func(a []float64) float64 { return math.Log10(a[0]) }},
+	"round": {1, func(a []float64) float64 { return math.Round(a[0]) }},
+	"floor": {1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"ceil":  {1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"pow":   {2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"min": {-1, func(a []float64) float64 {
+		v := a[0]
+		for _, x := range a[1:] {
+			v = math.Min(v, x)
+		}
+		return v
+	}},
+	"max": {-1, func(a []float64) float64 {
+		v := a[0]
+		for _, x := range a[1:] {
+			v = math.Max(v, x)
+		}
+		return v
+	}},
+	// dewpoint(temperature °C, relative humidity %) with the Magnus formula
+	"dewpoint": {2, func(a []float64) float64 {
+		const b, c = 17.62, 243.12
+		gamma := math.Log(a[1]/100) + b*a[0]/(c+a[0])
+		return c * gamma / (b - gamma)
+	}},
+}
+
+// Parse compiles the given expression.
+func Parse(source string) (*Expression, error) {
+	p := &parser{src: source}
+	p.next()
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression '%s': %v", source, err)
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, fmt.Errorf("invalid expression '%s': unexpected '%s' at %d", source, p.tok.text, p.tok.pos)
+	}
+
+	variables := make([]string, 0, len(p.variables))
+	for name := range p.variables {
+		variables = append(variables, name)
+	}
+	sort.Strings(variables)
+
+	return &Expression{source: source, root: root, variables: variables}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Variables returns the sorted names of the DeviceResources referred by the expression.
+func (e *Expression) Variables() []string {
+	return e.variables
+}
+
+// Evaluate evaluates the expression with the given values of its variables.
+func (e *Expression) Evaluate(vars map[string]float64) (float64, error) {
+	return e.root.eval(vars)
+}
+
+func (n numberNode) eval(map[string]float64) (float64, error) {
+	return float64(n), nil
+}
+
+func (n variableNode) eval(vars map[string]float64) (float64, error) {
+	v, ok := vars[string(n)]
+	if !ok {
+		return 0, fmt.Errorf("no value of '%s'", string(n))
+	}
+	return v, nil
+}
+
+func (n unaryNode) eval(vars map[string]float64) (float64, error) {
+	v, err := n.operand.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	if n.op == '-' {
+		return -v, nil
+	}
+	return v, nil
+}
+
+func (n binaryNode) eval(vars map[string]float64) (float64, error) {
+	l, err := n.left.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	r, err := n.right.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case '+':
+		return l + r, nil
+	case '-':
+		return l - r, nil
+	case '*':
+		return l * r, nil
+	case '/':
+		return l / r, nil
+	case '%':
+		return math.Mod(l, r), nil
+	case '^':
+		return math.Pow(l, r), nil
+	}
+	return 0, fmt.Errorf("unknown operator '%c'", n.op)
+}
+
+func (n callNode) eval(vars map[string]float64) (float64, error) {
+	args := make([]float64, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(vars)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = v
+	}
+	return n.fn.call(args), nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenOperator
+	tokenInvalid
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+type parser struct {
+	src       string
+	pos       int
+	tok       token
+	variables map[string]bool
+}
+
+func (p *parser) next() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokenEOF, text: "end of expression", pos: start}
+		return
+	}
+
+	ch := p.src[p.pos]
+	switch {
+	case isDigit(ch) || ch == '.':
+		for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		// exponent, e.g. 1.5e-3
+		if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+			end := p.pos + 1
+			if end < len(p.src) && (p.src[end] == '+' || p.src[end] == '-') {
+				end++
+			}
+			if end < len(p.src) && isDigit(p.src[end]) {
+				for end < len(p.src) && isDigit(p.src[end]) {
+					end++
+				}
+				p.pos = end
+			}
+		}
+		p.tok = token{kind: tokenNumber, text: p.src[start:p.pos], pos: start}
+	case isIdentStart(ch):
+		for p.pos < len(p.src) && (isIdentStart(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokenIdent, text: p.src[start:p.pos], pos: start}
+	case strings.IndexByte("+-*/%^(),", ch) >= 0:
+		p.pos++
+		p.tok = token{kind: tokenOperator, text: string(ch), pos: start}
+	default:
+		p.pos++
+		p.tok = token{kind: tokenInvalid, text: string(ch), pos: start}
+	}
+}
+
+func (p *parser) isOperator(ops string) bool {
+	return p.tok.kind == tokenOperator && strings.Contains(ops, p.tok.text)
+}
+
+// parseExpr parses additive expressions
+func (p *parser) parseExpr() (node, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("+-") {
+		op := p.tok.text[0]
+		p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseTerm parses multiplicative expressions
+func (p *parser) parseTerm() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("*/%") {
+		op := p.tok.text[0]
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOperator("+-") {
+		op := p.tok.text[0]
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{op: op, operand: operand}, nil
+	}
+	return p.parsePower()
+}
+
+func (p *parser) parsePower() (node, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if p.isOperator("^") {
+		p.next()
+		exponent, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return binaryNode{op: '^', left: base, right: exponent}, nil
+	}
+	return base, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.tok
+	switch {
+	case tok.kind == tokenNumber:
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s' at %d", tok.text, tok.pos)
+		}
+		p.next()
+		return numberNode(v), nil
+	case tok.kind == tokenIdent:
+		p.next()
+		if !p.isOperator("(") {
+			if p.variables == nil {
+				p.variables = make(map[string]bool)
+			}
+			p.variables[tok.text] = true
+			return variableNode(tok.text), nil
+		}
+		return p.parseCall(tok)
+	case p.isOperator("("):
+		p.next()
+		n, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOperator(")") {
+			return nil, fmt.Errorf("missing ')' at %d", p.tok.pos)
+		}
+		p.next()
+		return n, nil
+	}
+	return nil, fmt.Errorf("unexpected '%s' at %d", tok.text, tok.pos)
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function '%s' at %d", name.text, name.pos)
+	}
+
+	// skip '('
+	p.next()
+	var args []node
+	if !p.isOperator(")") {
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if !p.isOperator(",") {
+				break
+			}
+			p.next()
+		}
+	}
+	if !p.isOperator(")") {
+		return nil, fmt.Errorf("missing ')' at %d", p.tok.pos)
+	}
+	p.next()
+
+	if (fn.arity >= 0 && len(args) != fn.arity) || len(args) == 0 {
+		return nil, fmt.Errorf("wrong number of arguments for function '%s'", name.text)
+	}
+	return callNode{name: name.text, fn: fn, args: args}, nil
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isIdentStart(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
@@ -0,0 +1,72 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package computed
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpressionEvaluate(t *testing.T) {
+	vars := map[string]float64{"voltage": 230, "current": 2.5, "temp": 20, "humidity": 50}
+	tests := []struct {
+		name     string
+		source   string
+		expected float64
+	}{
+		{"product", "voltage * current", 575},
+		{"precedence", "1 + 2 * 3", 7},
+		{"parentheses", "(1 + 2) * 3", 9},
+		{"unary minus", "-current + 1", -1.5},
+		{"right associative power", "2 ^ 3 ^ 2", 512},
+		{"modulo", "7 % 4", 3},
+		{"exponent literal", "1.5e2 / 10", 15},
+		{"functions", "max(abs(-3), sqrt(16), 2)", 4},
+		{"dewpoint", "round(dewpoint(temp, humidity) * 10)", 93},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Parse(tt.source)
+			require.NoError(t, err)
+			v, err := e.Evaluate(vars)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.expected, v, 1e-9)
+		})
+	}
+}
+
+func TestExpressionVariables(t *testing.T) {
+	e, err := Parse("voltage * current + min(voltage, 1)")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"current", "voltage"}, e.Variables())
+}
+
+func TestExpressionEvaluateMissingVariable(t *testing.T) {
+	e, err := Parse("voltage * current")
+	require.NoError(t, err)
+	_, err = e.Evaluate(map[string]float64{"voltage": 1})
+	assert.Error(t, err)
+}
+
+func TestExpressionEvaluateDivisionByZero(t *testing.T) {
+	e, err := Parse("1 / x")
+	require.NoError(t, err)
+	v, err := e.Evaluate(map[string]float64{"x": 0})
+	require.NoError(t, err)
+	assert.True(t, math.IsInf(v, 1))
+}
+
+func TestParseInvalidExpression(t *testing.T) {
+	tests := []string{"", "1 +", "(1 + 2", "foo(1)", "pow(1)", "1 2", "a $ b", "max()"}
+	for _, source := range tests {
+		_, err := Parse(source)
+		assert.Error(t, err, source)
+	}
+}
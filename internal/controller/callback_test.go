@@ -0,0 +1,275 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	bootstrapContainer "github.com/edgexfoundry/go-mod-bootstrap/v2/bootstrap/container"
+	"github.com/edgexfoundry/go-mod-bootstrap/v2/di"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/clients/interfaces"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	commonDTO "github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/requests"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/autoevent"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/mock"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/transformer"
+	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+func TestUpdateDeviceService(t *testing.T) {
+	dic := di.NewContainer(di.ServiceConstructorMap{
+		container.DeviceServiceName: func(get di.Get) interface{} {
+			return models.DeviceService{Id: "id", Name: "service", AdminState: models.Unlocked}
+		},
+		bootstrapContainer.LoggingClientInterfaceName: func(get di.Get) interface{} {
+			return logger.NewMockClient()
+		},
+	})
+	target := NewHttpController(dic)
+
+	serviceName := "service"
+	otherName := "other"
+	labels := requests.UpdateDeviceServiceRequest{BaseRequest: commonDTO.NewBaseRequest()}
+	labels.Service.Name = &serviceName
+	labels.Service.Labels = []string{"floor-1"}
+	other := requests.UpdateDeviceServiceRequest{BaseRequest: commonDTO.NewBaseRequest()}
+	other.Service.Name = &otherName
+
+	tests := []struct {
+		Name               string
+		Request            requests.UpdateDeviceServiceRequest
+		ExpectedStatusCode int
+	}{
+		{"Valid - labels updated", labels, http.StatusOK},
+		{"Invalid - other device service", other, http.StatusBadRequest},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.Name, func(t *testing.T) {
+			jsonData, err := json.Marshal(testCase.Request)
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodPut, contracts.ApiServiceCallbackRoute, strings.NewReader(string(jsonData)))
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			handler := http.HandlerFunc(target.UpdateDeviceService)
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, testCase.ExpectedStatusCode, recorder.Result().StatusCode)
+		})
+	}
+
+	ds := container.DeviceServiceFrom(dic.Get)
+	assert.Equal(t, []string{"floor-1"}, ds.Labels)
+	assert.Equal(t, models.AdminState(models.Unlocked), ds.AdminState)
+}
+
+var initCacheOnce sync.Once
+
+// initTestCache initializes the caches once with an empty snapshot, the tests add their own
+// profiles and devices.
+func initTestCache(t *testing.T) {
+	initCacheOnce.Do(func() {
+		dir, err := ioutil.TempDir("", "controller-test")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "snapshot.json")
+		require.NoError(t, ioutil.WriteFile(path, []byte("{}"), 0600))
+		_, err = cache.InitCacheFromSnapshot(path, logger.NewMockClient())
+		require.NoError(t, err)
+	})
+}
+
+// countingDriver counts the reads and returns a constant value, the writes are discarded
+type countingDriver struct {
+	dsModels.ProtocolDriver
+	reads int32
+}
+
+func (d *countingDriver) HandleReadCommands(_ string, _ map[string]models.ProtocolProperties, reqs []dsModels.CommandRequest) ([]*dsModels.CommandValue, error) {
+	atomic.AddInt32(&d.reads, 1)
+	res := make([]*dsModels.CommandValue, len(reqs))
+	for i, req := range reqs {
+		cv, err := dsModels.NewFloat64Value(req.DeviceResourceName, 0, 21.5)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = cv
+	}
+	return res, nil
+}
+
+func (d *countingDriver) HandleWriteCommands(string, map[string]models.ProtocolProperties, []dsModels.CommandRequest, []*dsModels.CommandValue) error {
+	return nil
+}
+
+// discardEventClient accepts and discards the events
+type discardEventClient struct {
+	interfaces.EventClient
+}
+
+func (discardEventClient) Add(context.Context, requests.AddEventRequest) (commonDTO.BaseWithIdResponse, errors.EdgeX) {
+	return commonDTO.BaseWithIdResponse{}, nil
+}
+
+func TestUpdateDeviceService_LockUnlock(t *testing.T) {
+	initTestCache(t)
+	profile := models.DeviceProfile{
+		Name:            "autoevent-profile",
+		DeviceResources: []models.DeviceResource{{Name: "temperature", Properties: models.PropertyValue{Type: contracts.ValueTypeFloat64, ReadWrite: "R"}}},
+		DeviceCommands:  []models.ProfileResource{{Name: "temperature", Get: []models.ResourceOperation{{DeviceResource: "temperature"}}}},
+	}
+	require.NoError(t, cache.Profiles().Add(profile))
+	device := models.Device{
+		Name:        "autoevent-device",
+		ProfileName: profile.Name,
+		AdminState:  models.Unlocked,
+		AutoEvents:  []models.AutoEvent{{Resource: "temperature", Frequency: "10ms"}},
+	}
+	require.NoError(t, cache.Devices().Add(device))
+
+	config := &common.ConfigurationStruct{}
+	config.Device.MaxCmdOps = 128
+	driver := &countingDriver{}
+	setAdminState := func(dic *di.Container, state models.AdminState) {
+		dic.Update(di.ServiceConstructorMap{
+			container.DeviceServiceName: func(get di.Get) interface{} {
+				return models.DeviceService{Id: "id", Name: "service", AdminState: state}
+			},
+		})
+	}
+	dic := di.NewContainer(di.ServiceConstructorMap{
+		container.ConfigurationName: func(get di.Get) interface{} {
+			return config
+		},
+		bootstrapContainer.LoggingClientInterfaceName: func(get di.Get) interface{} {
+			return logger.NewMockClient()
+		},
+		container.ProtocolDriverName: func(get di.Get) interface{} {
+			return driver
+		},
+		container.MetadataDeviceClientName: func(get di.Get) interface{} {
+			return &mock.DeviceClientMock{}
+		},
+		container.CoredataEventClientName: func(get di.Get) interface{} {
+			return discardEventClient{}
+		},
+	})
+	setAdminState(dic, models.Unlocked)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	autoevent.NewManager(ctx, &wg, 1, dic)
+	autoevent.GetManager().RestartForDevice(device.Name, dic)
+	reads := func() int32 { return atomic.LoadInt32(&driver.reads) }
+	require.Eventually(t, func() bool { return reads() > 0 }, time.Second, 5*time.Millisecond)
+
+	target := NewHttpController(dic)
+	update := func(state string) {
+		serviceName := "service"
+		req := requests.UpdateDeviceServiceRequest{BaseRequest: commonDTO.NewBaseRequest()}
+		req.Service.Name = &serviceName
+		req.Service.AdminState = &state
+		jsonData, err := json.Marshal(req)
+		require.NoError(t, err)
+		recorder := doRequest(t, http.MethodPut, contracts.ApiServiceCallbackRoute, target.UpdateDeviceService, strings.NewReader(string(jsonData)))
+		require.Equal(t, http.StatusOK, recorder.Result().StatusCode)
+	}
+
+	update(models.Locked)
+	assert.Equal(t, models.AdminState(models.Locked), container.DeviceServiceFrom(dic.Get).AdminState)
+	// the locked service rejects the reads anyway, unlock it behind the callback's back so
+	// that only the stopped AutoEvents keep the driver from being read
+	time.Sleep(50 * time.Millisecond)
+	setAdminState(dic, models.Unlocked)
+	stopped := reads()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, stopped, reads(), "the AutoEvents are stopped while the service is locked")
+
+	setAdminState(dic, models.Locked)
+	update(models.Unlocked)
+	assert.Equal(t, models.AdminState(models.Unlocked), container.DeviceServiceFrom(dic.Get).AdminState)
+	assert.Eventually(t, func() bool { return reads() > stopped }, time.Second, 5*time.Millisecond, "the AutoEvents are restarted once unlocked")
+}
+
+func TestUpdateProfile_Transforms(t *testing.T) {
+	initTestCache(t)
+	profile := models.DeviceProfile{
+		Name:            "transform-profile",
+		DisplayName:     "transform-profile",
+		DeviceLibraryId: "library",
+		DeviceResources: []models.DeviceResource{{Name: "temperature", Properties: models.PropertyValue{Type: contracts.ValueTypeFloat64, DataType: 1, ReadWrite: "R"}}},
+	}
+	require.NoError(t, cache.Profiles().Add(profile))
+	require.NoError(t, transformer.RegisterReadTransform("test-fahrenheit", func(cv *dsModels.CommandValue, _ models.DeviceResource) (*dsModels.CommandValue, error) {
+		return cv, nil
+	}))
+	dic := di.NewContainer(di.ServiceConstructorMap{
+		bootstrapContainer.LoggingClientInterfaceName: func(get di.Get) interface{} {
+			return logger.NewMockClient()
+		},
+		container.ProtocolDriverName: func(get di.Get) interface{} {
+			return &countingDriver{}
+		},
+	})
+	target := NewHttpController(dic)
+
+	tests := []struct {
+		Name               string
+		Transform          string
+		ExpectedStatusCode int
+	}{
+		{"Valid - registered transform", "test-fahrenheit", http.StatusOK},
+		{"Invalid - unregistered transform", "test-fahrenheit,test-kelvin", http.StatusBadRequest},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.Name, func(t *testing.T) {
+			updated := profile
+			updated.DeviceResources = []models.DeviceResource{profile.DeviceResources[0]}
+			updated.DeviceResources[0].Attributes = map[string]string{common.AttributeTransform: testCase.Transform}
+			jsonData, err := json.Marshal(requests.DeviceProfileRequest{BaseRequest: commonDTO.NewBaseRequest(), Profile: dtos.FromDeviceProfileModelToDTO(updated)})
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodPut, contracts.ApiProfileCallbackRoute, strings.NewReader(string(jsonData)))
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			handler := http.HandlerFunc(target.UpdateProfile)
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, testCase.ExpectedStatusCode, recorder.Result().StatusCode)
+		})
+	}
+
+	cached, ok := cache.Profiles().ForName(profile.Name)
+	require.True(t, ok)
+	assert.Equal(t, "test-fahrenheit", cached.DeviceResources[0].Attributes[common.AttributeTransform], "the invalid update isn't applied")
+}
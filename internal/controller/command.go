@@ -51,6 +51,10 @@ func (c *HttpController) Command(writer http.ResponseWriter, request *http.Reque
 		sendEvent = true
 	}
 	isRead := request.Method == http.MethodGet
+	if err = command.CheckCoreCommand(vars[common.NameVar], vars[common.CommandVar], isRead, c.dic); err != nil {
+		c.sendEdgexError(writer, request, err, contracts.ApiDeviceNameCommandNameRoute)
+		return
+	}
 	event, edgexErr := command.CommandHandler(isRead, sendEvent, correlationID, vars, body, c.dic)
 	if edgexErr != nil {
 		c.sendEdgexError(writer, request, edgexErr, contracts.ApiDeviceNameCommandNameRoute)
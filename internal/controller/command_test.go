@@ -0,0 +1,114 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	bootstrapContainer "github.com/edgexfoundry/go-mod-bootstrap/v2/bootstrap/container"
+	"github.com/edgexfoundry/go-mod-bootstrap/v2/di"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/mock"
+	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
+)
+
+func TestCommand_StrictCoreCommands(t *testing.T) {
+	initTestCache(t)
+	resource := func(name string) models.DeviceResource {
+		return models.DeviceResource{Name: name, Properties: models.PropertyValue{Type: contracts.ValueTypeFloat64, ReadWrite: "RW"}}
+	}
+	command := func(name string) models.ProfileResource {
+		ros := []models.ResourceOperation{{DeviceResource: name}}
+		return models.ProfileResource{Name: name, Get: ros, Set: ros}
+	}
+	profile := models.DeviceProfile{
+		Name:            "strict-profile",
+		DeviceResources: []models.DeviceResource{resource("temperature"), resource("setpoint"), resource("mode"), resource("calibration")},
+		DeviceCommands:  []models.ProfileResource{command("temperature"), command("setpoint"), command("mode"), command("calibration")},
+		CoreCommands: []models.Command{
+			{Name: "temperature", Get: true},
+			{Name: "setpoint", Get: true, Put: true},
+			{Name: "mode", Put: true},
+		},
+	}
+	require.NoError(t, cache.Profiles().Add(profile))
+	device := models.Device{Name: "strict-device", ProfileName: profile.Name, AdminState: models.Unlocked, OperatingState: models.Up}
+	require.NoError(t, cache.Devices().Add(device))
+
+	config := &common.ConfigurationStruct{}
+	config.Device.MaxCmdOps = 128
+	config.Device.StrictCoreCommands = true
+	dic := di.NewContainer(di.ServiceConstructorMap{
+		container.ConfigurationName: func(get di.Get) interface{} {
+			return config
+		},
+		bootstrapContainer.LoggingClientInterfaceName: func(get di.Get) interface{} {
+			return logger.NewMockClient()
+		},
+		container.ProtocolDriverName: func(get di.Get) interface{} {
+			return &countingDriver{}
+		},
+		container.DeviceServiceName: func(get di.Get) interface{} {
+			return models.DeviceService{Name: "service", AdminState: models.Unlocked}
+		},
+		container.MetadataDeviceClientName: func(get di.Get) interface{} {
+			return &mock.DeviceClientMock{}
+		},
+	})
+	target := NewHttpController(dic)
+
+	tests := []struct {
+		Name               string
+		Method             string
+		Command            string
+		ExpectedStatusCode int
+	}{
+		{"Valid - Get allowed", http.MethodGet, "temperature", http.StatusOK},
+		{"Valid - Put allowed", http.MethodPut, "setpoint", http.StatusOK},
+		{"Valid - Put only command", http.MethodPut, "mode", http.StatusOK},
+		{"Invalid - not a coreCommand", http.MethodGet, "calibration", http.StatusMethodNotAllowed},
+		{"Invalid - Get without the Get flag", http.MethodGet, "mode", http.StatusMethodNotAllowed},
+		{"Invalid - Put without the Put flag", http.MethodPut, "temperature", http.StatusMethodNotAllowed},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.Name, func(t *testing.T) {
+			body := ""
+			if testCase.Method == http.MethodPut {
+				body = `{"` + testCase.Command + `": 21.5}`
+			}
+			req, err := http.NewRequest(testCase.Method, contracts.ApiDeviceNameCommandNameRoute, strings.NewReader(body))
+			require.NoError(t, err)
+			req = mux.SetURLVars(req, map[string]string{common.NameVar: device.Name, common.CommandVar: testCase.Command})
+
+			recorder := httptest.NewRecorder()
+			handler := http.HandlerFunc(target.Command)
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, testCase.ExpectedStatusCode, recorder.Result().StatusCode, recorder.Body.String())
+		})
+	}
+
+	// without StrictCoreCommands the commands missing from the coreCommands are reachable
+	config.Device.StrictCoreCommands = false
+	req, err := http.NewRequest(http.MethodGet, contracts.ApiDeviceNameCommandNameRoute, strings.NewReader(""))
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{common.NameVar: device.Name, common.CommandVar: "calibration"})
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(target.Command).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Result().StatusCode, "all the commands are reachable when not strict")
+}
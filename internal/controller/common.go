@@ -16,8 +16,11 @@ import (
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/common"
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/clients"
 	sdkCommon "github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/reconciler"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/telemetry"
 )
 
@@ -47,13 +50,16 @@ func (c *HttpController) Config(writer http.ResponseWriter, request *http.Reques
 func (c *HttpController) Metrics(writer http.ResponseWriter, request *http.Request) {
 	telem := telemetry.NewSystemUsage()
 	metrics := common.Metrics{
-		MemAlloc:       telem.Memory.Alloc,
-		MemFrees:       telem.Memory.Frees,
-		MemLiveObjects: telem.Memory.LiveObjects,
-		MemMallocs:     telem.Memory.Mallocs,
-		MemSys:         telem.Memory.Sys,
-		MemTotalAlloc:  telem.Memory.TotalAlloc,
-		CpuBusyAvg:     uint8(telem.CpuBusyAvg),
+		MemAlloc:           telem.Memory.Alloc,
+		MemFrees:           telem.Memory.Frees,
+		MemLiveObjects:     telem.Memory.LiveObjects,
+		MemMallocs:         telem.Memory.Mallocs,
+		MemSys:             telem.Memory.Sys,
+		MemTotalAlloc:      telem.Memory.TotalAlloc,
+		CpuBusyAvg:         uint8(telem.CpuBusyAvg),
+		CacheDrift:         reconciler.Drift(),
+		Degraded:           clients.Degraded(),
+		UnresolvedProfiles: cache.UnresolvedProfiles(),
 	}
 
 	response := common.NewMetricsResponse(metrics)
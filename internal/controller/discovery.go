@@ -1,19 +1,30 @@
 // -*- Mode: Go; indent-tabs-mode: t -*-
 //
-// Copyright (C) 2020 IOTech Ltd
+// Copyright (C) 2020-2021 IOTech Ltd
 //
 // SPDX-License-Identifier: Apache-2.0
 
 package controller
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 
+	"github.com/gorilla/mux"
+
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/requests"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/responses"
 	edgexErr "github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/autodiscovery"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/provision"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
 )
 
 func (c *HttpController) Discovery(writer http.ResponseWriter, request *http.Request) {
@@ -38,6 +49,79 @@ func (c *HttpController) Discovery(writer http.ResponseWriter, request *http.Req
 		return
 	}
 
-	go autodiscovery.DiscoveryWrapper(discovery, c.lc)
-	c.sendResponse(writer, request, contracts.ApiDiscoveryRoute, nil, http.StatusAccepted)
+	job, err := autodiscovery.StartJob(discovery, c.lc)
+	if err != nil {
+		c.sendEdgexError(writer, request, err, contracts.ApiDiscoveryRoute)
+		return
+	}
+	response := responses.NewDiscoveryJobResponse("", "", http.StatusAccepted, job)
+	c.sendResponse(writer, request, contracts.ApiDiscoveryRoute, response, http.StatusAccepted)
+}
+
+// DiscoveryJobs returns the discovery jobs, the latest first.
+func (c *HttpController) DiscoveryJobs(writer http.ResponseWriter, request *http.Request) {
+	response := responses.NewMultiDiscoveryJobsResponse("", "", http.StatusOK, autodiscovery.Jobs())
+	c.sendResponse(writer, request, common.APIDiscoveryJobsRoute, response, http.StatusOK)
+}
+
+// DiscoveryJob returns the discovery job with the given id.
+func (c *HttpController) DiscoveryJob(writer http.ResponseWriter, request *http.Request) {
+	id := mux.Vars(request)[common.IdVar]
+	job, ok := autodiscovery.Job(id)
+	if !ok {
+		err := edgexErr.NewCommonEdgeX(edgexErr.KindEntityDoesNotExist, fmt.Sprintf("discovery job %s not found", id), nil)
+		c.sendEdgexError(writer, request, err, common.APIDiscoveryJobRoute)
+		return
+	}
+	response := responses.NewDiscoveryJobResponse("", "", http.StatusOK, job)
+	c.sendResponse(writer, request, common.APIDiscoveryJobRoute, response, http.StatusOK)
+}
+
+// CancelDiscoveryJob cancels the running discovery job with the given id.
+func (c *HttpController) CancelDiscoveryJob(writer http.ResponseWriter, request *http.Request) {
+	id := mux.Vars(request)[common.IdVar]
+	if err := autodiscovery.CancelJob(id); err != nil {
+		c.sendEdgexError(writer, request, err, common.APIDiscoveryJobRoute)
+		return
+	}
+	job, _ := autodiscovery.Job(id)
+	response := responses.NewDiscoveryJobResponse("", "", http.StatusOK, job)
+	c.sendResponse(writer, request, common.APIDiscoveryJobRoute, response, http.StatusOK)
+}
+
+// DiscoveryDryRun evaluates the given discovered device against all the provision watchers
+// and explains the decision, without adding the device.
+func (c *HttpController) DiscoveryDryRun(writer http.ResponseWriter, request *http.Request) {
+	defer request.Body.Close()
+
+	var dryRunRequest requests.DiscoveryDryRunRequest
+	if err := json.NewDecoder(request.Body).Decode(&dryRunRequest); err != nil {
+		edgexError := edgexErr.NewCommonEdgeX(edgexErr.KindContractInvalid, "JSON decode failed", err)
+		c.sendEdgexError(writer, request, edgexError, common.APIDiscoveryDryRunRoute)
+		return
+	}
+
+	d := dryRunRequest.Device
+	discovered := dsModels.DiscoveredDevice{
+		Name:        d.Name,
+		Protocols:   dtos.ToProtocolModels(d.Protocols),
+		Description: d.Description,
+		Labels:      d.Labels,
+	}
+	accepted, evaluations := provision.EvaluateAll(discovered, cache.ProvisionWatchers().Compiled())
+
+	decision, watcher := dtos.DiscoveryDecisionRejected, ""
+	if accepted != nil {
+		decision, watcher = dtos.DiscoveryDecisionAccepted, accepted.Name
+	}
+	evaluationDTOs := make([]dtos.ProvisionWatcherEvaluation, 0, len(evaluations))
+	for _, e := range evaluations {
+		evaluationDTOs = append(evaluationDTOs, dtos.ProvisionWatcherEvaluation{
+			ProvisionWatcher: e.ProvisionWatcher,
+			Matched:          e.Matched,
+			Reasons:          e.Reasons,
+		})
+	}
+	response := responses.NewDiscoveryDryRunResponse(dryRunRequest.RequestId, "", http.StatusOK, decision, watcher, evaluationDTOs)
+	c.sendResponse(writer, request, common.APIDiscoveryDryRunRoute, response, http.StatusOK)
 }
@@ -54,8 +54,13 @@ func (c *RestController) InitRestRoutes() {
 	c.addReservedRoute(sdkCommon.APIV2SecretRoute, c.httpController.Secret).Methods(http.MethodPost)
 
 	c.addReservedRoute(contracts.ApiDiscoveryRoute, c.httpController.Discovery).Methods(http.MethodPost)
+	c.addReservedRoute(sdkCommon.APIDiscoveryJobsRoute, c.httpController.DiscoveryJobs).Methods(http.MethodGet)
+	c.addReservedRoute(sdkCommon.APIDiscoveryJobRoute, c.httpController.DiscoveryJob).Methods(http.MethodGet)
+	c.addReservedRoute(sdkCommon.APIDiscoveryJobRoute, c.httpController.CancelDiscoveryJob).Methods(http.MethodDelete)
+	c.addReservedRoute(sdkCommon.APIDiscoveryDryRunRoute, c.httpController.DiscoveryDryRun).Methods(http.MethodPost)
 
 	c.addReservedRoute(contracts.ApiDeviceNameCommandNameRoute, c.httpController.Command).Methods(http.MethodPut, http.MethodGet)
+	c.addReservedRoute(sdkCommon.APITransformRoute, c.httpController.TransformData).Methods(http.MethodPost)
 
 	c.addReservedRoute(contracts.ApiDeviceCallbackRoute, c.httpController.AddDevice).Methods(http.MethodPost)
 	c.addReservedRoute(contracts.ApiDeviceCallbackRoute, c.httpController.UpdateDevice).Methods(http.MethodPut)
@@ -64,6 +69,7 @@ func (c *RestController) InitRestRoutes() {
 	c.addReservedRoute(contracts.ApiProvisionWatcherRoute, c.httpController.AddProvisionWatcher).Methods(http.MethodPost)
 	c.addReservedRoute(contracts.ApiProvisionWatcherRoute, c.httpController.UpdateProvisionWatcher).Methods(http.MethodPut)
 	c.addReservedRoute(contracts.ApiProvisionWatcherByNameRoute, c.httpController.DeleteProvisionWatcher).Methods(http.MethodDelete)
+	c.addReservedRoute(contracts.ApiServiceCallbackRoute, c.httpController.UpdateDeviceService).Methods(http.MethodPut)
 
 	c.router.Use(correlation.ManageHeader)
 	c.router.Use(correlation.OnResponseComplete)
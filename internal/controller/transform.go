@@ -0,0 +1,50 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/requests"
+	edgexErr "github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/command"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
+)
+
+// TransformData handles the request to the transform debug endpoint. It applies the transforms,
+// assertion and mappings of a DeviceResource to the raw value given by the route variable and
+// returns the resulting reading, without accessing the device.
+func (c *HttpController) TransformData(writer http.ResponseWriter, request *http.Request) {
+	defer request.Body.Close()
+
+	configuration := container.ConfigurationFrom(c.dic.Get)
+	if !configuration.Device.TransformDebug {
+		err := edgexErr.NewCommonEdgeX(edgexErr.KindServiceUnavailable, "transform debug disabled", nil)
+		c.sendEdgexError(writer, request, err, common.APITransformRoute)
+		return
+	}
+
+	var transformRequest requests.TransformDataRequest
+	if err := json.NewDecoder(request.Body).Decode(&transformRequest); err != nil {
+		edgexError := edgexErr.NewCommonEdgeX(edgexErr.KindContractInvalid, "JSON decode failed", err)
+		c.sendEdgexError(writer, request, edgexError, common.APITransformRoute)
+		return
+	}
+
+	value := mux.Vars(request)[common.TransformVar]
+	correlationID := request.Header.Get(common.CorrelationHeader)
+	response, err := command.TransformDataHandler(value, transformRequest, correlationID, c.dic)
+	if err != nil {
+		c.sendEdgexError(writer, request, err, common.APITransformRoute)
+		return
+	}
+	c.sendResponse(writer, request, common.APITransformRoute, response, http.StatusOK)
+}
@@ -0,0 +1,97 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	bootstrapContainer "github.com/edgexfoundry/go-mod-bootstrap/v2/bootstrap/container"
+	"github.com/edgexfoundry/go-mod-bootstrap/v2/di"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/requests"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/responses"
+	sdkCommon "github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
+	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
+)
+
+func TestTransformDataRequest(t *testing.T) {
+	config := &sdkCommon.ConfigurationStruct{}
+	config.Device.DataTransform = true
+	config.Device.TransformDebug = true
+
+	dic := di.NewContainer(di.ServiceConstructorMap{
+		container.ConfigurationName: func(get di.Get) interface{} {
+			return config
+		},
+		bootstrapContainer.LoggingClientInterfaceName: func(get di.Get) interface{} {
+			return logger.NewMockClient()
+		},
+	})
+	target := NewHttpController(dic)
+
+	properties := &dtos.PropertyValue{DataType: 1, Type: contracts.ValueTypeInt32, Offset: "5", Assertion: sdkCommon.AssertionExpressionPrefix + "<100"}
+	valid := requests.TransformDataRequest{ResourceName: "temperature", Properties: properties}
+	mapped := valid
+	mapped.Mappings = map[string]string{"100..": "high"}
+	noResource := requests.TransformDataRequest{}
+
+	tests := []struct {
+		Name               string
+		Value              string
+		Request            requests.TransformDataRequest
+		Debug              bool
+		ExpectedStatusCode int
+		ExpectedValue      string
+		ExpectedMapped     bool
+		ExpectedSatisfied  bool
+	}{
+		{"Valid - transformed", "10", valid, true, http.StatusOK, "15", false, true},
+		{"Valid - mapped", "100", mapped, true, http.StatusOK, "high", true, false},
+		{"Invalid - not a number", "abc", valid, true, http.StatusBadRequest, "", false, false},
+		{"Invalid - no resource", "10", noResource, true, http.StatusBadRequest, "", false, false},
+		{"Disabled", "10", valid, false, http.StatusServiceUnavailable, "", false, false},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.Name, func(t *testing.T) {
+			config.Device.TransformDebug = testCase.Debug
+
+			jsonData, err := json.Marshal(testCase.Request)
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodPost, sdkCommon.APITransformRoute, strings.NewReader(string(jsonData)))
+			require.NoError(t, err)
+			req = mux.SetURLVars(req, map[string]string{sdkCommon.TransformVar: testCase.Value})
+
+			recorder := httptest.NewRecorder()
+			handler := http.HandlerFunc(target.TransformData)
+			handler.ServeHTTP(recorder, req)
+
+			actualResponse := responses.TransformDataResponse{}
+			err = json.Unmarshal(recorder.Body.Bytes(), &actualResponse)
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.ExpectedStatusCode, recorder.Result().StatusCode, "HTTP status code not as expected")
+			assert.Equal(t, testCase.ExpectedStatusCode, actualResponse.StatusCode, "BaseResponse status code not as expected")
+			if testCase.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, testCase.ExpectedValue, actualResponse.Reading.Value)
+			assert.Equal(t, testCase.ExpectedMapped, actualResponse.Mapped)
+			require.NotNil(t, actualResponse.Assertion)
+			assert.Equal(t, testCase.ExpectedSatisfied, actualResponse.Assertion.Satisfied)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package eventbus notifies the subscribers of the changes of the devices, profiles,
+// provision watchers and of the device service. The events are delivered synchronously in
+// the publishing goroutine, in order of subscription, so the handlers must return quickly.
+package eventbus
+
+import (
+	"sync"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+// Topic identifies the kind of the published events.
+type Topic string
+
+const (
+	// DeviceTopic events are dsModels.DeviceChange
+	DeviceTopic Topic = "device"
+	// ProfileTopic events are dsModels.ProfileChange
+	ProfileTopic Topic = "profile"
+	// ProvisionWatcherTopic events are dsModels.ProvisionWatcherChange
+	ProvisionWatcherTopic Topic = "provisionWatcher"
+	// DeviceServiceTopic events are dsModels.DeviceServiceChange
+	DeviceServiceTopic Topic = "deviceService"
+)
+
+type subscription struct {
+	id      uint64
+	handler func(event interface{})
+}
+
+var (
+	subscriptions = make(map[Topic][]subscription)
+	nextId        uint64
+	mutex         sync.RWMutex
+)
+
+// Subscribe registers the handler of the events of the topic and returns the function
+// cancelling the subscription.
+func Subscribe(topic Topic, handler func(event interface{})) (unsubscribe func()) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	nextId++
+	id := nextId
+	subscriptions[topic] = append(subscriptions[topic], subscription{id: id, handler: handler})
+	return func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		subs := subscriptions[topic]
+		for i, s := range subs {
+			if s.id == id {
+				subscriptions[topic] = append(subs[:i:i], subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish delivers the event to the handlers subscribed to the topic.
+func Publish(topic Topic, event interface{}) {
+	mutex.RLock()
+	subs := subscriptions[topic]
+	mutex.RUnlock()
+
+	// the handlers are called without the lock so that they can (un)subscribe
+	for _, s := range subs {
+		s.handler(event)
+	}
+}
+
+// PublishDeviceChange publishes the change of the device, before is nil for an added device
+// and after is nil for a removed device.
+func PublishDeviceChange(before *models.Device, after *models.Device) {
+	Publish(DeviceTopic, dsModels.DeviceChange{Type: changeType(before != nil, after != nil), Before: before, After: after})
+}
+
+// PublishProfileChange publishes the change of the profile, before is nil for an added
+// profile and after is nil for a removed profile.
+func PublishProfileChange(before *models.DeviceProfile, after *models.DeviceProfile) {
+	Publish(ProfileTopic, dsModels.ProfileChange{Type: changeType(before != nil, after != nil), Before: before, After: after})
+}
+
+// PublishProvisionWatcherChange publishes the change of the provision watcher, before is nil
+// for an added provision watcher and after is nil for a removed provision watcher.
+func PublishProvisionWatcherChange(before *models.ProvisionWatcher, after *models.ProvisionWatcher) {
+	Publish(ProvisionWatcherTopic, dsModels.ProvisionWatcherChange{Type: changeType(before != nil, after != nil), Before: before, After: after})
+}
+
+// PublishDeviceServiceChange publishes the change of the device service.
+func PublishDeviceServiceChange(before *models.DeviceService, after *models.DeviceService) {
+	Publish(DeviceServiceTopic, dsModels.DeviceServiceChange{Type: changeType(before != nil, after != nil), Before: before, After: after})
+}
+
+func changeType(before bool, after bool) dsModels.ChangeType {
+	switch {
+	case !before:
+		return dsModels.ChangeAdded
+	case !after:
+		return dsModels.ChangeRemoved
+	}
+	return dsModels.ChangeUpdated
+}
@@ -0,0 +1,59 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package eventbus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+func TestPublishDeviceChange(t *testing.T) {
+	var changes []dsModels.DeviceChange
+	unsubscribe := Subscribe(DeviceTopic, func(event interface{}) {
+		changes = append(changes, event.(dsModels.DeviceChange))
+	})
+	var profileEvents int
+	defer Subscribe(ProfileTopic, func(event interface{}) { profileEvents++ })()
+
+	before := models.Device{Name: "device", Labels: []string{"old"}}
+	after := models.Device{Name: "device", Labels: []string{"new"}}
+	PublishDeviceChange(nil, &before)
+	PublishDeviceChange(&before, &after)
+	PublishDeviceChange(&after, nil)
+
+	unsubscribe()
+	PublishDeviceChange(nil, &before)
+
+	if assert.Len(t, changes, 3) {
+		assert.Equal(t, dsModels.ChangeAdded, changes[0].Type)
+		assert.Nil(t, changes[0].Before)
+		assert.Equal(t, dsModels.ChangeUpdated, changes[1].Type)
+		assert.Equal(t, "old", changes[1].Before.Labels[0])
+		assert.Equal(t, "new", changes[1].After.Labels[0])
+		assert.Equal(t, dsModels.ChangeRemoved, changes[2].Type)
+		assert.Nil(t, changes[2].After)
+	}
+	assert.Zero(t, profileEvents)
+}
+
+func TestUnsubscribeDuringPublish(t *testing.T) {
+	var calls int
+	var unsubscribe func()
+	unsubscribe = Subscribe(ProvisionWatcherTopic, func(event interface{}) {
+		calls++
+		unsubscribe()
+	})
+	defer Subscribe(ProvisionWatcherTopic, func(event interface{}) { calls++ })()
+
+	PublishProvisionWatcherChange(nil, &models.ProvisionWatcher{Name: "watcher"})
+	PublishProvisionWatcherChange(nil, &models.ProvisionWatcher{Name: "watcher"})
+	assert.Equal(t, 3, calls)
+}
@@ -0,0 +1,97 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provision
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Prefixes of the ProvisionWatcher identifier and blocking identifier rules
+const (
+	RegexPrefix = "regex:"
+	RangePrefix = "range:"
+	CIDRPrefix  = "cidr:"
+)
+
+// rule is a compiled identifier or blocking identifier rule: a regular expression, a
+// numeric range like "range:10..20", "range:..20" or "range:10..", a network like
+// "cidr:192.168.0.0/16", or an exact value. The rules without prefix are regular
+// expressions for the identifiers and exact values for the blocking identifiers.
+type rule struct {
+	text     string
+	regex    *regexp.Regexp
+	min, max float64
+	isRange  bool
+	network  *net.IPNet
+}
+
+func compileRule(text string, regexByDefault bool) (rule, error) {
+	r := rule{text: text}
+	switch {
+	case strings.HasPrefix(text, RangePrefix):
+		bounds := strings.TrimPrefix(text, RangePrefix)
+		i := strings.Index(bounds, "..")
+		if i < 0 {
+			return r, fmt.Errorf("range %s has no '..' separator", text)
+		}
+		r.isRange = true
+		r.min, r.max = math.Inf(-1), math.Inf(1)
+		var err error
+		if lower := strings.TrimSpace(bounds[:i]); lower != "" {
+			if r.min, err = strconv.ParseFloat(lower, 64); err != nil {
+				return r, fmt.Errorf("range %s has an invalid lower bound", text)
+			}
+		}
+		if upper := strings.TrimSpace(bounds[i+2:]); upper != "" {
+			if r.max, err = strconv.ParseFloat(upper, 64); err != nil {
+				return r, fmt.Errorf("range %s has an invalid upper bound", text)
+			}
+		}
+		if r.min > r.max {
+			return r, fmt.Errorf("range %s has its lower bound greater than its upper bound", text)
+		}
+	case strings.HasPrefix(text, CIDRPrefix):
+		_, network, err := net.ParseCIDR(strings.TrimPrefix(text, CIDRPrefix))
+		if err != nil {
+			return r, fmt.Errorf("invalid network %s: %v", text, err)
+		}
+		r.network = network
+	case strings.HasPrefix(text, RegexPrefix) || regexByDefault:
+		regex, err := regexp.Compile(strings.TrimPrefix(text, RegexPrefix))
+		if err != nil {
+			return r, fmt.Errorf("invalid regular expression %s: %v", text, err)
+		}
+		r.regex = regex
+	}
+	return r, nil
+}
+
+func (r rule) match(value string) bool {
+	switch {
+	case r.isRange:
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return err == nil && v >= r.min && v <= r.max
+	case r.network != nil:
+		ip := net.ParseIP(value)
+		if ip == nil {
+			// an address with a port
+			if host, _, err := net.SplitHostPort(value); err == nil {
+				ip = net.ParseIP(host)
+			}
+		}
+		return ip != nil && r.network.Contains(ip)
+	case r.regex != nil:
+		return r.regex.MatchString(value)
+	default:
+		return value == r.text
+	}
+}
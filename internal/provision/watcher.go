@@ -0,0 +1,244 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package provision implements the matching of the discovered devices against the
+// ProvisionWatchers, whose rules are compiled once when they're cached.
+package provision
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+// Watcher is a ProvisionWatcher with its compiled rules. The identifiers must all be
+// matched by the properties of one protocol of a discovered device, except the reserved
+// labels and description identifiers which match the labels and the description. A
+// device is blocked if any of its properties, labels or its description matches a rule
+// of the blocking identifiers.
+type Watcher struct {
+	models.ProvisionWatcher
+	identifiers         map[string]rule
+	identifierNames     []string // sorted
+	labels              *rule
+	description         *rule
+	blocking            map[string][]rule
+	blockingNames       []string // sorted
+	blockingLabels      []rule
+	blockingDescription []rule
+}
+
+// Evaluation explains whether a discovered device is matched by a ProvisionWatcher.
+type Evaluation struct {
+	ProvisionWatcher string
+	Matched          bool
+	// Reasons the device isn't matched
+	Reasons []string
+}
+
+// Compile compiles the rules of the ProvisionWatcher, all the invalid rules are reported
+// in the returned error.
+func Compile(pw models.ProvisionWatcher) (*Watcher, errors.EdgeX) {
+	w := &Watcher{
+		ProvisionWatcher: pw,
+		identifiers:      make(map[string]rule, len(pw.Identifiers)),
+		blocking:         make(map[string][]rule, len(pw.BlockingIdentifiers)),
+	}
+
+	var issues []string
+	for _, name := range sortedNames(pw.Identifiers) {
+		r, err := compileRule(pw.Identifiers[name], true)
+		if err != nil {
+			issues = append(issues, fmt.Sprintf("identifier %s: %v", name, err))
+			continue
+		}
+		switch name {
+		case common.WatcherIdentifierLabels:
+			w.labels = &r
+		case common.WatcherIdentifierDescription:
+			w.description = &r
+		default:
+			w.identifiers[name] = r
+			w.identifierNames = append(w.identifierNames, name)
+		}
+	}
+	for _, name := range sortedBlockingNames(pw.BlockingIdentifiers) {
+		for _, text := range pw.BlockingIdentifiers[name] {
+			r, err := compileRule(text, false)
+			if err != nil {
+				issues = append(issues, fmt.Sprintf("blocking identifier %s: %v", name, err))
+				continue
+			}
+			switch name {
+			case common.WatcherIdentifierLabels:
+				w.blockingLabels = append(w.blockingLabels, r)
+			case common.WatcherIdentifierDescription:
+				w.blockingDescription = append(w.blockingDescription, r)
+			default:
+				if _, ok := w.blocking[name]; !ok {
+					w.blockingNames = append(w.blockingNames, name)
+				}
+				w.blocking[name] = append(w.blocking[name], r)
+			}
+		}
+	}
+
+	if len(issues) > 0 {
+		errMsg := fmt.Sprintf("provision watcher %s is invalid: %s", pw.Name, strings.Join(issues, "; "))
+		return nil, errors.NewCommonEdgeX(errors.KindContractInvalid, errMsg, nil)
+	}
+	return w, nil
+}
+
+// Evaluate evaluates the discovered device against the watcher.
+func (w *Watcher) Evaluate(d dsModels.DiscoveredDevice) Evaluation {
+	e := Evaluation{ProvisionWatcher: w.Name}
+	if w.AdminState == models.Locked {
+		e.Reasons = append(e.Reasons, "provision watcher is locked")
+		return e
+	}
+
+	protocols := sortedProtocols(d.Protocols)
+	if len(w.identifiers) > 0 {
+		var reasons []string
+		matched := false
+		for _, protocol := range protocols {
+			reason := w.matchProtocol(protocol, d.Protocols[protocol])
+			if reason == "" {
+				matched = true
+				break
+			}
+			reasons = append(reasons, reason)
+		}
+		if !matched {
+			if len(reasons) == 0 {
+				reasons = append(reasons, "device has no protocol")
+			}
+			e.Reasons = append(e.Reasons, reasons...)
+		}
+	}
+	if w.labels != nil && !matchAny(*w.labels, d.Labels) {
+		e.Reasons = append(e.Reasons, fmt.Sprintf("no label matches %s", w.labels.text))
+	}
+	if w.description != nil && !w.description.match(d.Description) {
+		e.Reasons = append(e.Reasons, fmt.Sprintf("description doesn't match %s", w.description.text))
+	}
+
+	for _, name := range w.blockingNames {
+		for _, protocol := range protocols {
+			value, ok := d.Protocols[protocol][name]
+			if !ok {
+				continue
+			}
+			for _, r := range w.blocking[name] {
+				if r.match(value) {
+					e.Reasons = append(e.Reasons, fmt.Sprintf("protocol %s property %s value %s is blocked by %s", protocol, name, value, r.text))
+				}
+			}
+		}
+	}
+	for _, r := range w.blockingLabels {
+		if matchAny(r, d.Labels) {
+			e.Reasons = append(e.Reasons, fmt.Sprintf("labels are blocked by %s", r.text))
+		}
+	}
+	for _, r := range w.blockingDescription {
+		if r.match(d.Description) {
+			e.Reasons = append(e.Reasons, fmt.Sprintf("description is blocked by %s", r.text))
+		}
+	}
+
+	e.Matched = len(e.Reasons) == 0
+	return e
+}
+
+// matchProtocol returns the reason the protocol properties don't match the identifiers,
+// empty if they match.
+func (w *Watcher) matchProtocol(protocol string, properties models.ProtocolProperties) string {
+	for _, name := range w.identifierNames {
+		r := w.identifiers[name]
+		value, ok := properties[name]
+		if !ok {
+			return fmt.Sprintf("protocol %s has no property %s", protocol, name)
+		}
+		if !r.match(value) {
+			return fmt.Sprintf("protocol %s property %s value %s doesn't match %s", protocol, name, value, r.text)
+		}
+	}
+	return ""
+}
+
+// EvaluateAll evaluates the discovered device against the watchers in name order, the
+// first matching watcher is returned, nil if none matches.
+func EvaluateAll(d dsModels.DiscoveredDevice, watchers []*Watcher) (*Watcher, []Evaluation) {
+	sorted := make([]*Watcher, len(watchers))
+	copy(sorted, watchers)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var accepted *Watcher
+	evaluations := make([]Evaluation, 0, len(sorted))
+	for _, w := range sorted {
+		e := w.Evaluate(d)
+		evaluations = append(evaluations, e)
+		if e.Matched && accepted == nil {
+			accepted = w
+		}
+	}
+	return accepted, evaluations
+}
+
+// Explain joins the reasons of the evaluations, prefixed by the name of the watcher.
+func Explain(evaluations []Evaluation) string {
+	if len(evaluations) == 0 {
+		return "no provision watcher"
+	}
+	explanations := make([]string, 0, len(evaluations))
+	for _, e := range evaluations {
+		explanations = append(explanations, fmt.Sprintf("%s: %s", e.ProvisionWatcher, strings.Join(e.Reasons, ", ")))
+	}
+	return strings.Join(explanations, "; ")
+}
+
+func matchAny(r rule, values []string) bool {
+	for _, v := range values {
+		if r.match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedNames(m map[string]string) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedProtocols(protocols map[string]models.ProtocolProperties) []string {
+	names := make([]string, 0, len(protocols))
+	for name := range protocols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedBlockingNames(m map[string][]string) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
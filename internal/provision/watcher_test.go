@@ -0,0 +1,119 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provision
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name        string
+		identifiers map[string]string
+		blocking    map[string][]string
+		expectError bool
+	}{
+		{"valid", map[string]string{"Address": "cidr:10.0.0.0/8", "Port": "range:1..1024", "Model": "^T"}, map[string][]string{"Serial": {"regex:^0+$"}}, false},
+		{"invalid regex", map[string]string{"Model": "("}, nil, true},
+		{"invalid range", map[string]string{"Port": "range:10"}, nil, true},
+		{"reversed range", map[string]string{"Port": "range:20..10"}, nil, true},
+		{"invalid network", map[string]string{"Address": "cidr:10.0.0.0/40"}, nil, true},
+		{"invalid blocking regex", nil, map[string][]string{"Serial": {"regex:["}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(models.ProvisionWatcher{Name: tt.name, Identifiers: tt.identifiers, BlockingIdentifiers: tt.blocking})
+			if tt.expectError {
+				require.Error(t, err)
+				assert.Equal(t, errors.KindContractInvalid, errors.Kind(err))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	w, err := Compile(models.ProvisionWatcher{
+		Name: "watcher",
+		Identifiers: map[string]string{
+			"Address":                           "cidr:192.168.0.0/16",
+			"Port":                              "range:502..",
+			common.WatcherIdentifierLabels:      "^modbus$",
+			common.WatcherIdentifierDescription: "regex:meter",
+		},
+		BlockingIdentifiers: map[string][]string{
+			"Serial":                       {"0000"},
+			common.WatcherIdentifierLabels: {"regex:^test"},
+		},
+	})
+	require.NoError(t, err)
+
+	device := func(address, port, serial string, labels ...string) dsModels.DiscoveredDevice {
+		return dsModels.DiscoveredDevice{
+			Name:        "device",
+			Protocols:   map[string]models.ProtocolProperties{"modbus-tcp": {"Address": address, "Port": port, "Serial": serial}},
+			Description: "power meter",
+			Labels:      labels,
+		}
+	}
+	tests := []struct {
+		name    string
+		device  dsModels.DiscoveredDevice
+		matched bool
+	}{
+		{"matched", device("192.168.1.10", "502", "1234", "modbus"), true},
+		{"address with port", device("192.168.1.10:502", "502", "1234", "modbus"), true},
+		{"address outside network", device("10.0.0.1", "502", "1234", "modbus"), false},
+		{"port out of range", device("192.168.1.10", "80", "1234", "modbus"), false},
+		{"port not a number", device("192.168.1.10", "modbus", "1234", "modbus"), false},
+		{"label not matched", device("192.168.1.10", "502", "1234", "bacnet"), false},
+		{"blocked serial", device("192.168.1.10", "502", "0000", "modbus"), false},
+		{"blocked label", device("192.168.1.10", "502", "1234", "modbus", "testing"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := w.Evaluate(tt.device)
+			assert.Equal(t, tt.matched, e.Matched)
+			assert.Equal(t, tt.matched, len(e.Reasons) == 0)
+		})
+	}
+
+	locked := *w
+	locked.AdminState = models.Locked
+	assert.False(t, locked.Evaluate(device("192.168.1.10", "502", "1234", "modbus")).Matched)
+}
+
+func TestEvaluateAll(t *testing.T) {
+	b, err := Compile(models.ProvisionWatcher{Name: "b", Identifiers: map[string]string{"Address": ".*"}})
+	require.NoError(t, err)
+	a, err := Compile(models.ProvisionWatcher{Name: "a", Identifiers: map[string]string{"Address": "^10\\."}})
+	require.NoError(t, err)
+	c, err := Compile(models.ProvisionWatcher{Name: "c", Identifiers: map[string]string{"Address": ".*"}})
+	require.NoError(t, err)
+
+	d := dsModels.DiscoveredDevice{Name: "device", Protocols: map[string]models.ProtocolProperties{"other": {"Address": "192.168.1.10"}}}
+	accepted, evaluations := EvaluateAll(d, []*Watcher{c, b, a})
+	require.NotNil(t, accepted)
+	assert.Equal(t, "b", accepted.Name)
+	require.Len(t, evaluations, 3)
+	assert.Equal(t, "a", evaluations[0].ProvisionWatcher)
+	assert.False(t, evaluations[0].Matched)
+	assert.Contains(t, Explain(evaluations[:1]), "a: protocol other property Address")
+
+	accepted, evaluations = EvaluateAll(d, []*Watcher{a})
+	assert.Nil(t, accepted)
+	assert.Len(t, evaluations, 1)
+}
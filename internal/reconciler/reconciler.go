@@ -0,0 +1,286 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package reconciler periodically reconciles the devices, profiles and provision watchers
+// cached by the device service with core metadata, so that a callback missed, e.g. while
+// the service was restarting, doesn't leave the cache drifting indefinitely.
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bootstrapContainer "github.com/edgexfoundry/go-mod-bootstrap/v2/bootstrap/container"
+	"github.com/edgexfoundry/go-mod-bootstrap/v2/bootstrap/startup"
+	"github.com/edgexfoundry/go-mod-bootstrap/v2/di"
+	"github.com/google/uuid"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	commonDTO "github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/requests"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/callback"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/eventbus"
+	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
+)
+
+var (
+	// the drift counts since the service started
+	deviceDrift    uint64
+	profileDrift   uint64
+	watcherDrift   uint64
+	lastReconciled int64
+
+	// a single reconciliation runs at a time
+	mutex sync.Mutex
+)
+
+func BootstrapHandler(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	_ startup.Timer,
+	dic *di.Container) bool {
+	lc := bootstrapContainer.LoggingClientFrom(dic.Get)
+	configuration := container.ConfigurationFrom(dic.Get)
+
+	if !configuration.Device.CacheReconcile.Enabled {
+		lc.Info("Cache reconciliation stopped: disabled by configuration")
+		return true
+	}
+	duration, err := time.ParseDuration(configuration.Device.CacheReconcile.Interval)
+	if err != nil || duration <= 0 {
+		lc.Info("Cache reconciliation stopped: interval error in configuration")
+		return true
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		lc.Info(fmt.Sprintf("Starting cache reconciliation with duration %v", duration))
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(duration):
+				Reconcile(dic)
+			}
+		}
+	}()
+
+	return true
+}
+
+// Drift returns the number of devices, profiles and provision watchers which differed from
+// core metadata and were corrected, since the service started.
+func Drift() commonDTO.CacheDrift {
+	return commonDTO.CacheDrift{
+		Devices:           atomic.LoadUint64(&deviceDrift),
+		Profiles:          atomic.LoadUint64(&profileDrift),
+		ProvisionWatchers: atomic.LoadUint64(&watcherDrift),
+		LastReconciled:    atomic.LoadInt64(&lastReconciled),
+	}
+}
+
+// Reconcile diffs the cache against core metadata once and applies the adds, updates and
+// removes through the callback handlers, as if the missed callbacks were received.
+func Reconcile(dic *di.Container) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	lc := bootstrapContainer.LoggingClientFrom(dic.Get)
+	ctx := context.WithValue(context.Background(), common.CorrelationHeader, uuid.New().String())
+	serviceName := container.DeviceServiceFrom(dic.Get).Name
+
+	devices := reconcileDevices(ctx, serviceName, dic, lc)
+	profiles := reconcileProfiles(ctx, dic, lc)
+	watchers := reconcileProvisionWatchers(ctx, serviceName, dic, lc)
+
+	atomic.AddUint64(&deviceDrift, devices)
+	atomic.AddUint64(&profileDrift, profiles)
+	atomic.AddUint64(&watcherDrift, watchers)
+	atomic.StoreInt64(&lastReconciled, time.Now().UnixNano()/1e6)
+	if devices+profiles+watchers > 0 {
+		lc.Warn(fmt.Sprintf("cache reconciled with metadata, drifted devices: %d, profiles: %d, provision watchers: %d", devices, profiles, watchers))
+	} else {
+		lc.Debug("cache consistent with metadata")
+	}
+}
+
+func reconcileDevices(ctx context.Context, serviceName string, dic *di.Container, lc logger.LoggingClient) uint64 {
+	dc := container.MetadataDeviceClientFrom(dic.Get)
+	res, err := dc.DevicesByServiceName(ctx, serviceName, 0, -1)
+	if err != nil {
+		// an unreachable metadata must not empty the cache
+		lc.Error(fmt.Sprintf("failed to reconcile devices: %v", err))
+		return 0
+	}
+
+	var drift uint64
+	inMetadata := make(map[string]bool, len(res.Devices))
+	for _, dto := range res.Devices {
+		inMetadata[dto.Name] = true
+		device := dtos.ToDeviceModel(dto)
+		cached, ok := cache.Devices().ForName(device.Name)
+		if !ok {
+			lc.Info(fmt.Sprintf("device %s missing from the cache, adding it", device.Name))
+			drift++
+			req := requests.AddDeviceRequest{BaseRequest: commonDTO.NewBaseRequest(), Device: dto}
+			if err := callback.AddDevice(req, dic); err != nil {
+				lc.Error(fmt.Sprintf("failed to add device %s: %v", device.Name, err))
+			}
+		} else if !sameDevice(cached, device) {
+			lc.Info(fmt.Sprintf("device %s outdated in the cache, updating it", device.Name))
+			drift++
+			req := requests.UpdateDeviceRequest{BaseRequest: commonDTO.NewBaseRequest(), Device: dtos.FromDeviceModelToUpdateDTO(device)}
+			if err := callback.UpdateDevice(req, dic); err != nil {
+				lc.Error(fmt.Sprintf("failed to update device %s: %v", device.Name, err))
+			}
+		}
+	}
+
+	for _, device := range cache.Devices().All() {
+		if inMetadata[device.Name] {
+			continue
+		}
+		lc.Info(fmt.Sprintf("device %s removed from metadata, removing it", device.Name))
+		drift++
+		if err := callback.DeleteDevice(device.Name, dic); err != nil {
+			lc.Error(fmt.Sprintf("failed to remove device %s: %v", device.Name, err))
+		}
+	}
+	return drift
+}
+
+func reconcileProfiles(ctx context.Context, dic *di.Container, lc logger.LoggingClient) uint64 {
+	dpc := container.MetadataDeviceProfileClientFrom(dic.Get)
+
+	var drift uint64
+	for _, cached := range cache.Profiles().All() {
+		res, err := dpc.DeviceProfileByName(ctx, cached.Name)
+		if err != nil {
+			if errors.Kind(err) == errors.KindEntityDoesNotExist && cache.CheckProfileNotUsed(cached.Name) {
+				lc.Info(fmt.Sprintf("unused profile %s removed from metadata, removing it", cached.Name))
+				drift++
+				removed, _ := cache.Profiles().Resolved(cached.Name)
+				if err := cache.Profiles().RemoveByName(cached.Name); err != nil {
+					lc.Error(fmt.Sprintf("failed to remove profile %s: %v", cached.Name, err))
+				} else {
+					eventbus.PublishProfileChange(&removed, nil)
+				}
+			} else {
+				lc.Error(fmt.Sprintf("failed to reconcile profile %s: %v", cached.Name, err))
+			}
+			continue
+		}
+
+		if !sameProfile(cached, dtos.ToDeviceProfileModel(res.Profile)) {
+			lc.Info(fmt.Sprintf("profile %s outdated in the cache, updating it", cached.Name))
+			drift++
+			req := requests.DeviceProfileRequest{BaseRequest: commonDTO.NewBaseRequest(), Profile: res.Profile}
+			if err := callback.UpdateProfile(req, dic); err != nil {
+				lc.Error(fmt.Sprintf("failed to update profile %s: %v", cached.Name, err))
+			}
+		}
+	}
+	return drift
+}
+
+func reconcileProvisionWatchers(ctx context.Context, serviceName string, dic *di.Container, lc logger.LoggingClient) uint64 {
+	pwc := container.MetadataProvisionWatcherClientFrom(dic.Get)
+	res, err := pwc.ProvisionWatchersByServiceName(ctx, serviceName, 0, -1)
+	if err != nil {
+		lc.Error(fmt.Sprintf("failed to reconcile provision watchers: %v", err))
+		return 0
+	}
+
+	var drift uint64
+	inMetadata := make(map[string]bool, len(res.ProvisionWatchers))
+	for _, dto := range res.ProvisionWatchers {
+		inMetadata[dto.Name] = true
+		watcher := dtos.ToProvisionWatcherModel(dto)
+		cached, ok := cache.ProvisionWatchers().ForName(watcher.Name)
+		if !ok {
+			lc.Info(fmt.Sprintf("provision watcher %s missing from the cache, adding it", watcher.Name))
+			drift++
+			req := requests.AddProvisionWatcherRequest{BaseRequest: commonDTO.NewBaseRequest(), ProvisionWatcher: dto}
+			if err := callback.AddProvisionWatcher(req, lc); err != nil {
+				lc.Error(fmt.Sprintf("failed to add provision watcher %s: %v", watcher.Name, err))
+			}
+		} else if !sameProvisionWatcher(cached, watcher) {
+			lc.Info(fmt.Sprintf("provision watcher %s outdated in the cache, updating it", watcher.Name))
+			drift++
+			req := requests.UpdateProvisionWatcherRequest{BaseRequest: commonDTO.NewBaseRequest(), ProvisionWatcher: dtos.FromProvisionWatcherModelToUpdateDTO(watcher)}
+			if err := callback.UpdateProvisionWatcher(req, lc); err != nil {
+				lc.Error(fmt.Sprintf("failed to update provision watcher %s: %v", watcher.Name, err))
+			}
+		}
+	}
+
+	for _, watcher := range cache.ProvisionWatchers().All() {
+		if inMetadata[watcher.Name] {
+			continue
+		}
+		lc.Info(fmt.Sprintf("provision watcher %s removed from metadata, removing it", watcher.Name))
+		drift++
+		if err := callback.DeleteProvisionWatcher(watcher.Name, lc); err != nil {
+			lc.Error(fmt.Sprintf("failed to remove provision watcher %s: %v", watcher.Name, err))
+		}
+	}
+	return drift
+}
+
+// sameDevice compares the devices ignoring the timestamps maintained by metadata
+func sameDevice(a models.Device, b models.Device) bool {
+	normalize := func(d models.Device) models.Device {
+		d.Timestamps = models.Timestamps{}
+		d.LastConnected, d.LastReported = 0, 0
+		if len(d.Labels) == 0 {
+			d.Labels = nil
+		}
+		if len(d.Protocols) == 0 {
+			d.Protocols = nil
+		}
+		if len(d.AutoEvents) == 0 {
+			d.AutoEvents = nil
+		}
+		return d
+	}
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func sameProfile(a models.DeviceProfile, b models.DeviceProfile) bool {
+	a.Timestamps, b.Timestamps = models.Timestamps{}, models.Timestamps{}
+	return reflect.DeepEqual(a, b)
+}
+
+func sameProvisionWatcher(a models.ProvisionWatcher, b models.ProvisionWatcher) bool {
+	normalize := func(pw models.ProvisionWatcher) models.ProvisionWatcher {
+		pw.Timestamps = models.Timestamps{}
+		if len(pw.Labels) == 0 {
+			pw.Labels = nil
+		}
+		if len(pw.Identifiers) == 0 {
+			pw.Identifiers = nil
+		}
+		if len(pw.BlockingIdentifiers) == 0 {
+			pw.BlockingIdentifiers = nil
+		}
+		if len(pw.AutoEvents) == 0 {
+			pw.AutoEvents = nil
+		}
+		return pw
+	}
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
@@ -0,0 +1,54 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package reconciler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+)
+
+func TestSameDevice(t *testing.T) {
+	device := models.Device{
+		Name:        "thermostat",
+		ProfileName: "thermostat-profile",
+		AdminState:  models.Unlocked,
+		Protocols:   map[string]models.ProtocolProperties{"modbus-tcp": {"Address": "10.0.0.1"}},
+	}
+
+	touched := device
+	touched.Timestamps = models.Timestamps{Created: 1, Modified: 2}
+	touched.LastConnected = 3
+	touched.Labels = []string{}
+	assert.True(t, sameDevice(device, touched), "timestamps and empty labels are not drift")
+
+	locked := device
+	locked.AdminState = models.Locked
+	assert.False(t, sameDevice(device, locked))
+
+	moved := device
+	moved.Protocols = map[string]models.ProtocolProperties{"modbus-tcp": {"Address": "10.0.0.2"}}
+	assert.False(t, sameDevice(device, moved))
+}
+
+func TestSameProvisionWatcher(t *testing.T) {
+	watcher := models.ProvisionWatcher{
+		Name:        "watcher",
+		Identifiers: map[string]string{"Address": "10.0.0.*"},
+	}
+
+	touched := watcher
+	touched.Timestamps = models.Timestamps{Modified: 1}
+	touched.BlockingIdentifiers = map[string][]string{}
+	assert.True(t, sameProvisionWatcher(watcher, touched))
+
+	changed := watcher
+	changed.ProfileName = "other-profile"
+	assert.False(t, sameProvisionWatcher(watcher, changed))
+}
@@ -0,0 +1,333 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package transformer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/clients/interfaces"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	commonDTO "github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/requests"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+// Assertion is a parsed PropertyValue.Assertion. A plain value is the original assertion, the
+// reading must be equal to it. The rules prefixed by common.AssertionExpressionPrefix, e.g.
+// "ds-assert:<80", are:
+//   - "==x" and "!=x", numeric comparison if both sides are numbers, string comparison otherwise
+//   - ">x", ">=x", "<x" and "<=x", numeric comparison
+//   - "a..b", the reading must be within the inclusive numeric range
+//   - "/regex/", the reading must match the regular expression
+type Assertion struct {
+	op       string
+	operand  string
+	number   float64
+	min, max float64
+	regex    *regexp.Regexp
+	// exact is set for the original assertion, compared as a string
+	exact bool
+}
+
+const (
+	assertionEqual    = "=="
+	assertionNotEqual = "!="
+	assertionGreater  = ">"
+	assertionGreaterE = ">="
+	assertionLess     = "<"
+	assertionLessE    = "<="
+	assertionRange    = ".."
+	assertionRegex    = "/"
+)
+
+var (
+	// assertions caches the parsed assertions by rule
+	assertions sync.Map
+
+	// violations keeps the state of the DeviceResources whose assertion was checked by
+	// device name, true for a violation
+	violations = make(map[string]map[string]bool)
+	mutex      sync.Mutex
+)
+
+// ParseAssertion parses the assertion rule.
+func ParseAssertion(rule string) (*Assertion, error) {
+	if a, ok := assertions.Load(rule); ok {
+		return a.(*Assertion), nil
+	}
+
+	a, err := parseAssertion(rule)
+	if err != nil {
+		return nil, err
+	}
+	assertions.Store(rule, a)
+	return a, nil
+}
+
+func parseAssertion(rule string) (*Assertion, error) {
+	if !strings.HasPrefix(rule, common.AssertionExpressionPrefix) {
+		// the original exact-string assertion
+		return &Assertion{op: assertionEqual, operand: rule, exact: true}, nil
+	}
+	expr := strings.TrimSpace(strings.TrimPrefix(rule, common.AssertionExpressionPrefix))
+
+	if len(expr) >= 2 && strings.HasPrefix(expr, assertionRegex) && strings.HasSuffix(expr, assertionRegex) {
+		regex, err := regexp.Compile(expr[1 : len(expr)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid assertion %s: %v", rule, err)
+		}
+		return &Assertion{op: assertionRegex, regex: regex}, nil
+	}
+
+	for _, op := range []string{assertionGreaterE, assertionLessE, assertionEqual, assertionNotEqual, assertionGreater, assertionLess} {
+		if !strings.HasPrefix(expr, op) {
+			continue
+		}
+		a := &Assertion{op: op, operand: strings.TrimSpace(expr[len(op):])}
+		number, err := strconv.ParseFloat(a.operand, 64)
+		if err != nil && op != assertionEqual && op != assertionNotEqual {
+			return nil, fmt.Errorf("invalid assertion %s: %s is not a number", rule, a.operand)
+		}
+		a.number = number
+		return a, nil
+	}
+
+	if i := strings.Index(expr, assertionRange); i > 0 {
+		min, minErr := strconv.ParseFloat(strings.TrimSpace(expr[:i]), 64)
+		max, maxErr := strconv.ParseFloat(strings.TrimSpace(expr[i+len(assertionRange):]), 64)
+		if minErr == nil && maxErr == nil {
+			if min > max {
+				return nil, fmt.Errorf("invalid assertion %s: lower bound greater than upper bound", rule)
+			}
+			return &Assertion{op: assertionRange, min: min, max: max}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid assertion %s: unknown expression %s", rule, expr)
+}
+
+// Check returns whether the value satisfies the assertion. The hysteresis is the deadband
+// a violated numeric assertion requires to be satisfied again, to avoid flapping around
+// the threshold.
+func (a *Assertion) Check(value string, violated bool, hysteresis float64) bool {
+	if a.op == assertionRegex {
+		return a.regex.MatchString(value)
+	}
+
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	numeric := err == nil
+	if !violated {
+		hysteresis = 0
+	}
+
+	switch a.op {
+	case assertionEqual, assertionNotEqual:
+		equal := value == a.operand
+		if n, err := strconv.ParseFloat(a.operand, 64); err == nil && numeric && !a.exact {
+			equal = v == n
+		}
+		return equal == (a.op == assertionEqual)
+	case assertionGreater:
+		return numeric && v > a.number+hysteresis
+	case assertionGreaterE:
+		return numeric && v >= a.number+hysteresis
+	case assertionLess:
+		return numeric && v < a.number-hysteresis
+	case assertionLessE:
+		return numeric && v <= a.number-hysteresis
+	case assertionRange:
+		return numeric && v >= a.min+hysteresis && v <= a.max-hysteresis
+	}
+	return false
+}
+
+// CheckAssertion checks the CommandValue against the assertion of its DeviceResource and
+// tracks the violations of the device:
+//   - the device's OperatingState is set to DOWN on the first violation with the
+//     common.DeviceLabelAssertionDown label, unless the ds-assertionOperatingState attribute is
+//     "false". Only a device labeled so is set back to UP, once all its asserted DeviceResources
+//     were checked and satisfy their assertions, and none other reason keeps it DOWN. The
+//     label lets the device recover after a restart, its DeviceResources being taken as
+//     violating their assertions until checked
+//   - with the ds-assertionAlarm attribute, a Bool alarm reading of the given name is returned,
+//     true for a violation and false for the recovery
+//
+// An error is returned on violation if no alarm is configured, callers replace the reading
+// with the failure message as before.
+func CheckAssertion(
+	cv *dsModels.CommandValue,
+	dr models.DeviceResource,
+	device *models.Device,
+	lc logger.LoggingClient,
+	dc interfaces.DeviceClient) (*dsModels.CommandValue, error) {
+	rule := dr.Properties.Assertion
+	if rule == "" {
+		return nil, nil
+	}
+	a, err := ParseAssertion(rule)
+	if err != nil {
+		lc.Error(err.Error())
+		return nil, err
+	}
+
+	hysteresis := 0.0
+	if v, ok := dr.Attributes[common.AttributeAssertionHysteresis]; ok {
+		if hysteresis, err = strconv.ParseFloat(v, 64); err != nil || hysteresis < 0 {
+			lc.Warn(fmt.Sprintf("invalid %s %s of deviceResource %s, no hysteresis applied", common.AttributeAssertionHysteresis, v, dr.Name))
+			hysteresis = 0
+		}
+	}
+
+	updateState, _ := strconv.ParseBool(dr.Attributes[common.AttributeAssertionOperatingState])
+	if _, ok := dr.Attributes[common.AttributeAssertionOperatingState]; !ok {
+		updateState = true
+	}
+
+	demoted := common.HasLabel(device.Labels, common.DeviceLabelAssertionDown)
+	wasViolated, checked := isViolated(device.Name, dr.Name)
+	if !checked && updateState && demoted {
+		wasViolated = true
+	}
+	satisfied := a.Check(cv.ValueToString(), wasViolated, math.Abs(hysteresis))
+	anyViolated := setViolated(device.Name, dr.Name, !satisfied)
+	if updateState {
+		if !satisfied && device.OperatingState != models.Down {
+			lc.Info(fmt.Sprintf("assertion of deviceResource %s violated, marking device %s DOWN", dr.Name, device.Name))
+			labels := append(common.WithoutLabel(device.Labels, common.DeviceLabelAssertionDown), common.DeviceLabelAssertionDown)
+			updateOperatingState(device, models.Down, labels, dc)
+		} else if satisfied && !anyViolated && demoted && allChecked(*device) {
+			labels := common.WithoutLabel(device.Labels, common.DeviceLabelAssertionDown)
+			state := device.OperatingState
+			if !common.HasLabel(device.Labels, common.DeviceLabelDiscoveryDown) {
+				lc.Info(fmt.Sprintf("assertions of device %s recovered, marking it UP", device.Name))
+				state = models.Up
+			}
+			updateOperatingState(device, state, labels, dc)
+		}
+	}
+
+	if satisfied == !wasViolated {
+		// no transition
+		if satisfied {
+			return nil, nil
+		}
+		return assertionFailed(cv, dr, rule, lc)
+	}
+
+	if satisfied {
+		return newAlarm(cv, dr, false), nil
+	}
+	return assertionFailed(cv, dr, rule, lc)
+}
+
+func assertionFailed(cv *dsModels.CommandValue, dr models.DeviceResource, rule string, lc logger.LoggingClient) (*dsModels.CommandValue, error) {
+	msg := fmt.Sprintf("assertion (%s) failed with value: %s", rule, cv.ValueToString())
+	lc.Error(msg)
+	if alarm := newAlarm(cv, dr, true); alarm != nil {
+		return alarm, nil
+	}
+	return nil, fmt.Errorf(msg)
+}
+
+// newAlarm creates the alarm reading configured by the ds-assertionAlarm attribute
+func newAlarm(cv *dsModels.CommandValue, dr models.DeviceResource, violated bool) *dsModels.CommandValue {
+	name, ok := dr.Attributes[common.AttributeAssertionAlarm]
+	if !ok || name == "" {
+		return nil
+	}
+	alarm, _ := dsModels.NewBoolValue(name, cv.Origin, violated)
+	return alarm
+}
+
+// ResetAssertions drops the assertion violations tracked for the device.
+func ResetAssertions(deviceName string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	delete(violations, deviceName)
+}
+
+// isViolated returns whether the DeviceResource violates its assertion, the second
+// returned bool indicates whether its assertion has been checked yet
+func isViolated(deviceName string, resourceName string) (bool, bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	violated, ok := violations[deviceName][resourceName]
+	return violated, ok
+}
+
+// setViolated records the violation state of the DeviceResource and returns whether
+// any DeviceResource of the device violates its assertion
+func setViolated(deviceName string, resourceName string, violated bool) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	resources, ok := violations[deviceName]
+	if !ok {
+		resources = make(map[string]bool)
+		violations[deviceName] = resources
+	}
+	resources[resourceName] = violated
+	for _, v := range resources {
+		if v {
+			return true
+		}
+	}
+	return false
+}
+
+// allChecked returns whether the assertions of all the DeviceResources of the device that
+// update its OperatingState were checked
+func allChecked(device models.Device) bool {
+	profile, ok := cache.Profiles().Resolved(device.ProfileName)
+	if !ok {
+		return true
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, dr := range profile.DeviceResources {
+		if dr.Properties.Assertion == "" {
+			continue
+		}
+		if v, ok := dr.Attributes[common.AttributeAssertionOperatingState]; ok {
+			if updateState, _ := strconv.ParseBool(v); !updateState {
+				continue
+			}
+		}
+		if _, ok := violations[device.Name][dr.Name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func updateOperatingState(device *models.Device, state models.OperatingState, labels []string, dc interfaces.DeviceClient) {
+	device.OperatingState = state
+	device.Labels = labels
+	cache.Devices().Update(*device)
+	ctx := context.WithValue(context.Background(), common.CorrelationHeader, uuid.New().String())
+	os := string(device.OperatingState)
+	pd := dtos.UpdateDevice{
+		Id:             &device.Id,
+		Name:           &device.Name,
+		OperatingState: &os,
+		Labels:         labels,
+	}
+	go dc.Update(ctx, []requests.UpdateDeviceRequest{{
+		BaseRequest: commonDTO.NewBaseRequest(),
+		Device:      pd}})
+}
@@ -0,0 +1,239 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package transformer
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/clients/interfaces"
+	commonDTO "github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/requests"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+func TestAssertionCheck(t *testing.T) {
+	expr := common.AssertionExpressionPrefix
+	tests := []struct {
+		rule     string
+		value    string
+		expected bool
+	}{
+		{"OK", "OK", true},
+		{"OK", "FAIL", false},
+		{expr + "==5", "5.0", true},
+		{expr + "==on", "on", true},
+		{expr + "!=0", "1", true},
+		{expr + "!=0", "0", false},
+		{expr + "!=off", "off", false},
+		{expr + ">10", "10.5", true},
+		{expr + ">10", "10", false},
+		{expr + ">=10", "10", true},
+		{expr + "<0", "-1", true},
+		{expr + "<=0", "0.1", false},
+		{expr + "0..100", "50", true},
+		{expr + "0..100", "101", false},
+		{expr + "-10..-1", "-5", true},
+		{expr + "/^ok|ready$/", "ready", true},
+		{expr + "/^ok|ready$/", "error", false},
+		{expr + ">10", "high", false},
+		{"<80", "<80", true},
+		{"<80", "79", false},
+		{"5", "5.0", false},
+		{"/OK/", "OK", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule+" "+tt.value, func(t *testing.T) {
+			a, err := ParseAssertion(tt.rule)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, a.Check(tt.value, false, 0))
+		})
+	}
+}
+
+func TestAssertionCheck_Hysteresis(t *testing.T) {
+	a, err := ParseAssertion(common.AssertionExpressionPrefix + "<80")
+	require.NoError(t, err)
+	assert.True(t, a.Check("79", false, 5))
+	// a violated assertion requires the value to go below 75 to recover
+	assert.False(t, a.Check("79", true, 5))
+	assert.True(t, a.Check("74", true, 5))
+
+	a, err = ParseAssertion(common.AssertionExpressionPrefix + " 10..20")
+	require.NoError(t, err)
+	assert.False(t, a.Check("11", true, 2))
+	assert.True(t, a.Check("12", true, 2))
+	assert.False(t, a.Check("19", true, 2))
+}
+
+func TestParseAssertion_Invalid(t *testing.T) {
+	for _, rule := range []string{">abc", "<=", "/[/", "10..0", "10", "ok"} {
+		_, err := ParseAssertion(common.AssertionExpressionPrefix + rule)
+		assert.Error(t, err, rule)
+	}
+}
+
+func TestCheckAssertion_Alarm(t *testing.T) {
+	device := &models.Device{Name: "test-alarm-device", OperatingState: models.Up}
+	dr := models.DeviceResource{
+		Name:       "temperature",
+		Properties: models.PropertyValue{Assertion: common.AssertionExpressionPrefix + "<80"},
+		Attributes: map[string]string{
+			common.AttributeAssertionAlarm:          "temperatureAlarm",
+			common.AttributeAssertionHysteresis:     "5",
+			common.AttributeAssertionOperatingState: "false",
+		},
+	}
+	check := func(v float64) *dsModels.CommandValue {
+		cv, err := dsModels.NewFloat64Value(dr.Name, 0, v)
+		require.NoError(t, err)
+		alarm, err := CheckAssertion(cv, dr, device, lc, nil)
+		require.NoError(t, err)
+		return alarm
+	}
+
+	assert.Nil(t, check(70), "no alarm for a normal value")
+
+	alarm := check(85)
+	require.NotNil(t, alarm)
+	assert.Equal(t, "temperatureAlarm", alarm.DeviceResourceName)
+	assert.Equal(t, "true", alarm.ValueToString())
+
+	alarm = check(78)
+	require.NotNil(t, alarm, "still violated within the hysteresis")
+	assert.Equal(t, "true", alarm.ValueToString())
+
+	alarm = check(70)
+	require.NotNil(t, alarm, "recovery alarm")
+	assert.Equal(t, "false", alarm.ValueToString())
+
+	assert.Nil(t, check(71))
+	assert.Equal(t, models.OperatingState(models.Up), device.OperatingState)
+}
+
+func TestCheckAssertion_Legacy(t *testing.T) {
+	device := &models.Device{Name: "test-legacy-device", OperatingState: models.Down}
+	dr := models.DeviceResource{
+		Name:       "status",
+		Properties: models.PropertyValue{Assertion: "OK"},
+		Attributes: map[string]string{common.AttributeAssertionOperatingState: "false"},
+	}
+
+	alarm, err := CheckAssertion(dsModels.NewStringValue(dr.Name, 0, "OK"), dr, device, lc, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, alarm)
+
+	alarm, err = CheckAssertion(dsModels.NewStringValue(dr.Name, 0, "FAIL"), dr, device, lc, nil)
+	assert.Error(t, err)
+	assert.Nil(t, alarm)
+}
+
+// deviceClientRecorder records the device updates
+type deviceClientRecorder struct {
+	interfaces.DeviceClient
+	updates chan requests.UpdateDeviceRequest
+}
+
+func (dc *deviceClientRecorder) Update(_ context.Context, reqs []requests.UpdateDeviceRequest) ([]commonDTO.BaseResponse, errors.EdgeX) {
+	for _, req := range reqs {
+		dc.updates <- req
+	}
+	return nil, nil
+}
+
+func TestCheckAssertion_RecoverDownDevice(t *testing.T) {
+	dir, err := ioutil.TempDir("", "assertion-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	snapshot := filepath.Join(dir, "snapshot.json")
+	require.NoError(t, ioutil.WriteFile(snapshot, []byte("{}"), 0600))
+	_, err = cache.InitCacheFromSnapshot(snapshot, lc)
+	require.NoError(t, err)
+
+	profile := models.DeviceProfile{
+		Name: "test-recover-profile",
+		DeviceResources: []models.DeviceResource{
+			{Name: "temperature", Properties: models.PropertyValue{Assertion: common.AssertionExpressionPrefix + "<80"}},
+			{Name: "humidity", Properties: models.PropertyValue{Assertion: common.AssertionExpressionPrefix + "<90"}},
+			{Name: "status", Properties: models.PropertyValue{Assertion: "OK"}, Attributes: map[string]string{common.AttributeAssertionOperatingState: "false"}},
+		},
+	}
+	require.NoError(t, cache.Profiles().Add(profile))
+	dc := &deviceClientRecorder{updates: make(chan requests.UpdateDeviceRequest, 1)}
+	check := func(device *models.Device, name string, v float64) {
+		dr, ok := cache.DeviceResourceOf(*device, name)
+		require.True(t, ok)
+		cv, err := dsModels.NewFloat64Value(name, 0, v)
+		require.NoError(t, err)
+		_, _ = CheckAssertion(cv, dr, device, lc, dc)
+	}
+	cached := func(device *models.Device) models.Device {
+		d, ok := cache.Devices().ForName(device.Name)
+		require.True(t, ok)
+		return d
+	}
+
+	device := &models.Device{Name: "test-recover-device", ProfileName: profile.Name, OperatingState: models.Up, Labels: []string{"sensor"}}
+	require.NoError(t, cache.Devices().Add(*device))
+	check(device, "humidity", 50)
+	check(device, "temperature", 90)
+	assert.Equal(t, models.OperatingState(models.Down), device.OperatingState, "violated")
+	assert.Equal(t, []string{"sensor", common.DeviceLabelAssertionDown}, device.Labels)
+	update := <-dc.updates
+	assert.Equal(t, models.Down, *update.Device.OperatingState)
+	assert.Equal(t, []string{"sensor", common.DeviceLabelAssertionDown}, update.Device.Labels)
+
+	check(device, "temperature", 70)
+	assert.Equal(t, models.OperatingState(models.Up), device.OperatingState, "recovered")
+	assert.Equal(t, []string{"sensor"}, device.Labels)
+	update = <-dc.updates
+	assert.Equal(t, models.Up, *update.Device.OperatingState)
+	assert.Equal(t, []string{"sensor"}, update.Device.Labels)
+	assert.Equal(t, models.OperatingState(models.Up), cached(device).OperatingState)
+
+	// after a restart, the device recovers once all its asserted resources are checked
+	ResetAssertions(device.Name)
+	device.OperatingState = models.Down
+	device.Labels = []string{common.DeviceLabelAssertionDown}
+	check(device, "temperature", 70)
+	assert.Equal(t, models.OperatingState(models.Down), device.OperatingState, "humidity not checked yet")
+	assert.Len(t, dc.updates, 0)
+	check(device, "humidity", 50)
+	assert.Equal(t, models.OperatingState(models.Up), device.OperatingState)
+	assert.Empty(t, device.Labels)
+	<-dc.updates
+
+	// a device also DOWN as missed by the discoveries is left DOWN
+	ResetAssertions(device.Name)
+	device.OperatingState = models.Down
+	device.Labels = []string{common.DeviceLabelDiscoveryDown, common.DeviceLabelAssertionDown}
+	check(device, "temperature", 70)
+	check(device, "humidity", 50)
+	assert.Equal(t, models.OperatingState(models.Down), device.OperatingState)
+	assert.Equal(t, []string{common.DeviceLabelDiscoveryDown}, device.Labels)
+	<-dc.updates
+
+	// a device DOWN for another reason isn't marked UP
+	manual := &models.Device{Name: "test-manual-down-device", ProfileName: profile.Name, OperatingState: models.Down}
+	require.NoError(t, cache.Devices().Add(*manual))
+	check(manual, "temperature", 90)
+	check(manual, "temperature", 70)
+	check(manual, "humidity", 50)
+	assert.Equal(t, models.OperatingState(models.Down), manual.OperatingState)
+	assert.Empty(t, manual.Labels)
+	assert.Len(t, dc.updates, 0)
+}
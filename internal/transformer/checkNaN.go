@@ -14,11 +14,7 @@ import (
 )
 
 // NaNError is used to throw the NaN error for the floating-point value
-type NaNError struct{}
-
-func (e NaNError) Error() string {
-	return "not a valid float value NaN"
-}
+type NaNError = models.NaNError
 
 func isNaN(cv *models.CommandValue) (bool, error) {
 	switch cv.Type {
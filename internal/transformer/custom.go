@@ -0,0 +1,141 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package transformer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+// The custom transforms listed by the ds-transform attribute of a DeviceResource are applied:
+//   - on read, in the listed order after the value is extracted and decoded from the device
+//     payload and before mask, shift, base, scale, offset, assertion and mapping
+//   - on write, in the reverse order after mapping, offset, scale and base are reverted
+//
+// Unlike the built-in transforms they are applied regardless of Device.DataTransform.
+var (
+	readTransforms  = make(map[string]dsModels.TransformFunc)
+	writeTransforms = make(map[string]dsModels.TransformFunc)
+	customMutex     sync.RWMutex
+)
+
+// RegisterReadTransform registers the named custom read transform.
+func RegisterReadTransform(name string, fn dsModels.TransformFunc) error {
+	return registerTransform(readTransforms, name, fn)
+}
+
+// RegisterWriteTransform registers the named custom write transform.
+func RegisterWriteTransform(name string, fn dsModels.TransformFunc) error {
+	return registerTransform(writeTransforms, name, fn)
+}
+
+func registerTransform(transforms map[string]dsModels.TransformFunc, name string, fn dsModels.TransformFunc) error {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.Contains(name, ",") {
+		return fmt.Errorf("invalid transform name '%s'", name)
+	}
+	if fn == nil {
+		return fmt.Errorf("transform %s is nil", name)
+	}
+
+	customMutex.Lock()
+	defer customMutex.Unlock()
+	if _, ok := transforms[name]; ok {
+		return fmt.Errorf("transform %s already registered", name)
+	}
+	transforms[name] = fn
+	return nil
+}
+
+// ApplyReadTransforms applies the custom read transforms of the DeviceResource to the
+// CommandValue. On failure the given CommandValue is returned along with the error.
+func ApplyReadTransforms(cv *dsModels.CommandValue, dr models.DeviceResource) (*dsModels.CommandValue, error) {
+	names := transformNames(dr)
+	return applyTransforms(readTransforms, writeTransforms, names, cv, dr)
+}
+
+// ApplyWriteTransforms applies the custom write transforms of the DeviceResource to the
+// CommandValue. On failure the given CommandValue is returned along with the error.
+func ApplyWriteTransforms(cv *dsModels.CommandValue, dr models.DeviceResource) (*dsModels.CommandValue, error) {
+	names := transformNames(dr)
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return applyTransforms(writeTransforms, readTransforms, names, cv, dr)
+}
+
+// applyTransforms applies the named transforms, names only registered in the other
+// direction are skipped
+func applyTransforms(
+	transforms map[string]dsModels.TransformFunc,
+	others map[string]dsModels.TransformFunc,
+	names []string,
+	cv *dsModels.CommandValue,
+	dr models.DeviceResource) (*dsModels.CommandValue, error) {
+	if len(names) == 0 {
+		return cv, nil
+	}
+
+	customMutex.RLock()
+	defer customMutex.RUnlock()
+	result := cv
+	for _, name := range names {
+		fn, ok := transforms[name]
+		if !ok {
+			if _, ok := others[name]; ok {
+				continue
+			}
+			return cv, fmt.Errorf("transform %s of deviceResource %s is not registered", name, dr.Name)
+		}
+		transformed, err := fn(result, dr)
+		if err != nil {
+			return cv, fmt.Errorf("transform %s failed for device resource '%s', error: %w", name, dr.Name, err)
+		}
+		if transformed == nil {
+			return cv, fmt.Errorf("transform %s returned no value for device resource '%s'", name, dr.Name)
+		}
+		// the reading keeps the name of the DeviceResource
+		transformed.DeviceResourceName = cv.DeviceResourceName
+		result = transformed
+	}
+	return result, nil
+}
+
+func transformNames(dr models.DeviceResource) []string {
+	attribute, ok := dr.Attributes[common.AttributeTransform]
+	if !ok {
+		return nil
+	}
+	var names []string
+	for _, name := range strings.Split(attribute, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// UnregisteredTransforms returns the names listed by the ds-transform attribute of the
+// DeviceResource which are registered in neither direction.
+func UnregisteredTransforms(dr models.DeviceResource) []string {
+	customMutex.RLock()
+	defer customMutex.RUnlock()
+	var unregistered []string
+	for _, name := range transformNames(dr) {
+		_, read := readTransforms[name]
+		_, write := writeTransforms[name]
+		if !read && !write {
+			unregistered = append(unregistered, name)
+		}
+	}
+	return unregistered
+}
@@ -0,0 +1,81 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package transformer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+func TestCustomTransforms(t *testing.T) {
+	double := func(cv *dsModels.CommandValue, dr models.DeviceResource) (*dsModels.CommandValue, error) {
+		v, err := cv.Int32Value()
+		if err != nil {
+			return nil, err
+		}
+		return dsModels.NewInt32Value(cv.DeviceResourceName, cv.Origin, v*2)
+	}
+	increment := func(cv *dsModels.CommandValue, dr models.DeviceResource) (*dsModels.CommandValue, error) {
+		v, err := cv.Int32Value()
+		if err != nil {
+			return nil, err
+		}
+		return dsModels.NewInt32Value(cv.DeviceResourceName, cv.Origin, v+1)
+	}
+	overflow := func(cv *dsModels.CommandValue, dr models.DeviceResource) (*dsModels.CommandValue, error) {
+		return nil, dsModels.NewOverflowError(int32(0), 1e20)
+	}
+	require.NoError(t, RegisterReadTransform("test-double", double))
+	require.NoError(t, RegisterReadTransform("test-increment", increment))
+	require.NoError(t, RegisterWriteTransform("test-increment", double))
+	require.NoError(t, RegisterReadTransform("test-overflow", overflow))
+	assert.Error(t, RegisterReadTransform("test-double", double), "duplicated name")
+	assert.Error(t, RegisterReadTransform("a,b", double), "name with separator")
+	assert.Error(t, RegisterWriteTransform("test-nil", nil), "nil transform")
+
+	dr := models.DeviceResource{
+		Name:       "temperature",
+		Attributes: map[string]string{common.AttributeTransform: "test-double, test-increment"},
+	}
+	cv, err := dsModels.NewInt32Value("temperature", 0, 10)
+	require.NoError(t, err)
+
+	// read: (10 * 2) + 1
+	result, err := ApplyReadTransforms(cv, dr)
+	require.NoError(t, err)
+	v, err := result.Int32Value()
+	require.NoError(t, err)
+	assert.Equal(t, int32(21), v)
+
+	// write: test-increment only, test-double has no write transform
+	result, err = ApplyWriteTransforms(cv, dr)
+	require.NoError(t, err)
+	v, err = result.Int32Value()
+	require.NoError(t, err)
+	assert.Equal(t, int32(20), v)
+
+	dr.Attributes[common.AttributeTransform] = "test-overflow"
+	result, err = ApplyReadTransforms(cv, dr)
+	assert.True(t, errors.As(err, &OverflowError{}))
+	assert.Equal(t, cv, result)
+
+	dr.Attributes[common.AttributeTransform] = "test-unknown"
+	_, err = ApplyReadTransforms(cv, dr)
+	assert.Error(t, err)
+
+	delete(dr.Attributes, common.AttributeTransform)
+	result, err = ApplyReadTransforms(cv, dr)
+	require.NoError(t, err)
+	assert.Equal(t, cv, result)
+}
@@ -0,0 +1,24 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package transformer
+
+import (
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/pkg/codec"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+// DecodeBinaryValue decodes a Binary CommandValue returned by the ProtocolDriver for a
+// DeviceResource of another value type, the layout of the payload is described by the
+// attributes of the DeviceResource. Other CommandValues are returned untouched.
+func DecodeBinaryValue(cv *dsModels.CommandValue, dr models.DeviceResource) (*dsModels.CommandValue, error) {
+	if cv.Type != contracts.ValueTypeBinary || dr.Properties.Type == "" || dr.Properties.Type == contracts.ValueTypeBinary {
+		return cv, nil
+	}
+	return codec.DecodeCommandValue(cv, cv.DeviceResourceName, dr.Properties.Type, dr.Attributes)
+}
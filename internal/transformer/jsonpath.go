@@ -0,0 +1,209 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package transformer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+// ExtractJSONPath extracts the value selected by the ds-jsonPath attribute of the DeviceResource
+// from a String (or Binary) CommandValue holding a JSON document, the extracted value is coerced
+// to the Properties.Type of the DeviceResource. CommandValues of DeviceResources without the
+// ds-jsonPath attribute are returned untouched.
+//
+// The supported path syntax is a subset of JSONPath: an optional leading '$' followed by
+// '.name', "['name']" and '[index]' segments, e.g. "$.sensors[0].temperature".
+func ExtractJSONPath(cv *dsModels.CommandValue, dr models.DeviceResource) (*dsModels.CommandValue, error) {
+	path, ok := dr.Attributes[common.AttributeJSONPath]
+	if !ok {
+		return cv, nil
+	}
+
+	var doc []byte
+	switch cv.Type {
+	case contracts.ValueTypeString:
+		s, err := cv.StringValue()
+		if err != nil {
+			return nil, err
+		}
+		doc = []byte(s)
+	case contracts.ValueTypeBinary:
+		doc = cv.BinValue
+	default:
+		// already extracted, e.g. by the ProtocolDriver
+		return cv, nil
+	}
+
+	var root interface{}
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.UseNumber()
+	if err := decoder.Decode(&root); err != nil {
+		return nil, fmt.Errorf("deviceResource %s value is not a valid JSON document: %v", dr.Name, err)
+	}
+
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s '%s' of deviceResource %s: %v", common.AttributeJSONPath, path, dr.Name, err)
+	}
+	v, err := selectJSONPath(root, segments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract %s from deviceResource %s value: %v", path, dr.Name, err)
+	}
+
+	valueType := dr.Properties.Type
+	if valueType == "" {
+		valueType = contracts.ValueTypeString
+	}
+	value, err := coerceJSONValue(v, valueType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to coerce %s of deviceResource %s to %s: %v", path, dr.Name, valueType, err)
+	}
+	return dsModels.NewCommandValue(cv.DeviceResourceName, cv.Origin, value, valueType)
+}
+
+// parseJSONPath splits the path into object keys (string) and array indexes (int)
+func parseJSONPath(path string) ([]interface{}, error) {
+	p := strings.TrimSpace(path)
+	p = strings.TrimPrefix(p, "$")
+
+	var segments []interface{}
+	for len(p) > 0 {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty key")
+			}
+			segments = append(segments, p[:end])
+			p = p[end:]
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing ']'")
+			}
+			inner := strings.TrimSpace(p[1:end])
+			p = p[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				segments = append(segments, inner[1:len(inner)-1])
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index '%s'", inner)
+			}
+			segments = append(segments, index)
+		default:
+			// a path without leading '$' or '.', e.g. "data.value"
+			if len(segments) > 0 {
+				return nil, fmt.Errorf("unexpected '%c'", p[0])
+			}
+			p = "." + p
+		}
+	}
+	return segments, nil
+}
+
+func selectJSONPath(v interface{}, segments []interface{}) (interface{}, error) {
+	for _, segment := range segments {
+		switch s := segment.(type) {
+		case string:
+			object, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("'%s' selected on a non-object value", s)
+			}
+			if v, ok = object[s]; !ok {
+				return nil, fmt.Errorf("key '%s' not found", s)
+			}
+		case int:
+			array, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("index %d selected on a non-array value", s)
+			}
+			index := s
+			if index < 0 {
+				index += len(array)
+			}
+			if index < 0 || index >= len(array) {
+				return nil, fmt.Errorf("index %d out of range", s)
+			}
+			v = array[index]
+		}
+	}
+	return v, nil
+}
+
+// coerceJSONValue converts a decoded JSON value to the Go type of the given value type
+func coerceJSONValue(v interface{}, valueType string) (interface{}, error) {
+	if v == nil {
+		return nil, fmt.Errorf("value is null")
+	}
+
+	var text string
+	switch jv := v.(type) {
+	case string:
+		text = jv
+	case json.Number:
+		text = jv.String()
+	case bool:
+		text = strconv.FormatBool(jv)
+	default:
+		// objects and arrays are kept as JSON documents
+		if valueType != contracts.ValueTypeString {
+			return nil, fmt.Errorf("value %v is not a scalar", v)
+		}
+		b, err := json.Marshal(jv)
+		return string(b), err
+	}
+
+	switch valueType {
+	case contracts.ValueTypeString:
+		return text, nil
+	case contracts.ValueTypeBool:
+		return strconv.ParseBool(text)
+	case contracts.ValueTypeFloat32:
+		f, err := strconv.ParseFloat(text, 32)
+		return float32(f), err
+	case contracts.ValueTypeFloat64:
+		return strconv.ParseFloat(text, 64)
+	case contracts.ValueTypeUint8:
+		u, err := strconv.ParseUint(text, 10, 8)
+		return uint8(u), err
+	case contracts.ValueTypeUint16:
+		u, err := strconv.ParseUint(text, 10, 16)
+		return uint16(u), err
+	case contracts.ValueTypeUint32:
+		u, err := strconv.ParseUint(text, 10, 32)
+		return uint32(u), err
+	case contracts.ValueTypeUint64:
+		return strconv.ParseUint(text, 10, 64)
+	case contracts.ValueTypeInt8:
+		i, err := strconv.ParseInt(text, 10, 8)
+		return int8(i), err
+	case contracts.ValueTypeInt16:
+		i, err := strconv.ParseInt(text, 10, 16)
+		return int16(i), err
+	case contracts.ValueTypeInt32:
+		i, err := strconv.ParseInt(text, 10, 32)
+		return int32(i), err
+	case contracts.ValueTypeInt64:
+		return strconv.ParseInt(text, 10, 64)
+	}
+	return nil, fmt.Errorf("value type %s is not supported", valueType)
+}
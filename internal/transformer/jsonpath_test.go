@@ -0,0 +1,101 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package transformer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+const testDocument = `{"status": "ok", "online": true, "sensors": [{"temperature": 21.5, "humidity": 40}, {"temperature": -3, "humidity": 85}], "meta": {"fw-version": "1.2"}}`
+
+func jsonPathResource(path string, valueType string) models.DeviceResource {
+	return models.DeviceResource{
+		Name:       "test-resource",
+		Properties: models.PropertyValue{Type: valueType},
+		Attributes: map[string]string{common.AttributeJSONPath: path},
+	}
+}
+
+func TestExtractJSONPath(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		valueType string
+		expected  string
+	}{
+		{"string", "$.status", contracts.ValueTypeString, "ok"},
+		{"bool", "$.online", contracts.ValueTypeBool, "true"},
+		{"float", "$.sensors[0].temperature", contracts.ValueTypeFloat64, "21.5"},
+		{"negative int", "$.sensors[1].temperature", contracts.ValueTypeInt16, "-3"},
+		{"negative index", "$.sensors[-1].humidity", contracts.ValueTypeUint8, "85"},
+		{"bracket key", "$.meta['fw-version']", contracts.ValueTypeString, "1.2"},
+		{"string to float", "$.meta[\"fw-version\"]", contracts.ValueTypeFloat32, "1.2"},
+		{"without root", "sensors[0].humidity", contracts.ValueTypeInt32, "40"},
+		{"object as string", "$.sensors[1]", contracts.ValueTypeString, `{"humidity":85,"temperature":-3}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cv := dsModels.NewStringValue("test-resource", 1, testDocument)
+			result, err := ExtractJSONPath(cv, jsonPathResource(tt.path, tt.valueType))
+			require.NoError(t, err)
+			assert.Equal(t, tt.valueType, result.Type)
+			assert.Equal(t, "test-resource", result.DeviceResourceName)
+			assert.Equal(t, int64(1), result.Origin)
+			assert.Equal(t, tt.expected, result.ValueToString())
+		})
+	}
+}
+
+func TestExtractJSONPath_Binary(t *testing.T) {
+	cv, err := dsModels.NewBinaryValue("test-resource", 0, []byte(testDocument))
+	require.NoError(t, err)
+	result, err := ExtractJSONPath(cv, jsonPathResource("$.sensors[0].humidity", contracts.ValueTypeUint16))
+	require.NoError(t, err)
+	v, err := result.Uint16Value()
+	require.NoError(t, err)
+	assert.Equal(t, uint16(40), v)
+}
+
+func TestExtractJSONPath_NoAttribute(t *testing.T) {
+	cv := dsModels.NewStringValue("test-resource", 0, testDocument)
+	result, err := ExtractJSONPath(cv, models.DeviceResource{Name: "test-resource"})
+	require.NoError(t, err)
+	assert.Equal(t, cv, result)
+}
+
+func TestExtractJSONPath_Error(t *testing.T) {
+	tests := []struct {
+		name      string
+		document  string
+		path      string
+		valueType string
+	}{
+		{"invalid document", "{", "$.status", contracts.ValueTypeString},
+		{"missing key", testDocument, "$.missing", contracts.ValueTypeString},
+		{"index out of range", testDocument, "$.sensors[2]", contracts.ValueTypeString},
+		{"index on object", testDocument, "$.meta[0]", contracts.ValueTypeString},
+		{"invalid path", testDocument, "$.sensors[x]", contracts.ValueTypeString},
+		{"overflow", testDocument, "$.sensors[1].temperature", contracts.ValueTypeUint8},
+		{"not a number", testDocument, "$.status", contracts.ValueTypeInt32},
+		{"not a scalar", testDocument, "$.sensors", contracts.ValueTypeInt32},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cv := dsModels.NewStringValue("test-resource", 0, tt.document)
+			_, err := ExtractJSONPath(cv, jsonPathResource(tt.path, tt.valueType))
+			assert.Error(t, err)
+		})
+	}
+}
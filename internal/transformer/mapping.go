@@ -0,0 +1,143 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package transformer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+// The keys of ResourceOperation.Mappings are matched against the value in the following order:
+//   - the exact value, e.g. "1": "on"
+//   - numeric ranges with inclusive and optional bounds, e.g. "0..10": "low", "10..": "high"
+//   - regular expressions between slashes, e.g. "/^err-.*$/": "error"
+//   - the MappingDefault entry
+//
+// Ranges are tried by ascending lower bound and regular expressions by key, so that the result
+// doesn't depend on the map order. The MappingStrict entry set to "true" fails the mapping of an
+// unmatched value instead of passing it through.
+const (
+	MappingDefault = common.SDKReservedPrefix + "default"
+	MappingStrict  = common.SDKReservedPrefix + "strict"
+)
+
+// MappingError is returned by the strict mappings when no rule matches the value
+type MappingError struct {
+	value string
+}
+
+func (e MappingError) Error() string {
+	return fmt.Sprintf("value '%s' matches no mapping rule", e.value)
+}
+
+type rangeRule struct {
+	min, max float64
+	result   string
+}
+
+type regexRule struct {
+	key    string
+	regex  *regexp.Regexp
+	result string
+}
+
+var regexCache sync.Map
+
+// MapValue maps the value with the mapping rules, the returned bool indicates whether a rule
+// matched the value. A MappingError is returned for an unmatched value in strict mode.
+func MapValue(value string, mappings map[string]string) (string, bool, error) {
+	if result, ok := mappings[value]; ok && !isReservedMapping(value) {
+		return result, true, nil
+	}
+
+	var ranges []rangeRule
+	var regexes []regexRule
+	for key, result := range mappings {
+		if isReservedMapping(key) {
+			continue
+		}
+		if r, ok, err := parseRange(key); err != nil {
+			return "", false, err
+		} else if ok {
+			r.result = result
+			ranges = append(ranges, r)
+			continue
+		}
+		if len(key) >= 2 && key[0] == '/' && key[len(key)-1] == '/' {
+			regex, err := compileMappingRegex(key[1 : len(key)-1])
+			if err != nil {
+				return "", false, fmt.Errorf("invalid mapping rule %s: %v", key, err)
+			}
+			regexes = append(regexes, regexRule{key: key, regex: regex, result: result})
+		}
+	}
+
+	if len(ranges) > 0 {
+		if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			sort.Slice(ranges, func(i, j int) bool { return ranges[i].min < ranges[j].min })
+			for _, r := range ranges {
+				if v >= r.min && v <= r.max {
+					return r.result, true, nil
+				}
+			}
+		}
+	}
+
+	sort.Slice(regexes, func(i, j int) bool { return regexes[i].key < regexes[j].key })
+	for _, r := range regexes {
+		if r.regex.MatchString(value) {
+			return r.result, true, nil
+		}
+	}
+
+	if result, ok := mappings[MappingDefault]; ok {
+		return result, true, nil
+	}
+	if strict, _ := strconv.ParseBool(mappings[MappingStrict]); strict {
+		return "", false, MappingError{value: value}
+	}
+	return value, false, nil
+}
+
+func isReservedMapping(key string) bool {
+	return key == MappingDefault || key == MappingStrict
+}
+
+// parseRange parses the range rules like "0..10", "..0" or "10.."
+func parseRange(key string) (rangeRule, bool, error) {
+	min, max, ok, err := common.ParseMappingRange(key)
+	return rangeRule{min: min, max: max}, ok, err
+}
+
+func compileMappingRegex(pattern string) (*regexp.Regexp, error) {
+	if regex, ok := regexCache.Load(pattern); ok {
+		return regex.(*regexp.Regexp), nil
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.Store(pattern, regex)
+	return regex, nil
+}
+
+// MapCommandValue maps the value of the CommandValue with the mapping rules, the returned bool
+// indicates whether a rule matched and the mapped String CommandValue is returned.
+func MapCommandValue(value *dsModels.CommandValue, mappings map[string]string) (*dsModels.CommandValue, bool, error) {
+	newValue, ok, err := MapValue(value.ValueToString(), mappings)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	return dsModels.NewStringValue(value.DeviceResourceName, value.Origin, newValue), true, nil
+}
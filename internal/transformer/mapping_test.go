@@ -0,0 +1,99 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package transformer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+func TestMapValue(t *testing.T) {
+	mappings := map[string]string{
+		"5":          "exact",
+		"0..10":      "low",
+		"10.5..":     "high",
+		"..-1":       "negative",
+		"/^err-.*$/": "error",
+		"/^e/":       "e-prefix",
+	}
+	tests := []struct {
+		value    string
+		expected string
+		ok       bool
+	}{
+		{"5", "exact", true},
+		{"0", "low", true},
+		{"10", "low", true},
+		{"7.5", "low", true},
+		{"10.2", "10.2", false},
+		{"100", "high", true},
+		{"-3", "negative", true},
+		{"err-42", "e-prefix", true},
+		{"unknown", "unknown", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			v, ok, err := MapValue(tt.value, mappings)
+			require.NoError(t, err)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, v)
+		})
+	}
+}
+
+func TestMapValue_Default(t *testing.T) {
+	mappings := map[string]string{"1": "on", MappingDefault: "off"}
+	v, ok, err := MapValue("3", mappings)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "off", v)
+}
+
+func TestMapValue_Strict(t *testing.T) {
+	mappings := map[string]string{"1": "on", MappingStrict: "true"}
+	v, ok, err := MapValue("1", mappings)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "on", v)
+
+	_, ok, err = MapValue("3", mappings)
+	assert.False(t, ok)
+	assert.True(t, errors.As(err, &MappingError{}))
+
+	// reserved keys are not mapped as values
+	_, _, err = MapValue(MappingStrict, mappings)
+	assert.Error(t, err)
+}
+
+func TestMapValue_InvalidRule(t *testing.T) {
+	_, _, err := MapValue("1", map[string]string{"/[/": "invalid"})
+	assert.Error(t, err)
+	_, _, err = MapValue("1", map[string]string{"10..0": "invalid"})
+	assert.Error(t, err)
+}
+
+func TestMapCommandValue(t *testing.T) {
+	cv, err := dsModels.NewUint8Value("test-resource", 1, 3)
+	require.NoError(t, err)
+
+	result, ok, err := MapCommandValue(cv, map[string]string{"0..5": "low"})
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "test-resource", result.DeviceResourceName)
+	assert.Equal(t, int64(1), result.Origin)
+	assert.Equal(t, "low", result.ValueToString())
+
+	result, ok, err = MapCommandValue(cv, map[string]string{"7": "seven"})
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, result)
+}
@@ -6,22 +6,11 @@
 
 package transformer
 
-import "fmt"
+import dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
 
 // OverflowError is used to throw the error of transformed value is out of range
-type OverflowError struct {
-	origin      interface{}
-	transformed float64
-}
-
-func (e OverflowError) Error() string {
-	return fmt.Sprintf("overflow failed, transformed value '%v' is not within the '%T' value type range", e.transformed, e.origin)
-}
-
-func (e OverflowError) String() string {
-	return fmt.Sprintf("overflow failed, transformed value '%v' is not within the '%T' value type range", e.transformed, e.origin)
-}
+type OverflowError = dsModels.OverflowError
 
 func NewOverflowError(origin interface{}, transformed float64) OverflowError {
-	return OverflowError{origin: origin, transformed: transformed}
+	return dsModels.NewOverflowError(origin, transformed)
 }
@@ -0,0 +1,201 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package transformer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+func isNumericArray(valueType string) bool {
+	switch valueType {
+	case contracts.ValueTypeUint8Array, contracts.ValueTypeUint16Array, contracts.ValueTypeUint32Array, contracts.ValueTypeUint64Array,
+		contracts.ValueTypeInt8Array, contracts.ValueTypeInt16Array, contracts.ValueTypeInt32Array, contracts.ValueTypeInt64Array,
+		contracts.ValueTypeFloat32Array, contracts.ValueTypeFloat64Array:
+		return true
+	}
+	return false
+}
+
+func isNaNValue(value interface{}) bool {
+	switch v := value.(type) {
+	case float32:
+		return math.IsNaN(float64(v))
+	case float64:
+		return math.IsNaN(v)
+	}
+	return false
+}
+
+// arrayValueForTransform returns the elements of a numeric array CommandValue, each element
+// has the scalar type of the array so that the scalar transforms apply
+func arrayValueForTransform(cv *dsModels.CommandValue) ([]interface{}, error) {
+	var values []interface{}
+	switch cv.Type {
+	case contracts.ValueTypeUint8Array:
+		arr, err := cv.Uint8ArrayValue()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range arr {
+			values = append(values, v)
+		}
+	case contracts.ValueTypeUint16Array:
+		arr, err := cv.Uint16ArrayValue()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range arr {
+			values = append(values, v)
+		}
+	case contracts.ValueTypeUint32Array:
+		arr, err := cv.Uint32ArrayValue()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range arr {
+			values = append(values, v)
+		}
+	case contracts.ValueTypeUint64Array:
+		arr, err := cv.Uint64ArrayValue()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range arr {
+			values = append(values, v)
+		}
+	case contracts.ValueTypeInt8Array:
+		arr, err := cv.Int8ArrayValue()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range arr {
+			values = append(values, v)
+		}
+	case contracts.ValueTypeInt16Array:
+		arr, err := cv.Int16ArrayValue()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range arr {
+			values = append(values, v)
+		}
+	case contracts.ValueTypeInt32Array:
+		arr, err := cv.Int32ArrayValue()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range arr {
+			values = append(values, v)
+		}
+	case contracts.ValueTypeInt64Array:
+		arr, err := cv.Int64ArrayValue()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range arr {
+			values = append(values, v)
+		}
+	case contracts.ValueTypeFloat32Array:
+		arr, err := cv.Float32ArrayValue()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range arr {
+			values = append(values, v)
+		}
+	case contracts.ValueTypeFloat64Array:
+		arr, err := cv.Float64ArrayValue()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range arr {
+			values = append(values, v)
+		}
+	default:
+		return nil, fmt.Errorf("wrong data type of CommandValue to transform: %s", cv.String())
+	}
+	return values, nil
+}
+
+// replaceNewArrayCommandValue replaces the value of the array CommandValue with the elements
+func replaceNewArrayCommandValue(cv *dsModels.CommandValue, values []interface{}) error {
+	var newCV *dsModels.CommandValue
+	var err error
+	switch cv.Type {
+	case contracts.ValueTypeUint8Array:
+		arr := make([]uint8, len(values))
+		for i, v := range values {
+			arr[i] = v.(uint8)
+		}
+		newCV, err = dsModels.NewUint8ArrayValue(cv.DeviceResourceName, cv.Origin, arr)
+	case contracts.ValueTypeUint16Array:
+		arr := make([]uint16, len(values))
+		for i, v := range values {
+			arr[i] = v.(uint16)
+		}
+		newCV, err = dsModels.NewUint16ArrayValue(cv.DeviceResourceName, cv.Origin, arr)
+	case contracts.ValueTypeUint32Array:
+		arr := make([]uint32, len(values))
+		for i, v := range values {
+			arr[i] = v.(uint32)
+		}
+		newCV, err = dsModels.NewUint32ArrayValue(cv.DeviceResourceName, cv.Origin, arr)
+	case contracts.ValueTypeUint64Array:
+		arr := make([]uint64, len(values))
+		for i, v := range values {
+			arr[i] = v.(uint64)
+		}
+		newCV, err = dsModels.NewUint64ArrayValue(cv.DeviceResourceName, cv.Origin, arr)
+	case contracts.ValueTypeInt8Array:
+		arr := make([]int8, len(values))
+		for i, v := range values {
+			arr[i] = v.(int8)
+		}
+		newCV, err = dsModels.NewInt8ArrayValue(cv.DeviceResourceName, cv.Origin, arr)
+	case contracts.ValueTypeInt16Array:
+		arr := make([]int16, len(values))
+		for i, v := range values {
+			arr[i] = v.(int16)
+		}
+		newCV, err = dsModels.NewInt16ArrayValue(cv.DeviceResourceName, cv.Origin, arr)
+	case contracts.ValueTypeInt32Array:
+		arr := make([]int32, len(values))
+		for i, v := range values {
+			arr[i] = v.(int32)
+		}
+		newCV, err = dsModels.NewInt32ArrayValue(cv.DeviceResourceName, cv.Origin, arr)
+	case contracts.ValueTypeInt64Array:
+		arr := make([]int64, len(values))
+		for i, v := range values {
+			arr[i] = v.(int64)
+		}
+		newCV, err = dsModels.NewInt64ArrayValue(cv.DeviceResourceName, cv.Origin, arr)
+	case contracts.ValueTypeFloat32Array:
+		arr := make([]float32, len(values))
+		for i, v := range values {
+			arr[i] = v.(float32)
+		}
+		newCV, err = dsModels.NewFloat32ArrayValue(cv.DeviceResourceName, cv.Origin, arr)
+	case contracts.ValueTypeFloat64Array:
+		arr := make([]float64, len(values))
+		for i, v := range values {
+			arr[i] = v.(float64)
+		}
+		newCV, err = dsModels.NewFloat64ArrayValue(cv.DeviceResourceName, cv.Origin, arr)
+	default:
+		return fmt.Errorf("wrong data type of CommandValue to replace: %s", cv.String())
+	}
+	if err != nil {
+		return err
+	}
+	*cv = *newCV
+	return nil
+}
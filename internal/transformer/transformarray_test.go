@@ -0,0 +1,114 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package transformer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+func TestTransformReadResult_int16Array(t *testing.T) {
+	cv, err := dsModels.NewInt16ArrayValue("waveform", 0, []int16{-10, 0, 25})
+	require.NoError(t, err)
+	pv := models.PropertyValue{Scale: "2", Offset: "1"}
+
+	err = TransformReadResult(cv, pv, lc)
+	require.NoError(t, err)
+	result, err := cv.Int16ArrayValue()
+	require.NoError(t, err)
+	assert.Equal(t, []int16{-19, 1, 51}, result)
+	assert.Equal(t, contracts.ValueTypeInt16Array, cv.Type)
+	assert.Equal(t, "waveform", cv.DeviceResourceName)
+}
+
+func TestTransformReadResult_uint16Array_mask_shift(t *testing.T) {
+	cv, err := dsModels.NewUint16ArrayValue("channels", 0, []uint16{0x0ff0, 0x1234})
+	require.NoError(t, err)
+	pv := models.PropertyValue{Mask: "255", Shift: "-4"}
+
+	err = TransformReadResult(cv, pv, lc)
+	require.NoError(t, err)
+	result, err := cv.Uint16ArrayValue()
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{0x000f, 0x0003}, result)
+}
+
+func TestTransformReadResult_array_overflow(t *testing.T) {
+	cv, err := dsModels.NewInt8ArrayValue("channels", 0, []int8{1, 100})
+	require.NoError(t, err)
+	pv := models.PropertyValue{Scale: "2"}
+
+	err = TransformReadResult(cv, pv, lc)
+	assert.True(t, errors.As(err, &OverflowError{}))
+	result, err := cv.Int8ArrayValue()
+	require.NoError(t, err)
+	assert.Equal(t, []int8{1, 100}, result, "the value shouldn't be partially transformed")
+}
+
+func TestTransformWriteParameter_float32Array(t *testing.T) {
+	cv, err := dsModels.NewFloat32ArrayValue("setpoints", 0, []float32{1.5, 3})
+	require.NoError(t, err)
+	pv := models.PropertyValue{Scale: "0.5", Offset: "1"}
+
+	err = TransformWriteParameter(cv, pv, lc)
+	require.NoError(t, err)
+	result, err := cv.Float32ArrayValue()
+	require.NoError(t, err)
+	assert.Equal(t, []float32{1, 4}, result)
+}
+
+func TestTransformWriteParameter_boolArray(t *testing.T) {
+	cv, err := dsModels.NewBoolArrayValue("switches", 0, []bool{true, false})
+	require.NoError(t, err)
+
+	err = TransformWriteParameter(cv, models.PropertyValue{Scale: "2"}, lc)
+	assert.NoError(t, err)
+}
+
+func TestCheckArrayRange(t *testing.T) {
+	pv := models.PropertyValue{Minimum: "-10", Maximum: "10"}
+	outOfRange, err := dsModels.NewFloat64Value("temperature", 0, 10.5)
+	require.NoError(t, err)
+	arrayInRange, err := dsModels.NewInt16ArrayValue("waveform", 0, []int16{-10, 0, 10})
+	require.NoError(t, err)
+	arrayOutOfRange, err := dsModels.NewInt16ArrayValue("waveform", 0, []int16{-10, -11})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		cv            *dsModels.CommandValue
+		pv            models.PropertyValue
+		errorExpected bool
+	}{
+		{"array in range", arrayInRange, pv, false},
+		{"array out of range", arrayOutOfRange, pv, true},
+		{"no range", arrayOutOfRange, models.PropertyValue{}, false},
+		{"minimum only", arrayInRange, models.PropertyValue{Minimum: "-9"}, true},
+		{"scalar not checked", outOfRange, pv, false},
+		{"invalid maximum", arrayInRange, models.PropertyValue{Maximum: "ten"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckArrayRange(tt.cv, tt.pv)
+			if tt.errorExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	err = CheckArrayRange(arrayOutOfRange, pv)
+	assert.True(t, errors.As(err, &OverflowError{}), "out of range elements are reported as overflows")
+}
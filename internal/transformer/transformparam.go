@@ -18,36 +18,81 @@ import (
 )
 
 func TransformWriteParameter(cv *dsModels.CommandValue, pv models.PropertyValue, lc logger.LoggingClient) error {
-	var err error
-	if cv.Type == contracts.ValueTypeString || cv.Type == contracts.ValueTypeBool || cv.Type == contracts.ValueTypeBinary {
-		return nil // do nothing for String, Bool and Binary
+	if isNumericArray(cv.Type) {
+		return transformWriteArray(cv, pv, lc)
+	}
+	if cv.Type == contracts.ValueTypeString || cv.Type == contracts.ValueTypeBool || cv.Type == contracts.ValueTypeBinary ||
+		cv.Type == contracts.ValueTypeBoolArray {
+		return nil // do nothing for String, Bool, Binary and BoolArray
 	}
 
 	value, err := commandValueForTransform(cv)
+	if err != nil {
+		return err
+	}
+	newValue, err := transformWriteValue(value, pv, lc)
+	if err != nil {
+		return err
+	}
+
+	if value != newValue {
+		err = replaceNewCommandValue(cv, newValue, lc)
+	}
+	return err
+}
+
+// transformWriteArray applies the write transforms to each element of a numeric array
+func transformWriteArray(cv *dsModels.CommandValue, pv models.PropertyValue, lc logger.LoggingClient) error {
+	values, err := arrayValueForTransform(cv)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for i, value := range values {
+		newValue, err := transformWriteValue(value, pv, lc)
+		if err != nil {
+			return err
+		}
+		if value != newValue {
+			values[i] = newValue
+			changed = true
+		}
+	}
+
+	if changed {
+		err = replaceNewArrayCommandValue(cv, values)
+	}
+	return err
+}
+
+// transformWriteValue reverts offset, scale and base of a numeric value
+func transformWriteValue(value interface{}, pv models.PropertyValue, lc logger.LoggingClient) (interface{}, error) {
+	var err error
 	newValue := value
 
 	if pv.Offset != "" && pv.Offset != defaultOffset {
 		newValue, err = transformWriteOffset(newValue, pv.Offset, lc)
 		if err != nil {
-			return err
+			return value, err
 		}
 	}
 
 	if pv.Scale != "" && pv.Scale != defaultScale {
 		newValue, err = transformWriteScale(newValue, pv.Scale, lc)
 		if err != nil {
-			return err
+			return value, err
 		}
 	}
 
 	if pv.Base != "" && pv.Base != defaultBase {
 		newValue, err = transformWriteBase(newValue, pv.Base, lc)
+		if err != nil {
+			return value, err
+		}
 	}
 
-	if value != newValue {
-		err = replaceNewCommandValue(cv, newValue, lc)
-	}
-	return err
+	return newValue, nil
 }
 
 func transformWriteBase(value interface{}, base string, lc logger.LoggingClient) (interface{}, error) {
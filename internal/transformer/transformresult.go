@@ -8,22 +8,13 @@ package transformer
 
 import (
 	"bytes"
-	"context"
 	"encoding/binary"
 	"fmt"
 	"math"
 	"strconv"
 
-	"github.com/google/uuid"
-
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
-	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/clients/interfaces"
-	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
-	commonDTO "github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/common"
-	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/requests"
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
-	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
-	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
 	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
 	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
 )
@@ -40,8 +31,12 @@ const (
 )
 
 func TransformReadResult(cv *dsModels.CommandValue, pv models.PropertyValue, lc logger.LoggingClient) error {
-	if cv.Type == contracts.ValueTypeString || cv.Type == contracts.ValueTypeBool || cv.Type == contracts.ValueTypeBinary {
-		return nil // do nothing for String, Bool and Binary
+	if isNumericArray(cv.Type) {
+		return transformReadArray(cv, pv, lc)
+	}
+	if cv.Type == contracts.ValueTypeString || cv.Type == contracts.ValueTypeBool || cv.Type == contracts.ValueTypeBinary ||
+		cv.Type == contracts.ValueTypeBoolArray {
+		return nil // do nothing for String, Bool, Binary and BoolArray
 	}
 	res, err := isNaN(cv)
 	if err != nil {
@@ -54,49 +49,94 @@ func TransformReadResult(cv *dsModels.CommandValue, pv models.PropertyValue, lc
 	if err != nil {
 		return err
 	}
+	newValue, err := transformReadValue(value, cv.DeviceResourceName, pv, lc)
+	if err != nil {
+		return err
+	}
+
+	if value != newValue {
+		err = replaceNewCommandValue(cv, newValue, lc)
+	}
+	return err
+}
+
+// transformReadArray applies the read transforms to each element of a numeric array
+func transformReadArray(cv *dsModels.CommandValue, pv models.PropertyValue, lc logger.LoggingClient) error {
+	values, err := arrayValueForTransform(cv)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for i, value := range values {
+		if isNaNValue(value) {
+			return fmt.Errorf("NaN error for device resource '%s' element %d, error: %w", cv.DeviceResourceName, i, NaNError{})
+		}
+		newValue, err := transformReadValue(value, cv.DeviceResourceName, pv, lc)
+		if err != nil {
+			return err
+		}
+		if value != newValue {
+			values[i] = newValue
+			changed = true
+		}
+	}
+
+	if changed {
+		err = replaceNewArrayCommandValue(cv, values)
+	}
+	return err
+}
+
+// transformReadValue applies mask, shift, base, scale and offset to a numeric value
+func transformReadValue(value interface{}, resourceName string, pv models.PropertyValue, lc logger.LoggingClient) (interface{}, error) {
+	var err error
 	newValue := value
 
-	if pv.Mask != "" && pv.Mask != defaultMask &&
-		(cv.Type == contracts.ValueTypeUint8 || cv.Type == contracts.ValueTypeUint16 || cv.Type == contracts.ValueTypeUint32 || cv.Type == contracts.ValueTypeUint64) {
+	if pv.Mask != "" && pv.Mask != defaultMask && isUnsignedValue(value) {
 		newValue, err = transformReadMask(newValue, pv.Mask, lc)
 		if err != nil {
-			return err
+			return value, err
 		}
 	}
 
-	if pv.Shift != "" && pv.Shift != defaultShift &&
-		(cv.Type == contracts.ValueTypeUint8 || cv.Type == contracts.ValueTypeUint16 || cv.Type == contracts.ValueTypeUint32 || cv.Type == contracts.ValueTypeUint64) {
+	if pv.Shift != "" && pv.Shift != defaultShift && isUnsignedValue(value) {
 		newValue, err = transformReadShift(newValue, pv.Shift, lc)
 		if err != nil {
-			return fmt.Errorf("transform failed for device resource '%v', error: %w ", cv.DeviceResourceName, err)
+			return value, fmt.Errorf("transform failed for device resource '%v', error: %w ", resourceName, err)
 		}
 	}
 
 	if pv.Base != "" && pv.Base != defaultBase {
 		newValue, err = transformReadBase(newValue, pv.Base, lc)
 		if err != nil {
-			return fmt.Errorf("transform failed for device resource '%v', error: %w ", cv.DeviceResourceName, err)
+			return value, fmt.Errorf("transform failed for device resource '%v', error: %w ", resourceName, err)
 		}
 	}
 
 	if pv.Scale != "" && pv.Scale != defaultScale {
 		newValue, err = transformReadScale(newValue, pv.Scale, lc)
 		if err != nil {
-			return fmt.Errorf("transform failed for device resource '%v', error: %w ", cv.DeviceResourceName, err)
+			return value, fmt.Errorf("transform failed for device resource '%v', error: %w ", resourceName, err)
 		}
 	}
 
 	if pv.Offset != "" && pv.Offset != defaultOffset {
 		newValue, err = transformReadOffset(newValue, pv.Offset, lc)
 		if err != nil {
-			return fmt.Errorf("transform failed for device resource '%v', error: %w ", cv.DeviceResourceName, err)
+			return value, fmt.Errorf("transform failed for device resource '%v', error: %w ", resourceName, err)
 		}
 	}
 
-	if value != newValue {
-		err = replaceNewCommandValue(cv, newValue, lc)
+	return newValue, nil
+}
+
+func isUnsignedValue(value interface{}) bool {
+	switch value.(type) {
+	case uint8, uint16, uint32, uint64:
+		return true
 	}
-	return err
+	return false
 }
 
 func transformReadBase(value interface{}, base string, lc logger.LoggingClient) (interface{}, error) {
@@ -605,38 +645,3 @@ func replaceNewCommandValue(cv *dsModels.CommandValue, newValue interface{}, lc
 	}
 	return err
 }
-
-func CheckAssertion(
-	cv *dsModels.CommandValue,
-	assertion string,
-	device *models.Device,
-	lc logger.LoggingClient,
-	dc interfaces.DeviceClient) error {
-	if assertion != "" && cv.ValueToString() != assertion {
-		device.OperatingState = models.Down
-		cache.Devices().Update(*device)
-		ctx := context.WithValue(context.Background(), common.CorrelationHeader, uuid.New().String())
-		os := string(device.OperatingState)
-		pd := dtos.UpdateDevice{
-			Id:             &device.Id,
-			Name:           &device.Name,
-			OperatingState: &os,
-		}
-		go dc.Update(ctx, []requests.UpdateDeviceRequest{{
-			BaseRequest: commonDTO.NewBaseRequest(),
-			Device:      pd}})
-		msg := fmt.Sprintf("assertion (%s) failed with value: %s", assertion, cv.ValueToString())
-		lc.Error(msg)
-		return fmt.Errorf(msg)
-	}
-	return nil
-}
-
-func MapCommandValue(value *dsModels.CommandValue, mappings map[string]string) (*dsModels.CommandValue, bool) {
-	newValue, ok := mappings[value.ValueToString()]
-	var result *dsModels.CommandValue
-	if ok {
-		result = dsModels.NewStringValue(value.DeviceResourceName, value.Origin, newValue)
-	}
-	return result, ok
-}
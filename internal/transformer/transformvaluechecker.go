@@ -9,8 +9,11 @@ package transformer
 import (
 	"fmt"
 	"math"
+	"strconv"
 
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
 	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
 )
 
 func checkTransformedValueInRange(origin interface{}, transformed float64, lc logger.LoggingClient) bool {
@@ -64,3 +67,39 @@ func checkTransformedValueInRange(origin interface{}, transformed float64, lc lo
 
 	return inRange
 }
+
+// CheckArrayRange checks each element of a numeric array CommandValue against the Minimum and
+// Maximum of the PropertyValue, the out of range elements are reported with an OverflowError.
+// The other values aren't checked.
+func CheckArrayRange(cv *dsModels.CommandValue, pv models.PropertyValue) error {
+	if !isNumericArray(cv.Type) || (pv.Minimum == "" && pv.Maximum == "") {
+		return nil
+	}
+	min, max := math.Inf(-1), math.Inf(1)
+	var err error
+	if pv.Minimum != "" {
+		if min, err = strconv.ParseFloat(pv.Minimum, 64); err != nil {
+			return fmt.Errorf("the minimum %s of PropertyValue cannot be parsed to float64: %v", pv.Minimum, err)
+		}
+	}
+	if pv.Maximum != "" {
+		if max, err = strconv.ParseFloat(pv.Maximum, 64); err != nil {
+			return fmt.Errorf("the maximum %s of PropertyValue cannot be parsed to float64: %v", pv.Maximum, err)
+		}
+	}
+
+	values, err := arrayValueForTransform(cv)
+	if err != nil {
+		return err
+	}
+	for i, value := range values {
+		v, err := strconv.ParseFloat(fmt.Sprintf("%v", value), 64)
+		if err != nil {
+			return err
+		}
+		if v < min || v > max {
+			return fmt.Errorf("element %d of device resource '%s' is not within the range [%s, %s]: %w", i, cv.DeviceResourceName, pv.Minimum, pv.Maximum, NewOverflowError(value, v))
+		}
+	}
+	return nil
+}
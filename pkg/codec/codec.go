@@ -0,0 +1,463 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package codec decodes and encodes typed values from and to raw byte slices, as read
+// from field buses like Modbus, BACnet or CAN. The layout of a value is described by the
+// attributes of its DeviceResource, so that a ProtocolDriver can return a Binary
+// CommandValue and let the SDK decode it according to the Properties.Type of the resource.
+package codec
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+)
+
+// Attributes of a DeviceResource describing the layout of its value in a byte slice, reserved
+// by the SDK
+const (
+	// AttributeByteOrder is the byte order of the value, "big" (default) or "little"
+	AttributeByteOrder = common.SDKReservedPrefix + "byteOrder"
+	// AttributeWordSwap reverses the order of the 16-bit words of a 32/64-bit value when "true"
+	AttributeWordSwap = common.SDKReservedPrefix + "wordSwap"
+	// AttributeEncoding is the encoding of integer values, "binary" (default), "bcd" or "signedMagnitude"
+	AttributeEncoding = common.SDKReservedPrefix + "encoding"
+	// AttributeByteOffset is the position of the value in the byte slice, it describes the
+	// fields of a packed struct together with the ds-derivedFrom attribute
+	AttributeByteOffset = common.SDKReservedPrefix + "byteOffset"
+	// AttributeByteLength is the number of bytes of the value, default is the size of the value type,
+	// it is required by String values and optional for BCD values
+	AttributeByteLength = common.SDKReservedPrefix + "byteLength"
+)
+
+// Values of the AttributeByteOrder attribute
+const (
+	BigEndian    = "big"
+	LittleEndian = "little"
+)
+
+// Values of the AttributeEncoding attribute
+const (
+	EncodingBinary          = "binary"
+	EncodingBCD             = "bcd"
+	EncodingSignedMagnitude = "signedMagnitude"
+)
+
+// Layout describes how a typed value is laid out in a byte slice.
+type Layout struct {
+	// ByteOrder is the byte order of the value, BigEndian if empty
+	ByteOrder string
+	// WordSwap reverses the order of the 16-bit words of a multi-word value
+	WordSwap bool
+	// Encoding is the encoding of integer values, EncodingBinary if empty
+	Encoding string
+	// Offset is the position of the first byte of the value
+	Offset int
+	// Length is the number of bytes of the value, the size of the value type if zero
+	Length int
+}
+
+// Field is a named value of a packed struct.
+type Field struct {
+	Name   string
+	Type   string
+	Layout Layout
+}
+
+// LayoutFromAttributes parses the layout described by the attributes of a DeviceResource.
+func LayoutFromAttributes(attributes map[string]string) (Layout, error) {
+	var l Layout
+	var err error
+
+	l.ByteOrder = attributes[AttributeByteOrder]
+	if l.ByteOrder != "" && l.ByteOrder != BigEndian && l.ByteOrder != LittleEndian {
+		return l, fmt.Errorf("invalid %s '%s'", AttributeByteOrder, l.ByteOrder)
+	}
+	if v, ok := attributes[AttributeWordSwap]; ok {
+		if l.WordSwap, err = strconv.ParseBool(v); err != nil {
+			return l, fmt.Errorf("invalid %s '%s'", AttributeWordSwap, v)
+		}
+	}
+	l.Encoding = attributes[AttributeEncoding]
+	switch l.Encoding {
+	case "", EncodingBinary, EncodingBCD, EncodingSignedMagnitude:
+	default:
+		return l, fmt.Errorf("invalid %s '%s'", AttributeEncoding, l.Encoding)
+	}
+	if v, ok := attributes[AttributeByteOffset]; ok {
+		if l.Offset, err = strconv.Atoi(v); err != nil || l.Offset < 0 {
+			return l, fmt.Errorf("invalid %s '%s'", AttributeByteOffset, v)
+		}
+	}
+	if v, ok := attributes[AttributeByteLength]; ok {
+		if l.Length, err = strconv.Atoi(v); err != nil || l.Length <= 0 {
+			return l, fmt.Errorf("invalid %s '%s'", AttributeByteLength, v)
+		}
+	}
+	return l, nil
+}
+
+// Size returns the number of bytes of a value of the given type in the layout.
+func (l Layout) Size(valueType string) (int, error) {
+	if l.Length > 0 {
+		return l.Length, nil
+	}
+	switch valueType {
+	case contracts.ValueTypeBool, contracts.ValueTypeUint8, contracts.ValueTypeInt8:
+		return 1, nil
+	case contracts.ValueTypeUint16, contracts.ValueTypeInt16:
+		return 2, nil
+	case contracts.ValueTypeUint32, contracts.ValueTypeInt32, contracts.ValueTypeFloat32:
+		return 4, nil
+	case contracts.ValueTypeUint64, contracts.ValueTypeInt64, contracts.ValueTypeFloat64:
+		return 8, nil
+	}
+	return 0, fmt.Errorf("the byte length of value type %s must be specified", valueType)
+}
+
+// Decode decodes a value of the given type from the byte slice, the returned value has the
+// Go type of the value type, e.g. uint16 for Uint16 or string for String.
+func Decode(data []byte, valueType string, l Layout) (interface{}, error) {
+	size, err := l.Size(valueType)
+	if err != nil {
+		return nil, err
+	}
+	if l.Offset+size > len(data) {
+		return nil, fmt.Errorf("%d bytes at offset %d out of the %d bytes payload", size, l.Offset, len(data))
+	}
+	b := make([]byte, size)
+	copy(b, data[l.Offset:l.Offset+size])
+
+	if valueType == contracts.ValueTypeString {
+		return strings.TrimRight(string(b), "\x00"), nil
+	}
+	if l.WordSwap {
+		if err = swapWords(b); err != nil {
+			return nil, err
+		}
+	}
+
+	switch valueType {
+	case contracts.ValueTypeFloat32, contracts.ValueTypeFloat64:
+		if l.Encoding != "" && l.Encoding != EncodingBinary {
+			return nil, fmt.Errorf("encoding %s is not supported by value type %s", l.Encoding, valueType)
+		}
+		raw, err := l.uint(b)
+		if err != nil {
+			return nil, err
+		}
+		if valueType == contracts.ValueTypeFloat32 {
+			return math.Float32frombits(uint32(raw)), nil
+		}
+		return math.Float64frombits(raw), nil
+	case contracts.ValueTypeBool:
+		for _, v := range b {
+			if v != 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	var v int64
+	var raw uint64
+	switch l.Encoding {
+	case EncodingBCD:
+		raw, err = l.bcd(b)
+		v = int64(raw)
+	case EncodingSignedMagnitude:
+		raw, err = l.uint(b)
+		sign := uint64(1) << (uint(len(b))*8 - 1)
+		v = int64(raw &^ sign)
+		if raw&sign != 0 {
+			v = -v
+		}
+		raw = uint64(v)
+	default:
+		raw, err = l.uint(b)
+		// sign-extend the two's complement value of a signed type
+		if len(b) < 8 {
+			shift := 64 - uint(len(b))*8
+			v = int64(raw<<shift) >> shift
+		} else {
+			v = int64(raw)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch valueType {
+	case contracts.ValueTypeUint8:
+		return uint8(raw), nil
+	case contracts.ValueTypeUint16:
+		return uint16(raw), nil
+	case contracts.ValueTypeUint32:
+		return uint32(raw), nil
+	case contracts.ValueTypeUint64:
+		return raw, nil
+	case contracts.ValueTypeInt8:
+		return int8(v), nil
+	case contracts.ValueTypeInt16:
+		return int16(v), nil
+	case contracts.ValueTypeInt32:
+		return int32(v), nil
+	case contracts.ValueTypeInt64:
+		return v, nil
+	}
+	return nil, fmt.Errorf("value type %s is not supported by the codec", valueType)
+}
+
+// Encode encodes a value of the given type into a new byte slice of the size of the value,
+// the Offset of the layout is ignored.
+func Encode(value interface{}, valueType string, l Layout) ([]byte, error) {
+	size, err := l.Size(valueType)
+	if err != nil {
+		return nil, err
+	}
+	l.Offset = 0
+	data := make([]byte, size)
+	if err = EncodeInto(data, value, valueType, l); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// EncodeInto encodes a value of the given type into the byte slice at the Offset of the
+// layout, the other bytes are left untouched, e.g. to update a field of a packed struct.
+func EncodeInto(data []byte, value interface{}, valueType string, l Layout) error {
+	size, err := l.Size(valueType)
+	if err != nil {
+		return err
+	}
+	if l.Offset+size > len(data) {
+		return fmt.Errorf("%d bytes at offset %d out of the %d bytes payload", size, l.Offset, len(data))
+	}
+	b := make([]byte, size)
+
+	if valueType == contracts.ValueTypeString {
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("value %v is not a string", value)
+		}
+		if len(s) > size {
+			return fmt.Errorf("string '%s' exceeds %d bytes", s, size)
+		}
+		copy(b, s)
+		copy(data[l.Offset:], b)
+		return nil
+	}
+
+	var raw uint64
+	switch v := value.(type) {
+	case bool:
+		if v {
+			raw = 1
+		}
+	case float32:
+		if l.Encoding != "" && l.Encoding != EncodingBinary {
+			return fmt.Errorf("encoding %s is not supported by value type %s", l.Encoding, valueType)
+		}
+		raw = uint64(math.Float32bits(v))
+	case float64:
+		if l.Encoding != "" && l.Encoding != EncodingBinary {
+			return fmt.Errorf("encoding %s is not supported by value type %s", l.Encoding, valueType)
+		}
+		raw = math.Float64bits(v)
+	default:
+		i, u, signed, err := integer(value)
+		if err != nil {
+			return err
+		}
+		if raw, err = l.encodeInteger(i, u, signed, size); err != nil {
+			return err
+		}
+	}
+
+	if err = l.putUint(b, raw); err != nil {
+		return err
+	}
+	if l.WordSwap {
+		if err = swapWords(b); err != nil {
+			return err
+		}
+	}
+	copy(data[l.Offset:], b)
+	return nil
+}
+
+// DecodeStruct decodes the fields of a packed struct from the byte slice.
+func DecodeStruct(data []byte, fields []Field) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		v, err := Decode(data, f.Type, f.Layout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode field %s: %v", f.Name, err)
+		}
+		values[f.Name] = v
+	}
+	return values, nil
+}
+
+// EncodeStruct encodes the given field values into the byte slice of a packed struct,
+// the fields without value are left untouched.
+func EncodeStruct(data []byte, fields []Field, values map[string]interface{}) error {
+	for _, f := range fields {
+		v, ok := values[f.Name]
+		if !ok {
+			continue
+		}
+		if err := EncodeInto(data, v, f.Type, f.Layout); err != nil {
+			return fmt.Errorf("failed to encode field %s: %v", f.Name, err)
+		}
+	}
+	return nil
+}
+
+func (l Layout) encodeInteger(i int64, u uint64, signed bool, size int) (uint64, error) {
+	bits := uint(size) * 8
+	switch l.Encoding {
+	case EncodingBCD:
+		if signed && i < 0 {
+			return 0, fmt.Errorf("negative value %d can't be BCD encoded", i)
+		}
+		if signed {
+			u = uint64(i)
+		}
+		var raw uint64
+		for n := uint(0); n < bits; n += 4 {
+			raw |= (u % 10) << n
+			u /= 10
+		}
+		if u != 0 {
+			return 0, fmt.Errorf("value exceeds %d BCD digits", bits/4)
+		}
+		return raw, nil
+	case EncodingSignedMagnitude:
+		sign := uint64(1) << (bits - 1)
+		magnitude := u
+		var raw uint64
+		if signed {
+			if i < 0 {
+				raw = sign
+				magnitude = uint64(-i)
+			} else {
+				magnitude = uint64(i)
+			}
+		}
+		if magnitude >= sign {
+			return 0, fmt.Errorf("value exceeds %d bits signed magnitude", bits)
+		}
+		return raw | magnitude, nil
+	default:
+		if signed {
+			if bits < 64 && (i < -(int64(1)<<(bits-1)) || i >= int64(1)<<(bits-1)) {
+				return 0, fmt.Errorf("value %d exceeds %d bits", i, bits)
+			}
+			return uint64(i), nil
+		}
+		if bits < 64 && u >= uint64(1)<<bits {
+			return 0, fmt.Errorf("value %d exceeds %d bits", u, bits)
+		}
+		return u, nil
+	}
+}
+
+// uint reads the bytes as an unsigned integer in the byte order of the layout
+func (l Layout) uint(b []byte) (uint64, error) {
+	if len(b) > 8 {
+		return 0, fmt.Errorf("%d bytes exceed 64 bits", len(b))
+	}
+	var v uint64
+	for i := range b {
+		if l.ByteOrder == LittleEndian {
+			v |= uint64(b[i]) << (uint(i) * 8)
+		} else {
+			v = v<<8 | uint64(b[i])
+		}
+	}
+	return v, nil
+}
+
+// putUint writes the unsigned integer into the bytes in the byte order of the layout
+func (l Layout) putUint(b []byte, v uint64) error {
+	if len(b) > 8 {
+		return fmt.Errorf("%d bytes exceed 64 bits", len(b))
+	}
+	for i := range b {
+		if l.ByteOrder == LittleEndian {
+			b[i] = byte(v >> (uint(i) * 8))
+		} else {
+			b[len(b)-1-i] = byte(v >> (uint(i) * 8))
+		}
+	}
+	return nil
+}
+
+// bcd reads the bytes as packed BCD digits, two digits per byte
+func (l Layout) bcd(b []byte) (uint64, error) {
+	if len(b) > 9 {
+		return 0, fmt.Errorf("%d BCD bytes exceed 64 bits", len(b))
+	}
+	ordered := b
+	if l.ByteOrder == LittleEndian {
+		ordered = make([]byte, len(b))
+		for i := range b {
+			ordered[len(b)-1-i] = b[i]
+		}
+	}
+	var v uint64
+	for _, octet := range ordered {
+		high, low := octet>>4, octet&0x0f
+		if high > 9 || low > 9 {
+			return 0, fmt.Errorf("invalid BCD byte 0x%02x", octet)
+		}
+		v = v*100 + uint64(high)*10 + uint64(low)
+	}
+	return v, nil
+}
+
+// swapWords reverses the order of the 16-bit words of the bytes in place
+func swapWords(b []byte) error {
+	if len(b)%2 != 0 {
+		return fmt.Errorf("word swap requires an even number of bytes, got %d", len(b))
+	}
+	for i, j := 0, len(b)-2; i < j; i, j = i+2, j-2 {
+		b[i], b[i+1], b[j], b[j+1] = b[j], b[j+1], b[i], b[i+1]
+	}
+	return nil
+}
+
+// integer returns the value of a Go integer as int64 for signed types and uint64 for unsigned types
+func integer(value interface{}) (int64, uint64, bool, error) {
+	switch v := value.(type) {
+	case int8:
+		return int64(v), 0, true, nil
+	case int16:
+		return int64(v), 0, true, nil
+	case int32:
+		return int64(v), 0, true, nil
+	case int64:
+		return v, 0, true, nil
+	case int:
+		return int64(v), 0, true, nil
+	case uint8:
+		return 0, uint64(v), false, nil
+	case uint16:
+		return 0, uint64(v), false, nil
+	case uint32:
+		return 0, uint64(v), false, nil
+	case uint64:
+		return 0, v, false, nil
+	case uint:
+		return 0, uint64(v), false, nil
+	}
+	return 0, 0, false, fmt.Errorf("value %v of type %T is not supported by the codec", value, value)
+}
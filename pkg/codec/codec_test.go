@@ -0,0 +1,133 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package codec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []byte
+		valueType string
+		layout    Layout
+		expected  interface{}
+	}{
+		{"big endian uint32", []byte{0x11, 0x22, 0x33, 0x44}, contracts.ValueTypeUint32, Layout{}, uint32(0x11223344)},
+		{"little endian uint32", []byte{0x44, 0x33, 0x22, 0x11}, contracts.ValueTypeUint32, Layout{ByteOrder: LittleEndian}, uint32(0x11223344)},
+		{"word swapped uint32", []byte{0x33, 0x44, 0x11, 0x22}, contracts.ValueTypeUint32, Layout{WordSwap: true}, uint32(0x11223344)},
+		{"byte swapped uint32", []byte{0x22, 0x11, 0x44, 0x33}, contracts.ValueTypeUint32, Layout{ByteOrder: LittleEndian, WordSwap: true}, uint32(0x11223344)},
+		{"word swapped uint64", []byte{0x77, 0x88, 0x55, 0x66, 0x33, 0x44, 0x11, 0x22}, contracts.ValueTypeUint64, Layout{WordSwap: true}, uint64(0x1122334455667788)},
+		{"negative int16", []byte{0xff, 0xfe}, contracts.ValueTypeInt16, Layout{}, int16(-2)},
+		{"word swapped float32", []byte{0x00, 0x00, 0x3f, 0xc0}, contracts.ValueTypeFloat32, Layout{WordSwap: true}, float32(1.5)},
+		{"little endian float64", []byte{0, 0, 0, 0, 0, 0, 0xf8, 0x3f}, contracts.ValueTypeFloat64, Layout{ByteOrder: LittleEndian}, float64(1.5)},
+		{"bcd uint16", []byte{0x12, 0x34}, contracts.ValueTypeUint16, Layout{Encoding: EncodingBCD}, uint16(1234)},
+		{"little endian bcd uint32", []byte{0x78, 0x56, 0x34, 0x12}, contracts.ValueTypeUint32, Layout{Encoding: EncodingBCD, ByteOrder: LittleEndian}, uint32(12345678)},
+		{"signed magnitude int16", []byte{0x80, 0x05}, contracts.ValueTypeInt16, Layout{Encoding: EncodingSignedMagnitude}, int16(-5)},
+		{"packed field at offset", []byte{0x00, 0x01, 0x02, 0x03}, contracts.ValueTypeUint16, Layout{Offset: 2}, uint16(0x0203)},
+		{"string", []byte{'a', 'b', 'c', 0, 0}, contracts.ValueTypeString, Layout{Length: 5}, "abc"},
+		{"bool", []byte{0x00, 0x01}, contracts.ValueTypeBool, Layout{Offset: 1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := Decode(tt.data, tt.valueType, tt.layout)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, v)
+
+			// encoding the decoded value gives back the original bytes
+			l := tt.layout
+			data := make([]byte, len(tt.data))
+			copy(data, tt.data)
+			require.NoError(t, EncodeInto(data, v, tt.valueType, l))
+			assert.Equal(t, tt.data, data)
+		})
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	_, err := Decode([]byte{0x01}, contracts.ValueTypeUint16, Layout{})
+	assert.Error(t, err, "payload too short")
+	_, err = Decode([]byte{0x1a}, contracts.ValueTypeUint8, Layout{Encoding: EncodingBCD})
+	assert.Error(t, err, "invalid BCD digit")
+	_, err = Decode([]byte{0x01, 0x02, 0x03}, contracts.ValueTypeString, Layout{})
+	assert.Error(t, err, "missing string length")
+	_, err = Decode([]byte{0, 0, 0, 0}, contracts.ValueTypeFloat32, Layout{Encoding: EncodingBCD})
+	assert.Error(t, err, "BCD float")
+}
+
+func TestEncodeError(t *testing.T) {
+	_, err := Encode(int64(1000), contracts.ValueTypeInt8, Layout{})
+	assert.Error(t, err, "value out of range")
+	_, err = Encode(uint16(1000), contracts.ValueTypeUint8, Layout{Encoding: EncodingBCD})
+	assert.Error(t, err, "too many BCD digits")
+	_, err = Encode(int16(-1), contracts.ValueTypeInt16, Layout{Encoding: EncodingBCD})
+	assert.Error(t, err, "negative BCD")
+	_, err = Encode("toolong", contracts.ValueTypeString, Layout{Length: 3})
+	assert.Error(t, err, "string too long")
+}
+
+func TestDecodeStruct(t *testing.T) {
+	fields := []Field{
+		{Name: "status", Type: contracts.ValueTypeUint8, Layout: Layout{Offset: 0}},
+		{Name: "temperature", Type: contracts.ValueTypeInt16, Layout: Layout{Offset: 1, ByteOrder: LittleEndian}},
+		{Name: "counter", Type: contracts.ValueTypeUint32, Layout: Layout{Offset: 3, Encoding: EncodingBCD}},
+	}
+	data := []byte{0x01, 0x9c, 0xff, 0x00, 0x00, 0x12, 0x34}
+
+	values, err := DecodeStruct(data, fields)
+	require.NoError(t, err)
+	assert.Equal(t, uint8(1), values["status"])
+	assert.Equal(t, int16(-100), values["temperature"])
+	assert.Equal(t, uint32(1234), values["counter"])
+
+	require.NoError(t, EncodeStruct(data, fields, map[string]interface{}{"temperature": int16(25)}))
+	assert.Equal(t, []byte{0x01, 0x19, 0x00, 0x00, 0x00, 0x12, 0x34}, data)
+}
+
+func TestLayoutFromAttributes(t *testing.T) {
+	l, err := LayoutFromAttributes(map[string]string{
+		AttributeByteOrder:  LittleEndian,
+		AttributeWordSwap:   "true",
+		AttributeEncoding:   EncodingBCD,
+		AttributeByteOffset: "4",
+		AttributeByteLength: "2",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Layout{ByteOrder: LittleEndian, WordSwap: true, Encoding: EncodingBCD, Offset: 4, Length: 2}, l)
+
+	_, err = LayoutFromAttributes(map[string]string{AttributeByteOrder: "middle"})
+	assert.Error(t, err)
+	_, err = LayoutFromAttributes(map[string]string{AttributeEncoding: "gray"})
+	assert.Error(t, err)
+	_, err = LayoutFromAttributes(map[string]string{AttributeByteOffset: "-1"})
+	assert.Error(t, err)
+}
+
+func TestDecodeCommandValue(t *testing.T) {
+	cv, err := models.NewBinaryValue("raw", 1, []byte{0x00, 0x00, 0x3f, 0xc0})
+	require.NoError(t, err)
+
+	decoded, err := DecodeCommandValue(cv, "temperature", contracts.ValueTypeFloat32, map[string]string{AttributeWordSwap: "true"})
+	require.NoError(t, err)
+	assert.Equal(t, "temperature", decoded.DeviceResourceName)
+	assert.Equal(t, int64(1), decoded.Origin)
+	v, err := decoded.Float32Value()
+	require.NoError(t, err)
+	assert.Equal(t, float32(1.5), v)
+
+	encoded, err := EncodeCommandValue(decoded, map[string]string{AttributeWordSwap: "true"})
+	require.NoError(t, err)
+	assert.Equal(t, contracts.ValueTypeBinary, encoded.Type)
+	assert.Equal(t, cv.BinValue, encoded.BinValue)
+}
@@ -0,0 +1,81 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package codec
+
+import (
+	"fmt"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+// DecodeCommandValue decodes the payload of a Binary CommandValue into a CommandValue of the
+// given DeviceResource name and value type, the layout of the value in the payload is described
+// by the attributes of the DeviceResource.
+func DecodeCommandValue(cv *models.CommandValue, resourceName string, valueType string, attributes map[string]string) (*models.CommandValue, error) {
+	if cv.Type != contracts.ValueTypeBinary {
+		return nil, fmt.Errorf("CommandValue (%s) is not a Binary value", cv.String())
+	}
+	l, err := LayoutFromAttributes(attributes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid layout of deviceResource %s: %v", resourceName, err)
+	}
+	v, err := Decode(cv.BinValue, valueType, l)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode deviceResource %s: %v", resourceName, err)
+	}
+	return models.NewCommandValue(resourceName, cv.Origin, v, valueType)
+}
+
+// EncodeCommandValue encodes a scalar CommandValue into a Binary CommandValue, the layout of the
+// value is described by the attributes of its DeviceResource.
+func EncodeCommandValue(cv *models.CommandValue, attributes map[string]string) (*models.CommandValue, error) {
+	l, err := LayoutFromAttributes(attributes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid layout of deviceResource %s: %v", cv.DeviceResourceName, err)
+	}
+	v, err := Value(cv)
+	if err != nil {
+		return nil, err
+	}
+	data, err := Encode(v, cv.Type, l)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode deviceResource %s: %v", cv.DeviceResourceName, err)
+	}
+	return models.NewBinaryValue(cv.DeviceResourceName, cv.Origin, data)
+}
+
+// Value returns the Go value of a scalar CommandValue.
+func Value(cv *models.CommandValue) (interface{}, error) {
+	switch cv.Type {
+	case contracts.ValueTypeBool:
+		return cv.BoolValue()
+	case contracts.ValueTypeString:
+		return cv.StringValue()
+	case contracts.ValueTypeUint8:
+		return cv.Uint8Value()
+	case contracts.ValueTypeUint16:
+		return cv.Uint16Value()
+	case contracts.ValueTypeUint32:
+		return cv.Uint32Value()
+	case contracts.ValueTypeUint64:
+		return cv.Uint64Value()
+	case contracts.ValueTypeInt8:
+		return cv.Int8Value()
+	case contracts.ValueTypeInt16:
+		return cv.Int16Value()
+	case contracts.ValueTypeInt32:
+		return cv.Int32Value()
+	case contracts.ValueTypeInt64:
+		return cv.Int64Value()
+	case contracts.ValueTypeFloat32:
+		return cv.Float32Value()
+	case contracts.ValueTypeFloat64:
+		return cv.Float64Value()
+	}
+	return nil, fmt.Errorf("CommandValue (%s) is not a scalar value", cv.String())
+}
@@ -0,0 +1,51 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package models
+
+import (
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+)
+
+// ChangeType is the type of a change notified to the subscribers of the DeviceService.
+type ChangeType string
+
+const (
+	ChangeAdded   ChangeType = "added"
+	ChangeUpdated ChangeType = "updated"
+	ChangeRemoved ChangeType = "removed"
+)
+
+// DeviceChange notifies a change of a Device. Before is nil when the Device is added and
+// After is nil when the Device is removed.
+type DeviceChange struct {
+	Type   ChangeType
+	Before *models.Device
+	After  *models.Device
+}
+
+// ProfileChange notifies a change of a DeviceProfile. Before is nil when the DeviceProfile
+// is added and After is nil when the DeviceProfile is removed.
+type ProfileChange struct {
+	Type   ChangeType
+	Before *models.DeviceProfile
+	After  *models.DeviceProfile
+}
+
+// ProvisionWatcherChange notifies a change of a ProvisionWatcher. Before is nil when the
+// ProvisionWatcher is added and After is nil when the ProvisionWatcher is removed.
+type ProvisionWatcherChange struct {
+	Type   ChangeType
+	Before *models.ProvisionWatcher
+	After  *models.ProvisionWatcher
+}
+
+// DeviceServiceChange notifies a change of the DeviceService itself.
+type DeviceServiceChange struct {
+	Type   ChangeType
+	Before *models.DeviceService
+	After  *models.DeviceService
+}
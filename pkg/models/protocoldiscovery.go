@@ -8,6 +8,8 @@
 package models
 
 import (
+	"context"
+
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
 )
 
@@ -21,6 +23,33 @@ type ProtocolDiscovery interface {
 	Discover()
 }
 
+// ContextDiscovery is implemented instead of ProtocolDiscovery by device services whose
+// device discovery can be cancelled and reports its progress.
+type ContextDiscovery interface {
+	// Discover runs a protocol specific device discovery until it's finished or the context
+	// is cancelled, and writes the results to the channel which is passed to the implementation
+	// via ProtocolDriver.Initialize(). The progress of the discovery, from 0 to 100 percent,
+	// is reported by calling progress. A returned error fails the discovery job.
+	Discover(ctx context.Context, progress func(percent int)) error
+}
+
+// ScopedDiscovery is optionally implemented along with ProtocolDiscovery or ContextDiscovery
+// by device services which can restrict a discovery to a scope, e.g. a subnet or a bus. It's
+// used by the discoveries scheduled by a ProvisionWatcher.
+type ScopedDiscovery interface {
+	// DiscoverScope runs a device discovery restricted to the scope, like the Discover of
+	// ContextDiscovery.
+	DiscoverScope(ctx context.Context, scope DiscoveryScope, progress func(percent int)) error
+}
+
+// DiscoveryScope is the scope of a discovery scheduled by a ProvisionWatcher.
+type DiscoveryScope struct {
+	// ProvisionWatcher is the name of the watcher which scheduled the discovery
+	ProvisionWatcher string
+	// Hints are the scoping hints of the watcher, e.g. "subnet": "192.168.1.0/24"
+	Hints map[string]string
+}
+
 // DiscoveredDevice defines the required information for a found device.
 type DiscoveredDevice struct {
 	Name        string
@@ -55,3 +55,11 @@ type ProtocolDriver interface {
 	// when a Device associated with this Device Service is removed
 	RemoveDevice(deviceName string, protocols map[string]models.ProtocolProperties) error
 }
+
+// ProfileAwareDriver is an optional interface of the ProtocolDriver, to be notified
+// when a DeviceProfile used by its devices is updated, e.g. to rebuild register maps.
+type ProfileAwareDriver interface {
+	// UpdateProfile is a callback function that is invoked after the profile is
+	// updated in the cache, with the names of the Devices using it.
+	UpdateProfile(profile models.DeviceProfile, deviceNames []string) error
+}
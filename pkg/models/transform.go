@@ -0,0 +1,45 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package models
+
+import (
+	"fmt"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+)
+
+// TransformFunc is a custom transform registered by the ProtocolDriver and referenced by
+// name in the ds-transform attribute of a DeviceResource. It returns the transformed
+// CommandValue, which may be of another value type than the given one. Returning an
+// OverflowError or a NaNError reports the reading as "overflow" or "NaN", like the SDK's
+// own transforms.
+type TransformFunc func(cv *CommandValue, dr models.DeviceResource) (*CommandValue, error)
+
+// OverflowError is used to throw the error of transformed value is out of range
+type OverflowError struct {
+	origin      interface{}
+	transformed float64
+}
+
+func (e OverflowError) Error() string {
+	return fmt.Sprintf("overflow failed, transformed value '%v' is not within the '%T' value type range", e.transformed, e.origin)
+}
+
+func (e OverflowError) String() string {
+	return fmt.Sprintf("overflow failed, transformed value '%v' is not within the '%T' value type range", e.transformed, e.origin)
+}
+
+func NewOverflowError(origin interface{}, transformed float64) OverflowError {
+	return OverflowError{origin: origin, transformed: transformed}
+}
+
+// NaNError is used to throw the NaN error for the floating-point value
+type NaNError struct{}
+
+func (e NaNError) Error() string {
+	return "not a valid float value NaN"
+}
@@ -11,20 +11,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"regexp"
 	"sync"
 	"time"
 
+	"github.com/edgexfoundry/go-mod-bootstrap/v2/di"
 	"github.com/google/uuid"
 
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
-	commonDTO "github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/common"
-	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/requests"
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/autodiscovery"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/computed"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/provision"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/transformer"
-	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
 	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
 )
 
@@ -68,31 +68,80 @@ func (s *DeviceService) sendAsyncValues(acv *dsModels.AsyncValues, working chan
 		return
 	}
 
+	transformed := make([]*dsModels.CommandValue, 0, len(acv.CommandValues))
+	arrived := make([]string, 0, len(acv.CommandValues))
 	for _, cv := range acv.CommandValues {
 		// get the device resource associated with the rsp.RO
-		dr, ok := cache.Profiles().DeviceResource(device.ProfileName, cv.DeviceResourceName)
+		dr, ok := cache.DeviceResourceOf(device, cv.DeviceResourceName)
 		if !ok {
 			s.LoggingClient.Error(fmt.Sprintf("processAsyncResults - Device Resource %s not found in Device %s", cv.DeviceResourceName, acv.DeviceName))
 			continue
 		}
 
-		// device resourse property转换
-		if s.config.Device.DataTransform {
-			err := transformer.TransformReadResult(cv, dr.Properties, s.LoggingClient)
-			if err != nil {
-				s.LoggingClient.Error(fmt.Sprintf("processAsyncResults - CommandValue (%s) transformed failed: %v", cv.String(), err))
-
-				if errors.As(err, &transformer.OverflowError{}) {
-					cv = dsModels.NewStringValue(cv.DeviceResourceName, cv.Origin, transformer.Overflow)
-				} else if errors.As(err, &transformer.NaNError{}) {
-					cv = dsModels.NewStringValue(cv.DeviceResourceName, cv.Origin, transformer.NaN)
-				} else {
-					cv = dsModels.NewStringValue(cv.DeviceResourceName, cv.Origin, fmt.Sprintf("Transformation failed for device resource, with value: %s, property value: %v, and error: %v", cv.String(), dr.Properties, err))
-				}
+		// extract the value of the deviceResource from a JSON document and decode the raw
+		// payload pushed for a typed deviceResource, a failure only drops the reading of that
+		// deviceResource, which must not stay the input of computed resources
+		cv, err := transformer.ExtractJSONPath(cv, dr)
+		if err == nil {
+			cv, err = transformer.DecodeBinaryValue(cv, dr)
+		}
+		if err != nil {
+			s.LoggingClient.Error(fmt.Sprintf("processAsyncResults - dropping the reading of deviceResource %s: %v", dr.Name, err))
+			computed.Drop(device.Name, dr.Name)
+			continue
+		}
+
+		// custom transforms of the driver then device resourse property转换
+		cv, err = transformer.ApplyReadTransforms(cv, dr)
+		if err == nil && s.config.Device.DataTransform {
+			err = transformer.TransformReadResult(cv, dr.Properties, s.LoggingClient)
+		}
+		if err == nil {
+			err = transformer.CheckArrayRange(cv, dr.Properties)
+		}
+		if err != nil {
+			s.LoggingClient.Error(fmt.Sprintf("processAsyncResults - CommandValue (%s) transformed failed: %v", cv.String(), err))
+
+			if errors.As(err, &transformer.OverflowError{}) {
+				cv = dsModels.NewStringValue(cv.DeviceResourceName, cv.Origin, transformer.Overflow)
+			} else if errors.As(err, &transformer.NaNError{}) {
+				cv = dsModels.NewStringValue(cv.DeviceResourceName, cv.Origin, transformer.NaN)
+			} else {
+				cv = dsModels.NewStringValue(cv.DeviceResourceName, cv.Origin, fmt.Sprintf("Transformation failed for device resource, with value: %s, property value: %v, and error: %v", cv.String(), dr.Properties, err))
+			}
+		}
+
+		if err != nil {
+			computed.Drop(device.Name, cv.DeviceResourceName)
+		} else {
+			computed.Remember(device.Name, cv)
+		}
+		transformed = append(transformed, cv)
+		arrived = append(arrived, cv.DeviceResourceName)
+	}
+
+	// re-evaluate the computed resources whose inputs arrived, the other inputs
+	// take their last known values
+	for _, dr := range computed.Dependents(device, arrived) {
+		cv, err := computed.Evaluate(device.Name, dr, time.Now().UnixNano())
+		if err != nil {
+			s.LoggingClient.Error(fmt.Sprintf("processAsyncResults - %v", err))
+
+			if errors.As(err, &transformer.OverflowError{}) {
+				cv = dsModels.NewStringValue(dr.Name, time.Now().UnixNano(), transformer.Overflow)
+			} else if errors.As(err, &transformer.NaNError{}) {
+				cv = dsModels.NewStringValue(dr.Name, time.Now().UnixNano(), transformer.NaN)
+			} else {
+				continue
 			}
 		}
+		transformed = append(transformed, cv)
+	}
+
+	for _, cv := range transformed {
+		dr, _ := cache.DeviceResourceOf(device, cv.DeviceResourceName)
 
-		err := transformer.CheckAssertion(cv, dr.Properties.Assertion, &device, s.LoggingClient, s.tedgeClients.DeviceClient)
+		alarm, err := transformer.CheckAssertion(cv, dr, &device, s.LoggingClient, s.tedgeClients.DeviceClient)
 		if err != nil {
 			s.LoggingClient.Error(fmt.Sprintf("processAsyncResults - Assertion failed for device resource: %s, with value: %s and assertion: %s, %v", cv.DeviceResourceName, cv.String(), dr.Properties.Assertion, err))
 			cv = dsModels.NewStringValue(cv.DeviceResourceName, cv.Origin, fmt.Sprintf("Assertion failed for device resource, with value: %s and assertion: %s", cv.String(), dr.Properties.Assertion))
@@ -102,8 +151,15 @@ func (s *DeviceService) sendAsyncValues(acv *dsModels.AsyncValues, working chan
 		if err != nil {
 			s.LoggingClient.Debug(fmt.Sprintf("processAsyncResults - getting resource operation failed: %s", err.Error()))
 		} else if len(ro.Mappings) > 0 {
-			newCV, ok := transformer.MapCommandValue(cv, ro.Mappings)
-			if ok {
+			newCV, ok, err := transformer.MapCommandValue(cv, ro.Mappings)
+			if err != nil {
+				s.LoggingClient.Error(fmt.Sprintf("processAsyncResults - Mapping failed for Device Resource Operation: %s, with value: %s, %v", ro, cv.String(), err))
+				// the value is dropped, not the alarm raised by its assertion
+				if alarm != nil {
+					readings = append(readings, common.CommandValueToReading(alarm, device.Name, device.ProfileName, "", ""))
+				}
+				continue
+			} else if ok {
 				cv = newCV
 			} else { // TODO
 				s.LoggingClient.Warn(fmt.Sprintf("processAsyncResults - Mapping failed for Device Resource Operation: %s, with value: %s, %v", ro, cv.String(), err))
@@ -114,6 +170,9 @@ func (s *DeviceService) sendAsyncValues(acv *dsModels.AsyncValues, working chan
 		// TODO 直接创建dtos reading
 		reading := common.CommandValueToReading(cv, device.Name, device.ProfileName, dr.Properties.MediaType, "")
 		readings = append(readings, reading)
+		if alarm != nil {
+			readings = append(readings, common.CommandValueToReading(alarm, device.Name, device.ProfileName, "", ""))
+		}
 	}
 
 	// push to Core Data
@@ -131,96 +190,140 @@ func (s *DeviceService) sendAsyncValues(acv *dsModels.AsyncValues, working chan
 
 // processAsyncFilterAndAdd filter and add devices discovered by
 // device service protocol discovery.
-func (s *DeviceService) processAsyncFilterAndAdd(ctx context.Context, wg *sync.WaitGroup) {
+func (s *DeviceService) processAsyncFilterAndAdd(ctx context.Context, wg *sync.WaitGroup, dic *di.Container) {
 	wg.Add(1)
 	defer func() {
 		wg.Done()
 	}()
+	missed := make(map[string]int)
+	// the devices rediscovered by the running discovery jobs, by job id
+	rediscovered := make(map[string]map[string]bool)
+	registering := newInFlight()
+	finished := make(chan dtos.DiscoveryJob)
+	autodiscovery.OnJobFinished(func(job dtos.DiscoveryJob) {
+		select {
+		case finished <- job:
+		case <-ctx.Done():
+		}
+	})
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case job := <-finished:
+			results := jobResults{id: job.Id, release: func() {}, seen: rediscovered[job.Id]}
+			if results.seen == nil {
+				results.seen = make(map[string]bool)
+			}
+			delete(rediscovered, job.Id)
+			// the devices written before the end of the job belong to it
+			select {
+			case devices := <-s.deviceCh:
+				s.filterAndAdd(ctx, wg, devices, results, registering, dic)
+			default:
+			}
+			s.countMissed(job, results.seen, missed, dic)
 		case devices := <-s.deviceCh:
-			newDevReqs := make([]requests.AddDeviceRequest, 0, len(devices))
-			ctx := context.Background()
-			pws := cache.ProvisionWatchers().All()
-			for _, d := range devices {
-				for _, pw := range pws {
-					if whitelistPass(d, pw, s.LoggingClient) && blacklistPass(d, pw, s.LoggingClient) {
-						if _, ok := cache.Devices().ForName(d.Name); ok {
-							s.LoggingClient.Debug(fmt.Sprintf("Candidate discovered device %s already existed", d.Name))
-							break
-						}
-						s.LoggingClient.Info(fmt.Sprintf("Adding discovered device %s to Edgex", d.Name))
-						millis := time.Now().UnixNano() / 1e6
-						device := models.Device{
-							Name:           d.Name,
-							ProfileName:    pw.ProfileName,
-							Protocols:      d.Protocols,
-							Labels:         d.Labels,
-							ServiceName:    pw.ServiceName,
-							AdminState:     pw.AdminState,
-							OperatingState: models.Up,
-							AutoEvents:     nil,
-						}
-						device.Created = millis
-						device.Description = d.Description
-						// models to dtos
-						newDevReqs = append(newDevReqs, requests.AddDeviceRequest{
-							BaseRequest: commonDTO.NewBaseRequest(),
-							Device:      dtos.FromDeviceModelToDTO(device),
-						})
-					}
-				}
-				// add here
-				_, err := s.tedgeClients.DeviceClient.Add(ctx, newDevReqs)
-				if err != nil {
-					s.LoggingClient.Error(fmt.Sprintf("failed to create discovered device %v", err))
-				} else {
-					break
+			// the batch is recorded in the job running when it arrives, which is held running
+			// until the devices are added
+			job, release, ok := autodiscovery.HoldLatestJob()
+			results := jobResults{id: job.Id, release: release, seen: make(map[string]bool)}
+			if ok && job.Status == dtos.DiscoveryJobRunning {
+				if _, ok := rediscovered[job.Id]; !ok {
+					rediscovered[job.Id] = results.seen
 				}
+				results.seen = rediscovered[job.Id]
 			}
-			s.LoggingClient.Debug("Filtered device addition finished")
+			s.filterAndAdd(ctx, wg, devices, results, registering, dic)
 		}
 	}
 }
 
-func whitelistPass(d dsModels.DiscoveredDevice, pw models.ProvisionWatcher, lc logger.LoggingClient) bool {
-	// ignore the device protocol properties name
-	for _, protocol := range d.Protocols {
-		matchedCount := 0
-		for name, regex := range pw.Identifiers {
-			if value, ok := protocol[name]; ok {
-				matched, err := regexp.MatchString(regex, value)
-				if !matched || err != nil {
-					lc.Debug(fmt.Sprintf("Device %s's %s value %s did not match PW identifier: %s", d.Name, name, value, regex))
-					break
-				}
-				matchedCount += 1
-			}
-		}
-		// match succeed on all identifiers
-		if matchedCount == len(pw.Identifiers) {
-			return true
-		}
-	}
-	return false
+// jobResults collects the results of a discovery job, which is held running until release is
+// called.
+type jobResults struct {
+	id      string
+	release func()
+	seen    map[string]bool // the names of the rediscovered devices
 }
 
-func blacklistPass(d dsModels.DiscoveredDevice, pw models.ProvisionWatcher, lc logger.LoggingClient) bool {
-	// a candidate should match none of the blocking identifiers
-	for name, blacklist := range pw.BlockingIdentifiers {
-		// ignore the device protocol properties name
-		for _, protocol := range d.Protocols {
-			if value, ok := protocol[name]; ok {
-				for _, v := range blacklist {
-					if value == v {
-						lc.Debug(fmt.Sprintf("Discovered Device %s's %s should not be %s", d.Name, name, value))
-						return false
-					}
-				}
-			}
+// filterAndAdd adds the discovered devices matching a ProvisionWatcher and records the
+// decisions about them in the discovery job. The devices already being added are rejected.
+func (s *DeviceService) filterAndAdd(ctx context.Context, wg *sync.WaitGroup, devices []dsModels.DiscoveredDevice, results jobResults, registering *inFlight, dic *di.Container) {
+	if !autodiscovery.AcceptingResults(results.id) {
+		s.LoggingClient.Info(fmt.Sprintf("dropping %d devices discovered by a cancelled discovery job", len(devices)))
+		decisions := make([]dtos.DiscoveredDeviceDecision, 0, len(devices))
+		for _, d := range devices {
+			decisions = append(decisions, dtos.DiscoveredDeviceDecision{
+				Name:      d.Name,
+				Protocols: dtos.FromProtocolModelsToDTOs(d.Protocols),
+				Decision:  dtos.DiscoveryDecisionRejected,
+				Reason:    "discovery job cancelled",
+			})
 		}
+		autodiscovery.RecordDecisions(results.id, decisions...)
+		results.release()
+		return
 	}
-	return true
+	devices, decisions := s.dedupeDiscovered(devices)
+	devices = s.reconcileDiscovered(devices, results.id, results.seen, dic)
+	var accepted []models.Device
+	var acceptedAt []int
+	watchers := cache.ProvisionWatchers().Compiled()
+	taken := registering.taken()
+	for _, d := range devices {
+		identity := s.discoveredIdentity(d)
+		if name, ok := registering.adding(identity); ok {
+			decisions = append(decisions, dtos.DiscoveredDeviceDecision{
+				Name:      d.Name,
+				Protocols: dtos.FromProtocolModelsToDTOs(d.Protocols),
+				Decision:  dtos.DiscoveryDecisionRejected,
+				Reason:    fmt.Sprintf("duplicate of device %s being added", name),
+			})
+			continue
+		}
+		// the first matching watcher by name order adds the device
+		watcher, evaluations := provision.EvaluateAll(d, watchers)
+		if watcher == nil {
+			reason := "matched no provision watcher: " + provision.Explain(evaluations)
+			s.LoggingClient.Debug(fmt.Sprintf("Discovered device %s rejected, %s", d.Name, reason))
+			decisions = append(decisions, dtos.DiscoveredDeviceDecision{
+				Name:      d.Name,
+				Protocols: dtos.FromProtocolModelsToDTOs(d.Protocols),
+				Decision:  dtos.DiscoveryDecisionRejected,
+				Reason:    reason,
+			})
+			continue
+		}
+		device, decision := s.provisionedDevice(d, watcher.ProvisionWatcher, taken)
+		if decision.Decision == dtos.DiscoveryDecisionAccepted {
+			s.LoggingClient.Info(fmt.Sprintf("Adding discovered device %s to Edgex", device.Name))
+			registering.add(device.Name, identity)
+			accepted = append(accepted, device)
+			acceptedAt = append(acceptedAt, len(decisions))
+		}
+		decisions = append(decisions, decision)
+	}
+	if len(accepted) == 0 {
+		autodiscovery.RecordDecisions(results.id, decisions...)
+		results.release()
+		s.LoggingClient.Debug("Filtered device addition finished")
+		return
+	}
+	acceptedDecisions := make([]*dtos.DiscoveredDeviceDecision, len(acceptedAt))
+	for i, at := range acceptedAt {
+		acceptedDecisions[i] = &decisions[at]
+	}
+	// the retries of the registration don't hold back the next discovered devices
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.registerDevices(ctx, accepted, acceptedDecisions)
+		for _, device := range accepted {
+			registering.remove(device.Name)
+		}
+		autodiscovery.RecordDecisions(results.id, decisions...)
+		results.release()
+		s.LoggingClient.Debug("Filtered device addition finished")
+	}()
 }
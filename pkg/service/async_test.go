@@ -0,0 +1,193 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/clients/interfaces"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	commonDTO "github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/requests"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/autodiscovery"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/clients"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+// eventRecorder records the events sent to core data
+type eventRecorder struct {
+	interfaces.EventClient
+	events []dtos.Event
+}
+
+func (r *eventRecorder) Add(_ context.Context, req requests.AddEventRequest) (commonDTO.BaseWithIdResponse, errors.EdgeX) {
+	r.events = append(r.events, req.Event)
+	return commonDTO.NewBaseWithIdResponse(req.RequestId, "", 201, req.Event.Id), nil
+}
+
+func TestSendAsyncValues(t *testing.T) {
+	initTestCache(t)
+	profile := models.DeviceProfile{
+		Name: "async-profile",
+		DeviceResources: []models.DeviceResource{
+			{Name: "temperature", Properties: models.PropertyValue{Type: contracts.ValueTypeFloat64, ReadWrite: "R"}, Attributes: map[string]string{common.AttributeJSONPath: "$.t"}},
+			{Name: "offset", Properties: models.PropertyValue{Type: contracts.ValueTypeFloat64, ReadWrite: "R"}},
+			{Name: "adjusted", Properties: models.PropertyValue{Type: contracts.ValueTypeFloat64, ReadWrite: "R"}, Attributes: map[string]string{common.AttributeExpression: "temperature + offset"}},
+			{Name: "mode", Properties: models.PropertyValue{Type: contracts.ValueTypeString, ReadWrite: "R", Assertion: "auto"},
+				Attributes: map[string]string{common.AttributeAssertionAlarm: "modeAlarm", common.AttributeAssertionOperatingState: "false"}},
+		},
+		DeviceCommands: []models.ProfileResource{{Name: "mode", Get: []models.ResourceOperation{
+			{DeviceResource: "mode", Mappings: map[string]string{"auto": "A", "manual": "M", "ds-strict": "true"}},
+		}}},
+	}
+	require.NoError(t, cache.Profiles().Add(profile))
+	device := models.Device{Id: "async-device-id", Name: "async-device", ProfileName: profile.Name, OperatingState: models.Up}
+	require.NoError(t, cache.Devices().Add(device))
+
+	recorder := &eventRecorder{}
+	s := &DeviceService{
+		LoggingClient: logger.NewMockClient(),
+		tedgeClients:  clients.TedgeClients{EventClient: recorder},
+		config:        &common.ConfigurationStruct{},
+	}
+	send := func(values ...*dsModels.CommandValue) map[string]string {
+		s.sendAsyncValues(&dsModels.AsyncValues{DeviceName: device.Name, CommandValues: values}, make(chan bool, 1))
+		require.NotEmpty(t, recorder.events)
+		readings := make(map[string]string)
+		for _, r := range recorder.events[len(recorder.events)-1].Readings {
+			readings[r.ResourceName] = r.Value
+		}
+		return readings
+	}
+	float := func(name string, v float64) *dsModels.CommandValue {
+		cv, err := dsModels.NewFloat64Value(name, 0, v)
+		require.NoError(t, err)
+		return cv
+	}
+
+	readings := send(dsModels.NewStringValue("temperature", 0, `{"t": 20}`), float("offset", 1))
+	require.Contains(t, readings, "adjusted")
+
+	// the rejected JSON document drops the input of the computed resource
+	readings = send(dsModels.NewStringValue("temperature", 0, "not json"), float("offset", 2))
+	assert.NotContains(t, readings, "temperature")
+	assert.NotContains(t, readings, "adjusted", "not evaluated with the stale temperature")
+	assert.Contains(t, readings, "offset")
+
+	// the unmapped value is dropped with the alarm raised by its assertion kept
+	readings = send(dsModels.NewStringValue("mode", 0, "off"))
+	assert.NotContains(t, readings, "mode")
+	assert.Equal(t, "true", readings["modeAlarm"])
+}
+
+// blockedDiscovery runs until its job is cancelled
+type blockedDiscovery struct {
+	started chan struct{}
+}
+
+func (d blockedDiscovery) Discover(ctx context.Context, _ func(percent int)) error {
+	close(d.started)
+	<-ctx.Done()
+	return nil
+}
+
+// blockedDeviceAdd adds the devices once unblocked
+type blockedDeviceAdd struct {
+	interfaces.DeviceClient
+	added   chan []string
+	unblock chan struct{}
+}
+
+func (d *blockedDeviceAdd) Add(_ context.Context, reqs []requests.AddDeviceRequest) ([]commonDTO.BaseWithIdResponse, errors.EdgeX) {
+	names := make([]string, len(reqs))
+	for i, req := range reqs {
+		names[i] = req.Device.Name
+	}
+	d.added <- names
+	<-d.unblock
+	return respond(reqs, func(string) int { return http.StatusCreated }), nil
+}
+
+func TestFilterAndAdd(t *testing.T) {
+	initTestCache(t)
+	require.NoError(t, cache.ProvisionWatchers().Add(models.ProvisionWatcher{
+		Id:          "inflight-watcher-id",
+		Name:        "inflight-watcher",
+		Identifiers: map[string]string{"Serial": "^inflight-"},
+		ProfileName: "inflight-profile",
+		AdminState:  models.Unlocked,
+	}))
+	stub := &blockedDeviceAdd{added: make(chan []string, 1), unblock: make(chan struct{})}
+	s := &DeviceService{
+		LoggingClient: logger.NewMockClient(),
+		tedgeClients:  clients.TedgeClients{DeviceClient: stub},
+		config:        &common.ConfigurationStruct{},
+	}
+	s.config.Device.Discovery.IdentityKey = "Serial"
+
+	discovery := blockedDiscovery{started: make(chan struct{})}
+	job, err := autodiscovery.StartJob(autodiscovery.ContextDiscovery{ContextDiscovery: discovery}, s.LoggingClient)
+	require.NoError(t, err)
+	<-discovery.started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	registering := newInFlight()
+	batch := func(name string, serial string) {
+		held, release, ok := autodiscovery.HoldLatestJob()
+		require.True(t, ok)
+		require.Equal(t, job.Id, held.Id)
+		d := dsModels.DiscoveredDevice{Name: name, Protocols: map[string]models.ProtocolProperties{"modbus-tcp": {"Serial": serial}}}
+		s.filterAndAdd(ctx, &wg, []dsModels.DiscoveredDevice{d}, jobResults{id: held.Id, release: release, seen: make(map[string]bool)}, registering, nil)
+	}
+
+	batch("inflight-device", "inflight-1")
+	assert.Equal(t, []string{"inflight-device"}, <-stub.added)
+	// the device discovered again while being added isn't accepted twice
+	batch("inflight-device", "inflight-1")
+	// another device is named around the name being added
+	batch("inflight-device", "inflight-2")
+	added := <-stub.added
+	require.Len(t, added, 1)
+	assert.NotEqual(t, "inflight-device", added[0])
+
+	// the cancelled job ends once its devices are added
+	require.NoError(t, autodiscovery.CancelJob(job.Id))
+	time.Sleep(50 * time.Millisecond)
+	running, _ := autodiscovery.Job(job.Id)
+	assert.Equal(t, dtos.DiscoveryJobRunning, running.Status)
+	close(stub.unblock)
+
+	for i := 0; i < 100 && running.Status == dtos.DiscoveryJobRunning; i++ {
+		time.Sleep(10 * time.Millisecond)
+		running, _ = autodiscovery.Job(job.Id)
+	}
+	require.Equal(t, dtos.DiscoveryJobCancelled, running.Status)
+	decisions := make(map[string]int)
+	for _, decision := range running.Devices {
+		decisions[decision.Decision]++
+	}
+	assert.Equal(t, map[string]int{dtos.DiscoveryDecisionAccepted: 2, dtos.DiscoveryDecisionRejected: 1}, decisions)
+	assert.Empty(t, registering.taken())
+}
@@ -19,7 +19,10 @@ import (
 
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/autoevent"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/clients"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/eventbus"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/reconciler"
 	"github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
 )
 
@@ -39,18 +42,34 @@ func (b *Bootstrap) BootstrapHandler(ctx context.Context, wg *sync.WaitGroup, st
 	ds.UpdateFromContainer(b.router, dic)
 	autoevent.NewManager(ctx, wg, ds.config.Service.AsyncBufferSize, dic)
 
-	if err := ds.updateService(); err != nil {
-		ds.LoggingClient.Error(fmt.Sprintf("update device service instance config error: %v\n", err))
-		return false
-	}
+	degraded := clients.Degraded()
+	if degraded {
+		// metadata is unavailable, start from the snapshot and resync once it's back
+		svc, err := cache.InitCacheFromSnapshot(ds.config.Device.CacheSnapshot, ds.LoggingClient)
+		if err != nil {
+			ds.LoggingClient.Error(fmt.Sprintf("failed to load the cache snapshot: %v", err))
+			return false
+		}
+		ds.mutex.Lock()
+		ds.deviceService = svc
+		ds.mutex.Unlock()
+	} else {
+		if err := ds.updateService(); err != nil {
+			ds.LoggingClient.Error(fmt.Sprintf("update device service instance config error: %v\n", err))
+			return false
+		}
 
-	// initialize devices, deviceResources, provisionWatchers & profiles cache
-	cache.InitCache(
-		ds.deviceService.Name,
-		ds.LoggingClient,
-		container.MetadataDeviceProfileClientFrom(dic.Get),
-		container.MetadataDeviceClientFrom(dic.Get),
-		container.MetadataProvisionWatcherClientFrom(dic.Get))
+		// initialize devices, deviceResources, provisionWatchers & profiles cache
+		cache.InitCache(
+			ds.serviceInstance().Name,
+			ds.LoggingClient,
+			container.MetadataDeviceProfileClientFrom(dic.Get),
+			container.MetadataDeviceClientFrom(dic.Get),
+			container.MetadataProvisionWatcherClientFrom(dic.Get))
+	}
+	if ds.config.Device.CacheSnapshot != "" {
+		cache.PersistSnapshot(ctx, wg, ds.config.Device.CacheSnapshot, ds.serviceInstance(), ds.LoggingClient)
+	}
 
 	if ds.AsyncReadings() {
 		ds.asyncCh = make(chan *models.AsyncValues, ds.config.Service.AsyncBufferSize)
@@ -58,7 +77,7 @@ func (b *Bootstrap) BootstrapHandler(ctx context.Context, wg *sync.WaitGroup, st
 	}
 	if ds.DeviceDiscovery() {
 		ds.deviceCh = make(chan []models.DiscoveredDevice, 1)
-		go ds.processAsyncFilterAndAdd(ctx, wg)
+		go ds.processAsyncFilterAndAdd(ctx, wg, dic)
 	}
 
 	err := ds.driver.Initialize(ds.LoggingClient, ds.asyncCh, ds.deviceCh)
@@ -76,7 +95,7 @@ func (b *Bootstrap) BootstrapHandler(ctx context.Context, wg *sync.WaitGroup, st
 			return ds.driver
 		},
 		container.DeviceServiceName: func(get di.Get) interface{} {
-			return ds.deviceService
+			return ds.serviceInstance()
 		},
 		container.ProtocolDiscoveryName: func(get di.Get) interface{} {
 			return ds.discovery
@@ -86,6 +105,25 @@ func (b *Bootstrap) BootstrapHandler(ctx context.Context, wg *sync.WaitGroup, st
 		},
 	})
 
+	// the resync applies the changes through the driver callbacks, so it starts once the
+	// driver is initialized
+	if degraded {
+		wg.Add(1)
+		go ds.resync(ctx, wg, dic)
+	}
+
+	// keep the DeviceService up to date with the updates received from metadata
+	eventbus.Subscribe(eventbus.DeviceServiceTopic, func(event interface{}) {
+		change := event.(models.DeviceServiceChange)
+		ds.mutex.Lock()
+		ds.deviceService = *change.After
+		ds.config.Service.Labels = change.After.Labels
+		ds.mutex.Unlock()
+		if ds.config.Device.CacheSnapshot != "" {
+			cache.PersistSnapshot(ctx, wg, ds.config.Device.CacheSnapshot, *change.After, ds.LoggingClient)
+		}
+	})
+
 	ds.controller.InitRestRoutes()
 
 	autoevent.GetManager().StartAutoEvents(dic)
@@ -93,3 +131,40 @@ func (b *Bootstrap) BootstrapHandler(ctx context.Context, wg *sync.WaitGroup, st
 
 	return true
 }
+
+// resync waits for metadata to become available when the service started from the cache
+// snapshot, then registers the service and reconciles the cache with metadata.
+func (s *DeviceService) resync(ctx context.Context, wg *sync.WaitGroup, dic *di.Container) {
+	defer wg.Done()
+
+	interval, err := time.ParseDuration(s.config.Service.CheckInterval)
+	if err != nil || interval <= 0 {
+		interval = 10 * time.Second
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		if !clients.CheckMetadataAvailable(dic) {
+			continue
+		}
+		if err := s.updateService(); err != nil {
+			s.LoggingClient.Error(fmt.Sprintf("failed to update the device service after metadata recovered: %v", err))
+			continue
+		}
+		dic.Update(di.ServiceConstructorMap{
+			container.DeviceServiceName: func(get di.Get) interface{} {
+				return s.serviceInstance()
+			},
+		})
+		reconciler.Reconcile(dic)
+		if s.config.Device.CacheSnapshot != "" {
+			cache.PersistSnapshot(ctx, wg, s.config.Device.CacheSnapshot, s.serviceInstance(), s.LoggingClient)
+		}
+		clients.SetDegraded(false)
+		s.LoggingClient.Info("metadata available, left degraded mode")
+		return
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/clients"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/reconciler"
 )
 
 func Main(serviceName string, serviceVersion string, proto interface{}, ctx context.Context, cancel context.CancelFunc, router *mux.Router) {
@@ -54,6 +55,7 @@ func Main(serviceName string, serviceVersion string, proto interface{}, ctx cont
 			clients.NewClients().BootstrapHandler,
 			NewBootstrap(router).BootstrapHandler,
 			autodiscovery.BootstrapHandler,
+			reconciler.BootstrapHandler,
 			handlers.NewStartMessage(serviceName, serviceVersion).BootstrapHandler,
 		})
 
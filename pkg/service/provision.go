@@ -0,0 +1,160 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+// provisionedDevice returns the device the ProvisionWatcher adds for the discovered device,
+// which inherits the AutoEvents, AdminState and labels of the watcher, and the decision about
+// the discovered device. The names already taken by other devices get a suffix derived from
+// the protocol properties, the device is only added if the decision is accepted.
+func (s *DeviceService) provisionedDevice(d dsModels.DiscoveredDevice, pw models.ProvisionWatcher, taken map[string]bool) (models.Device, dtos.DiscoveredDeviceDecision) {
+	decision := dtos.DiscoveredDeviceDecision{
+		Name:             d.Name,
+		Protocols:        dtos.FromProtocolModelsToDTOs(d.Protocols),
+		ProvisionWatcher: pw.Name,
+	}
+
+	name := d.Name
+	if text, ok := watcherNameTemplate(pw); ok {
+		if templated, err := executeNameTemplate(text, d.Protocols); err != nil {
+			s.LoggingClient.Warn(fmt.Sprintf("failed to name discovered device %s with the template of provision watcher %s: %v", d.Name, pw.Name, err))
+		} else {
+			name = templated
+		}
+	}
+
+	available := ""
+	for _, candidate := range []string{name, name + "-" + protocolsDigest(d.Protocols)} {
+		existing, ok := cache.Devices().ForName(candidate)
+		if ok && sameProtocols(existing.Protocols, d.Protocols) {
+			s.LoggingClient.Debug(fmt.Sprintf("Candidate discovered device %s already existed", candidate))
+			decision.Decision = dtos.DiscoveryDecisionExisting
+			decision.DeviceName = candidate
+			return models.Device{}, decision
+		}
+		if !ok && !taken[candidate] {
+			available = candidate
+			break
+		}
+	}
+	if available == "" {
+		s.LoggingClient.Warn(fmt.Sprintf("failed to find an available name for discovered device %s", name))
+		decision.Decision = dtos.DiscoveryDecisionFailed
+		decision.Reason = fmt.Sprintf("no available name for %s", name)
+		return models.Device{}, decision
+	}
+	taken[available] = true
+	name = available
+
+	device := models.Device{
+		Name:           name,
+		Description:    d.Description,
+		ProfileName:    pw.ProfileName,
+		Protocols:      d.Protocols,
+		Labels:         mergeLabels(d.Labels, pw.Labels),
+		ServiceName:    pw.ServiceName,
+		AdminState:     pw.AdminState,
+		OperatingState: models.Up,
+		AutoEvents:     pw.AutoEvents,
+	}
+	device.Created = time.Now().UnixNano() / 1e6
+	decision.Decision = dtos.DiscoveryDecisionAccepted
+	decision.DeviceName = name
+	return device, decision
+}
+
+func watcherNameTemplate(pw models.ProvisionWatcher) (string, bool) {
+	for _, label := range pw.Labels {
+		if strings.HasPrefix(label, common.WatcherLabelNameTemplate) {
+			return strings.TrimPrefix(label, common.WatcherLabelNameTemplate), true
+		}
+	}
+	return "", false
+}
+
+// executeNameTemplate executes the name template over the protocol properties of all the
+// protocols, the protocols are merged by name order.
+func executeNameTemplate(text string, protocols map[string]models.ProtocolProperties) (string, error) {
+	t, err := template.New("name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(protocols))
+	for name := range protocols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	properties := make(map[string]string)
+	for _, name := range names {
+		for k, v := range protocols[name] {
+			properties[k] = v
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, properties); err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(buf.String())
+	if name == "" {
+		return "", fmt.Errorf("template %s produced an empty name", text)
+	}
+	return name, nil
+}
+
+// sameProtocols returns whether the protocols are the same, the overrides set on a device
+// aren't part of its discovered protocols.
+func sameProtocols(a map[string]models.ProtocolProperties, b map[string]models.ProtocolProperties) bool {
+	return reflect.DeepEqual(cache.WithoutOverrides(a), cache.WithoutOverrides(b))
+}
+
+// protocolsDigest returns a short digest of the protocol properties, which is the same for
+// each discovery of a device.
+func protocolsDigest(protocols map[string]models.ProtocolProperties) string {
+	var lines []string
+	for protocol, properties := range protocols {
+		for k, v := range properties {
+			lines = append(lines, protocol+"\x00"+k+"\x00"+v)
+		}
+	}
+	sort.Strings(lines)
+	sum := sha1.Sum([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:4])
+}
+
+// mergeLabels returns the labels of the discovered device followed by the labels of the
+// watcher, without duplicates and without the labels reserved by the SDK.
+func mergeLabels(deviceLabels []string, watcherLabels []string) []string {
+	seen := make(map[string]bool, len(deviceLabels)+len(watcherLabels))
+	var labels []string
+	for _, label := range append(append([]string{}, deviceLabels...), watcherLabels...) {
+		if seen[label] || strings.HasPrefix(label, common.SDKReservedPrefix) {
+			continue
+		}
+		seen[label] = true
+		labels = append(labels, label)
+	}
+	return labels
+}
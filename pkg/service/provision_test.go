@@ -0,0 +1,177 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+var initCacheOnce sync.Once
+
+func initTestCache(t *testing.T) {
+	initCacheOnce.Do(func() {
+		dir, err := ioutil.TempDir("", "service-test")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "snapshot.json")
+		require.NoError(t, ioutil.WriteFile(path, []byte("{}"), 0600))
+		_, err = cache.InitCacheFromSnapshot(path, logger.NewMockClient())
+		require.NoError(t, err)
+	})
+}
+
+func TestExecuteNameTemplate(t *testing.T) {
+	protocols := map[string]models.ProtocolProperties{
+		"modbus-tcp": {"Address": "10.0.0.1", "Port": "502"},
+		"other":      {"Address": "10.0.0.2", "Serial": "A1"},
+	}
+
+	tests := []struct {
+		name          string
+		text          string
+		expected      string
+		expectedError bool
+	}{
+		{"properties", "meter-{{.Serial}}-{{.Port}}", "meter-A1-502", false},
+		{"later protocol by name wins", "meter-{{.Address}}", "meter-10.0.0.2", false},
+		{"spaces trimmed", " meter-{{.Serial}} ", "meter-A1", false},
+		{"missing key", "meter-{{.UnitID}}", "", true},
+		{"empty result", "{{if .Missing}}{{end}}", "", true},
+		{"blank result", "  ", "", true},
+		{"invalid template", "meter-{{.Serial", "", true},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			name, err := executeNameTemplate(testCase.text, protocols)
+			if testCase.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, name)
+		})
+	}
+}
+
+func TestProtocolsDigest(t *testing.T) {
+	protocols := map[string]models.ProtocolProperties{"modbus-tcp": {"Address": "10.0.0.1", "Port": "502"}}
+	same := map[string]models.ProtocolProperties{"modbus-tcp": {"Port": "502", "Address": "10.0.0.1"}}
+	other := map[string]models.ProtocolProperties{"modbus-tcp": {"Address": "10.0.0.2", "Port": "502"}}
+	moved := map[string]models.ProtocolProperties{"other": {"Address": "10.0.0.1", "Port": "502"}}
+
+	digest := protocolsDigest(protocols)
+	assert.Len(t, digest, 8)
+	assert.Equal(t, digest, protocolsDigest(same))
+	assert.NotEqual(t, digest, protocolsDigest(other))
+	assert.NotEqual(t, digest, protocolsDigest(moved))
+}
+
+func TestMergeLabels(t *testing.T) {
+	tests := []struct {
+		name          string
+		deviceLabels  []string
+		watcherLabels []string
+		expected      []string
+	}{
+		{"none", nil, nil, nil},
+		{"device then watcher", []string{"sensor"}, []string{"floor-1"}, []string{"sensor", "floor-1"}},
+		{"duplicates", []string{"sensor", "sensor"}, []string{"floor-1", "sensor"}, []string{"sensor", "floor-1"}},
+		{"reserved labels of the watcher", []string{"sensor"}, []string{common.WatcherLabelNameTemplate + "meter-{{.Serial}}", common.WatcherLabelDiscoveryScope + "10.0.0.0/24", "floor-1"}, []string{"sensor", "floor-1"}},
+		{"reserved labels of the device", []string{common.SDKReservedPrefix + "down", "sensor"}, nil, []string{"sensor"}},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, mergeLabels(testCase.deviceLabels, testCase.watcherLabels))
+		})
+	}
+}
+
+func TestProvisionedDevice(t *testing.T) {
+	initTestCache(t)
+	s := &DeviceService{LoggingClient: logger.NewMockClient()}
+
+	protocols := map[string]models.ProtocolProperties{"modbus-tcp": {"Address": "10.0.0.1", "Serial": "P1"}}
+	otherProtocols := map[string]models.ProtocolProperties{"modbus-tcp": {"Address": "10.0.0.9", "Serial": "P1"}}
+	withOverrides := map[string]models.ProtocolProperties{
+		"modbus-tcp":             protocols["modbus-tcp"],
+		common.ProtocolOverrides: {"temperature.scale": "0.1"},
+	}
+	require.NoError(t, cache.Devices().Add(models.Device{Id: "provision-existing-id", Name: "provision-existing", Protocols: withOverrides}))
+	require.NoError(t, cache.Devices().Add(models.Device{Id: "provision-taken-id", Name: "provision-taken", Protocols: otherProtocols}))
+	require.NoError(t, cache.Devices().Add(models.Device{Id: "provision-full-id", Name: "provision-full", Protocols: otherProtocols}))
+	require.NoError(t, cache.Devices().Add(models.Device{Id: "provision-full-suffixed-id", Name: "provision-full-" + protocolsDigest(protocols), Protocols: otherProtocols}))
+
+	watcher := models.ProvisionWatcher{
+		Name:        "provision-watcher",
+		Labels:      []string{"floor-1", common.WatcherLabelDiscoveryScope + "10.0.0.0/24"},
+		ProfileName: "meter",
+		ServiceName: "device-test",
+		AdminState:  models.Locked,
+		AutoEvents:  []models.AutoEvent{{Resource: "temperature", Frequency: "10s"}},
+	}
+	templated := watcher
+	templated.Labels = append([]string{common.WatcherLabelNameTemplate + "provision-{{.Serial}}"}, watcher.Labels...)
+	missingKey := watcher
+	missingKey.Labels = []string{common.WatcherLabelNameTemplate + "provision-{{.UnitID}}"}
+
+	tests := []struct {
+		name             string
+		discoveredName   string
+		protocols        map[string]models.ProtocolProperties
+		watcher          models.ProvisionWatcher
+		taken            map[string]bool
+		expectedDecision string
+		expectedName     string
+		expectedLabels   []string
+	}{
+		{"accepted", "provision-new", protocols, watcher, nil, dtos.DiscoveryDecisionAccepted, "provision-new", []string{"sensor", "floor-1"}},
+		{"named by the template", "provision-new", protocols, templated, nil, dtos.DiscoveryDecisionAccepted, "provision-P1", []string{"sensor", "floor-1"}},
+		{"template missing a key", "provision-new", protocols, missingKey, nil, dtos.DiscoveryDecisionAccepted, "provision-new", []string{"sensor"}},
+		{"existing despite overrides", "provision-existing", protocols, watcher, nil, dtos.DiscoveryDecisionExisting, "provision-existing", nil},
+		{"name taken by another device", "provision-taken", protocols, watcher, nil, dtos.DiscoveryDecisionAccepted, "provision-taken-" + protocolsDigest(protocols), []string{"sensor", "floor-1"}},
+		{"name taken in the batch", "provision-new", protocols, watcher, map[string]bool{"provision-new": true}, dtos.DiscoveryDecisionAccepted, "provision-new-" + protocolsDigest(protocols), []string{"sensor", "floor-1"}},
+		{"no available name", "provision-full", protocols, watcher, nil, dtos.DiscoveryDecisionFailed, "", nil},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			taken := testCase.taken
+			if taken == nil {
+				taken = make(map[string]bool)
+			}
+			d := dsModels.DiscoveredDevice{Name: testCase.discoveredName, Protocols: testCase.protocols, Labels: []string{"sensor"}}
+
+			device, decision := s.provisionedDevice(d, testCase.watcher, taken)
+			assert.Equal(t, testCase.expectedDecision, decision.Decision)
+			assert.Equal(t, testCase.expectedName, decision.DeviceName)
+			if decision.Decision != dtos.DiscoveryDecisionAccepted {
+				assert.Empty(t, device.Name)
+				return
+			}
+			assert.Equal(t, testCase.expectedName, device.Name)
+			assert.True(t, taken[device.Name])
+			assert.Equal(t, testCase.watcher.AutoEvents, device.AutoEvents)
+			assert.Equal(t, models.AdminState(models.Locked), device.AdminState)
+			assert.Equal(t, models.OperatingState(models.Up), device.OperatingState)
+			assert.Equal(t, "meter", device.ProfileName)
+			assert.Equal(t, testCase.expectedLabels, device.Labels, "the reserved labels of the watcher aren't inherited")
+		})
+	}
+}
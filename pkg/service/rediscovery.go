@@ -0,0 +1,170 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/edgexfoundry/go-mod-bootstrap/v2/di"
+	"github.com/google/uuid"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	commonDTO "github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/requests"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/autodiscovery"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/callback"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+// reconcileDiscovered matches the discovered devices to the existing devices by the identity
+// key of the discovery configuration. The existing devices whose protocols changed are
+// updated, the ones marked DOWN with the common.DeviceLabelDiscoveryDown label by countMissed
+// are marked UP again once rediscovered, even by another run of the service. The decisions are
+// recorded in the discovery job, the names of the matched devices are added to seen, the
+// discovered devices matching no existing device are returned.
+func (s *DeviceService) reconcileDiscovered(discovered []dsModels.DiscoveredDevice, job string, seen map[string]bool, dic *di.Container) []dsModels.DiscoveredDevice {
+	key := s.config.Device.Discovery.IdentityKey
+	if key == "" {
+		return discovered
+	}
+
+	existing := make(map[string]models.Device)
+	for _, device := range cache.Devices().All() {
+		if identity, ok := identityOf(device.Protocols, key); ok {
+			existing[identity] = device
+		}
+	}
+
+	var unmatched []dsModels.DiscoveredDevice
+	for _, d := range discovered {
+		identity, ok := identityOf(d.Protocols, key)
+		device, exists := existing[identity]
+		if !ok || !exists {
+			unmatched = append(unmatched, d)
+			continue
+		}
+		seen[device.Name] = true
+		autodiscovery.RecordDecisions(job, dtos.DiscoveredDeviceDecision{
+			Name:       d.Name,
+			Protocols:  dtos.FromProtocolModelsToDTOs(d.Protocols),
+			Decision:   dtos.DiscoveryDecisionExisting,
+			DeviceName: device.Name,
+			Reason:     fmt.Sprintf("matched by %s %s", key, identity),
+		})
+
+		update := dtos.UpdateDevice{Name: &device.Name}
+		protocols := rediscoveredProtocols(device, d)
+		if !reflect.DeepEqual(device.Protocols, protocols) {
+			s.LoggingClient.Info(fmt.Sprintf("discovered device %s with %s %s has new protocols, updating it", device.Name, key, identity))
+			update.Protocols = dtos.FromProtocolModelsToDTOs(protocols)
+		}
+		if common.HasLabel(device.Labels, common.DeviceLabelDiscoveryDown) {
+			update.Labels = common.WithoutLabel(device.Labels, common.DeviceLabelDiscoveryDown)
+			// a device also DOWN by the violation of an assertion is left DOWN
+			if device.OperatingState == models.Down && !common.HasLabel(device.Labels, common.DeviceLabelAssertionDown) {
+				s.LoggingClient.Info(fmt.Sprintf("discovered device %s with %s %s again, marking it UP", device.Name, key, identity))
+				up := string(models.Up)
+				update.OperatingState = &up
+			}
+		}
+		if update.Protocols != nil || update.Labels != nil {
+			s.updateDiscoveredDevice(update, dic)
+		}
+	}
+	return unmatched
+}
+
+// countMissed counts the discovery rounds missed by the existing devices once the discovery
+// job is finished, seen are the names of the devices it rediscovered. Only the completed full
+// discoveries count the missed rounds, as the devices out of the scope of a ProvisionWatcher
+// aren't looked for. The devices missed by MarkDownAfter rounds in a row are marked DOWN with
+// the common.DeviceLabelDiscoveryDown label. missed counts the rounds by device name.
+func (s *DeviceService) countMissed(job dtos.DiscoveryJob, seen map[string]bool, missed map[string]int, dic *di.Container) {
+	for name := range seen {
+		delete(missed, name)
+	}
+	key := s.config.Device.Discovery.IdentityKey
+	rounds := s.config.Device.Discovery.MarkDownAfter
+	if key == "" || rounds <= 0 || job.Status != dtos.DiscoveryJobCompleted || job.ProvisionWatcher != "" {
+		return
+	}
+
+	existing := make(map[string]models.Device)
+	for _, device := range cache.Devices().All() {
+		if _, ok := identityOf(device.Protocols, key); ok {
+			existing[device.Name] = device
+		}
+	}
+	// forget the removed devices
+	for name := range missed {
+		if _, ok := existing[name]; !ok {
+			delete(missed, name)
+		}
+	}
+	for _, device := range existing {
+		if seen[device.Name] {
+			continue
+		}
+		missed[device.Name]++
+		if missed[device.Name] >= rounds && device.OperatingState != models.Down {
+			s.LoggingClient.Info(fmt.Sprintf("device %s missed by %d discovery rounds, marking it DOWN", device.Name, missed[device.Name]))
+			down := string(models.Down)
+			labels := append(common.WithoutLabel(device.Labels, common.DeviceLabelDiscoveryDown), common.DeviceLabelDiscoveryDown)
+			s.updateDiscoveredDevice(dtos.UpdateDevice{Name: &device.Name, OperatingState: &down, Labels: labels}, dic)
+		}
+	}
+}
+
+// updateDiscoveredDevice updates the device in metadata and applies the update through the
+// UpdateDevice callback.
+func (s *DeviceService) updateDiscoveredDevice(update dtos.UpdateDevice, dic *di.Container) {
+	req := requests.UpdateDeviceRequest{BaseRequest: commonDTO.NewBaseRequest(), Device: update}
+	ctx := context.WithValue(context.Background(), common.CorrelationHeader, uuid.New().String())
+	if _, err := s.tedgeClients.DeviceClient.Update(ctx, []requests.UpdateDeviceRequest{req}); err != nil {
+		s.LoggingClient.Error(fmt.Sprintf("failed to update device %s in metadata: %v", *update.Name, err))
+		return
+	}
+	if err := callback.UpdateDevice(req, dic); err != nil {
+		s.LoggingClient.Error(fmt.Sprintf("failed to update device %s: %v", *update.Name, err))
+	}
+}
+
+// identityOf returns the value of the identity key in the protocol properties, the first
+// protocol by name order defining it wins.
+func identityOf(protocols map[string]models.ProtocolProperties, key string) (string, bool) {
+	names := make([]string, 0, len(protocols))
+	for name := range protocols {
+		if name != common.ProtocolOverrides {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if value, ok := protocols[name][key]; ok && value != "" {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// rediscoveredProtocols returns the discovered protocols, keeping the overrides of the device.
+func rediscoveredProtocols(device models.Device, d dsModels.DiscoveredDevice) map[string]models.ProtocolProperties {
+	protocols := make(map[string]models.ProtocolProperties, len(d.Protocols)+1)
+	for name, properties := range d.Protocols {
+		protocols[name] = properties
+	}
+	if overrides, ok := device.Protocols[common.ProtocolOverrides]; ok {
+		protocols[common.ProtocolOverrides] = overrides
+	}
+	return protocols
+}
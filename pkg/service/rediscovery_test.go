@@ -0,0 +1,227 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	bootstrapContainer "github.com/edgexfoundry/go-mod-bootstrap/v2/bootstrap/container"
+	"github.com/edgexfoundry/go-mod-bootstrap/v2/di"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/clients/interfaces"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	commonDTO "github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/requests"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/responses"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/autoevent"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/clients"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
+	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+// deviceUpdateRecorder records the device updates sent to metadata
+type deviceUpdateRecorder struct {
+	interfaces.DeviceClient
+	updates map[string][]dtos.UpdateDevice
+}
+
+func (r *deviceUpdateRecorder) Update(_ context.Context, reqs []requests.UpdateDeviceRequest) ([]commonDTO.BaseResponse, errors.EdgeX) {
+	res := make([]commonDTO.BaseResponse, len(reqs))
+	for i, req := range reqs {
+		r.updates[*req.Device.Name] = append(r.updates[*req.Device.Name], req.Device)
+		res[i] = commonDTO.NewBaseResponse(req.RequestId, "", 200)
+	}
+	return res, nil
+}
+
+// profileClientStub returns the same profile for any name
+type profileClientStub struct {
+	interfaces.DeviceProfileClient
+	profile dtos.DeviceProfile
+}
+
+func (p profileClientStub) DeviceProfileByName(context.Context, string) (responses.DeviceProfileResponse, errors.EdgeX) {
+	return responses.DeviceProfileResponse{Profile: p.profile}, nil
+}
+
+// acceptingDriver accepts the device updates
+type acceptingDriver struct {
+	dsModels.ProtocolDriver
+}
+
+func (acceptingDriver) UpdateDevice(string, map[string]models.ProtocolProperties, models.AdminState) error {
+	return nil
+}
+
+func TestIdentityOf(t *testing.T) {
+	tests := []struct {
+		name             string
+		protocols        map[string]models.ProtocolProperties
+		expectedIdentity string
+		expectedOk       bool
+	}{
+		{"identity", map[string]models.ProtocolProperties{"modbus-tcp": {"Serial": "S1"}}, "S1", true},
+		{"first protocol by name", map[string]models.ProtocolProperties{"b": {"Serial": "S2"}, "a": {"Serial": "S1"}, "c": {"Serial": "S3"}}, "S1", true},
+		{"empty value skipped", map[string]models.ProtocolProperties{"a": {"Serial": ""}, "b": {"Serial": "S2"}}, "S2", true},
+		{"overrides ignored", map[string]models.ProtocolProperties{common.ProtocolOverrides: {"Serial": "S0"}, "modbus-tcp": {"Serial": "S1"}}, "S1", true},
+		{"only in the overrides", map[string]models.ProtocolProperties{common.ProtocolOverrides: {"Serial": "S0"}}, "", false},
+		{"missing", map[string]models.ProtocolProperties{"modbus-tcp": {"Address": "10.0.0.1"}}, "", false},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				identity, ok := identityOf(testCase.protocols, "Serial")
+				assert.Equal(t, testCase.expectedOk, ok)
+				assert.Equal(t, testCase.expectedIdentity, identity)
+			}
+		})
+	}
+}
+
+func TestReconcileDiscovered(t *testing.T) {
+	initTestCache(t)
+	profile := dtos.ToDeviceProfileModel(dtos.FromDeviceProfileModelToDTO(models.DeviceProfile{
+		Name:            "rediscovery-profile",
+		DeviceResources: []models.DeviceResource{{Name: "temperature", Properties: models.PropertyValue{Type: contracts.ValueTypeFloat64, ReadWrite: "R"}}},
+	}))
+	require.NoError(t, cache.Profiles().Add(profile))
+
+	const key = "RediscoveryId"
+	protocols := func(address string, id string) map[string]models.ProtocolProperties {
+		return map[string]models.ProtocolProperties{"modbus-tcp": {"Address": address, key: id}}
+	}
+	overrides := models.ProtocolProperties{"temperature.properties.scale": "0.1"}
+	moved := protocols("10.0.0.1", "A")
+	moved[common.ProtocolOverrides] = overrides
+	for _, device := range []models.Device{
+		{Id: "rediscovery-moved-id", Name: "rediscovery-moved", Protocols: moved, OperatingState: models.Up},
+		{Id: "rediscovery-missed-id", Name: "rediscovery-missed", Protocols: protocols("10.0.0.2", "B"), Labels: []string{"sensor"}, OperatingState: models.Up},
+		{Id: "rediscovery-down-id", Name: "rediscovery-down", Protocols: protocols("10.0.0.3", "C"), OperatingState: models.Down},
+		{Id: "rediscovery-asserted-id", Name: "rediscovery-asserted", Protocols: protocols("10.0.0.4", "D"), OperatingState: models.Down,
+			Labels: []string{common.DeviceLabelDiscoveryDown, common.DeviceLabelAssertionDown}},
+	} {
+		device.ProfileName = profile.Name
+		require.NoError(t, cache.Devices().Add(device))
+	}
+
+	recorder := &deviceUpdateRecorder{updates: make(map[string][]dtos.UpdateDevice)}
+	dic := di.NewContainer(di.ServiceConstructorMap{
+		bootstrapContainer.LoggingClientInterfaceName: func(get di.Get) interface{} {
+			return logger.NewMockClient()
+		},
+		container.MetadataDeviceProfileClientName: func(get di.Get) interface{} {
+			return profileClientStub{profile: dtos.FromDeviceProfileModelToDTO(profile)}
+		},
+		container.ProtocolDriverName: func(get di.Get) interface{} {
+			return acceptingDriver{}
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	autoevent.NewManager(ctx, &wg, 1, dic)
+
+	s := &DeviceService{
+		LoggingClient: logger.NewMockClient(),
+		tedgeClients:  clients.TedgeClients{DeviceClient: recorder},
+		config:        &common.ConfigurationStruct{},
+	}
+	s.config.Device.Discovery.IdentityKey = key
+	s.config.Device.Discovery.MarkDownAfter = 2
+	discovered := func(address string, id string) dsModels.DiscoveredDevice {
+		return dsModels.DiscoveredDevice{Name: "discovered-" + id, Protocols: protocols(address, id)}
+	}
+	stateOf := func(name string) (models.OperatingState, []string) {
+		device, ok := cache.Devices().ForName(name)
+		require.True(t, ok)
+		return device.OperatingState, device.Labels
+	}
+
+	full := dtos.DiscoveryJob{Status: dtos.DiscoveryJobCompleted}
+	// round runs a discovery job writing the batches of discovered devices
+	round := func(job dtos.DiscoveryJob, missed map[string]int, batches ...[]dsModels.DiscoveredDevice) []dsModels.DiscoveredDevice {
+		var unmatched []dsModels.DiscoveredDevice
+		seen := make(map[string]bool)
+		for _, batch := range batches {
+			unmatched = append(unmatched, s.reconcileDiscovered(batch, job.Id, seen, dic)...)
+		}
+		s.countMissed(job, seen, missed, dic)
+		return unmatched
+	}
+
+	// the moved device is updated keeping its overrides, the unknown one is returned
+	missed := make(map[string]int)
+	unmatched := round(full, missed, []dsModels.DiscoveredDevice{discovered("10.0.0.5", "A"), discovered("10.0.0.3", "C"), discovered("10.0.0.4", "D"), discovered("10.0.0.9", "X")})
+	require.Len(t, unmatched, 1)
+	assert.Equal(t, "discovered-X", unmatched[0].Name)
+	require.Len(t, recorder.updates["rediscovery-moved"], 1)
+	expected := protocols("10.0.0.5", "A")
+	expected[common.ProtocolOverrides] = overrides
+	assert.Equal(t, dtos.FromProtocolModelsToDTOs(expected), recorder.updates["rediscovery-moved"][0].Protocols)
+	device, _ := cache.Devices().ForName("rediscovery-moved")
+	assert.Equal(t, expected, device.Protocols)
+	assert.Equal(t, 1, missed["rediscovery-missed"])
+	assert.Empty(t, recorder.updates["rediscovery-missed"])
+
+	// the missed device is marked DOWN after MarkDownAfter rounds
+	round(full, missed, []dsModels.DiscoveredDevice{discovered("10.0.0.5", "A"), discovered("10.0.0.3", "C")})
+	state, labels := stateOf("rediscovery-missed")
+	assert.Equal(t, models.OperatingState(models.Down), state)
+	assert.Equal(t, []string{"sensor", common.DeviceLabelDiscoveryDown}, labels)
+	assert.Len(t, recorder.updates["rediscovery-moved"], 1, "the unchanged protocols aren't updated")
+
+	// the device is marked UP once rediscovered, even without the rounds counted before a restart
+	missed = make(map[string]int)
+	round(full, missed, []dsModels.DiscoveredDevice{discovered("10.0.0.5", "A"), discovered("10.0.0.2", "B"), discovered("10.0.0.3", "C")})
+	state, labels = stateOf("rediscovery-missed")
+	assert.Equal(t, models.OperatingState(models.Up), state)
+	assert.Equal(t, []string{"sensor"}, labels)
+	assert.Len(t, recorder.updates["rediscovery-missed"], 2)
+
+	// a round is counted once whatever the number of batches its devices are written in
+	before := missed["rediscovery-asserted"]
+	round(full, missed, []dsModels.DiscoveredDevice{discovered("10.0.0.5", "A")}, []dsModels.DiscoveredDevice{discovered("10.0.0.2", "B")},
+		[]dsModels.DiscoveredDevice{discovered("10.0.0.3", "C")})
+	assert.Zero(t, missed["rediscovery-missed"])
+	assert.Equal(t, before+1, missed["rediscovery-asserted"])
+
+	// a scoped, cancelled or failed discovery doesn't count the devices it didn't look for as missed
+	for _, job := range []dtos.DiscoveryJob{
+		{Status: dtos.DiscoveryJobCompleted, ProvisionWatcher: "rediscovery-watcher"},
+		{Status: dtos.DiscoveryJobCancelled},
+		{Status: dtos.DiscoveryJobFailed},
+	} {
+		round(job, missed, []dsModels.DiscoveredDevice{discovered("10.0.0.5", "A")})
+	}
+	state, _ = stateOf("rediscovery-missed")
+	assert.Equal(t, models.OperatingState(models.Up), state)
+	assert.Zero(t, missed["rediscovery-missed"])
+	assert.Len(t, recorder.updates["rediscovery-missed"], 2)
+
+	// a device also DOWN by the violation of an assertion is left DOWN
+	state, labels = stateOf("rediscovery-asserted")
+	assert.Equal(t, models.OperatingState(models.Down), state)
+	assert.Equal(t, []string{common.DeviceLabelAssertionDown}, labels)
+
+	// a device DOWN for another reason is left DOWN
+	state, _ = stateOf("rediscovery-down")
+	assert.Equal(t, models.OperatingState(models.Down), state)
+	assert.Empty(t, recorder.updates["rediscovery-down"])
+}
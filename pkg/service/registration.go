@@ -0,0 +1,230 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	commonDTO "github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/requests"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+// registration is a discovered device accepted by a ProvisionWatcher, along with its
+// decision which is updated with the outcome of adding the device.
+type registration struct {
+	request  requests.AddDeviceRequest
+	decision *dtos.DiscoveredDeviceDecision
+}
+
+// inFlight tracks the discovered devices being added, by name and by identity, so that a
+// device discovered again before it's added isn't accepted twice.
+type inFlight struct {
+	names      map[string]string // identity by device name
+	identities map[string]string // device name by identity
+	mux        sync.Mutex
+}
+
+func newInFlight() *inFlight {
+	return &inFlight{names: make(map[string]string), identities: make(map[string]string)}
+}
+
+// taken returns the names of the devices being added.
+func (f *inFlight) taken() map[string]bool {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	taken := make(map[string]bool, len(f.names))
+	for name := range f.names {
+		taken[name] = true
+	}
+	return taken
+}
+
+// adding returns the name of the device being added with the identity.
+func (f *inFlight) adding(identity string) (string, bool) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	name, ok := f.identities[identity]
+	return name, ok
+}
+
+func (f *inFlight) add(name string, identity string) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	f.names[name] = identity
+	f.identities[identity] = name
+}
+
+func (f *inFlight) remove(name string) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	delete(f.identities, f.names[name])
+	delete(f.names, name)
+}
+
+// discoveredIdentity returns the identity of the discovered device, the value of the identity
+// key if configured, otherwise the digest of its protocol properties.
+func (s *DeviceService) discoveredIdentity(d dsModels.DiscoveredDevice) string {
+	if identity, ok := identityOf(d.Protocols, s.config.Device.Discovery.IdentityKey); ok {
+		return identity
+	}
+	return protocolsDigest(d.Protocols)
+}
+
+// dedupeDiscovered drops the devices reported more than once by the discovery, they're
+// identified by the identity key if configured, otherwise by their protocol properties.
+// The decisions about the dropped devices are returned.
+func (s *DeviceService) dedupeDiscovered(discovered []dsModels.DiscoveredDevice) ([]dsModels.DiscoveredDevice, []dtos.DiscoveredDeviceDecision) {
+	first := make(map[string]string, len(discovered))
+	unique := make([]dsModels.DiscoveredDevice, 0, len(discovered))
+	var duplicates []dtos.DiscoveredDeviceDecision
+	for _, d := range discovered {
+		identity := s.discoveredIdentity(d)
+		if name, seen := first[identity]; seen {
+			duplicates = append(duplicates, dtos.DiscoveredDeviceDecision{
+				Name:      d.Name,
+				Protocols: dtos.FromProtocolModelsToDTOs(d.Protocols),
+				Decision:  dtos.DiscoveryDecisionRejected,
+				Reason:    fmt.Sprintf("duplicate of discovered device %s", name),
+			})
+			continue
+		}
+		first[identity] = d.Name
+		unique = append(unique, d)
+	}
+	return unique, duplicates
+}
+
+// registerDevices adds the devices to metadata in chunks of Service.MaxResultCount requests,
+// all at once if it isn't set, and records the outcome of each request in its decision. The
+// requests which fail with a server or communication error are retried Discovery.AddRetries
+// times, waiting Service.Timeout between the attempts.
+func (s *DeviceService) registerDevices(ctx context.Context, devices []models.Device, decisions []*dtos.DiscoveredDeviceDecision) {
+	pending := make([]registration, 0, len(devices))
+	for i, device := range devices {
+		pending = append(pending, registration{
+			request: requests.AddDeviceRequest{
+				BaseRequest: commonDTO.NewBaseRequest(),
+				Device:      dtos.FromDeviceModelToDTO(device),
+			},
+			decision: decisions[i],
+		})
+	}
+
+	retries := s.config.Device.Discovery.AddRetries
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Duration(s.config.Service.Timeout) * time.Millisecond):
+			}
+			s.LoggingClient.Info(fmt.Sprintf("retrying to add %d discovered devices, attempt %d", len(pending), attempt+1))
+		}
+		last := attempt >= retries
+		var failed []registration
+		for _, chunk := range chunkRegistrations(pending, s.config.Service.MaxResultCount) {
+			failed = append(failed, s.addChunk(ctx, chunk, attempt+1, last)...)
+		}
+		pending = failed
+	}
+}
+
+// addChunk adds a chunk of devices and returns the registrations to retry, none if it's the
+// last attempt. A retried chunk may contain devices added by a failed attempt whose response
+// was lost, so a conflict on a retry is taken as the device being added.
+func (s *DeviceService) addChunk(ctx context.Context, chunk []registration, attempt int, last bool) []registration {
+	reqs := make([]requests.AddDeviceRequest, len(chunk))
+	for i, r := range chunk {
+		reqs[i] = r.request
+		r.decision.Attempts = attempt
+	}
+
+	var retry []registration
+	res, err := s.tedgeClients.DeviceClient.Add(ctx, reqs)
+	if err != nil {
+		s.LoggingClient.Error(fmt.Sprintf("failed to add %d discovered devices: %v", len(chunk), err))
+		for _, r := range chunk {
+			if s.recordFailure(r, err.Code(), err.Error(), last) {
+				retry = append(retry, r)
+			}
+		}
+		return retry
+	}
+
+	byRequestId := make(map[string]commonDTO.BaseWithIdResponse, len(res))
+	for _, response := range res {
+		byRequestId[response.RequestId] = response
+	}
+	for i, r := range chunk {
+		response, ok := byRequestId[r.request.RequestId]
+		if !ok && i < len(res) && res[i].RequestId == "" {
+			// the responses are in the order of the requests
+			response, ok = res[i], true
+		}
+		switch {
+		case !ok:
+			if s.recordFailure(r, http.StatusInternalServerError, "no response for the device", last) {
+				retry = append(retry, r)
+			}
+		case response.StatusCode >= http.StatusOK && response.StatusCode < http.StatusMultipleChoices:
+			r.decision.Decision = dtos.DiscoveryDecisionAccepted
+			r.decision.Reason = ""
+			s.LoggingClient.Info(fmt.Sprintf("discovered device %s added with id %s", r.request.Device.Name, response.Id))
+		case response.StatusCode == http.StatusConflict && attempt > 1:
+			r.decision.Decision = dtos.DiscoveryDecisionAccepted
+			r.decision.Reason = ""
+			s.LoggingClient.Info(fmt.Sprintf("discovered device %s already added by a previous attempt", r.request.Device.Name))
+		default:
+			if s.recordFailure(r, response.StatusCode, fmt.Sprint(response.Message), last) {
+				retry = append(retry, r)
+			}
+		}
+	}
+	return retry
+}
+
+// recordFailure records the failure in the decision and returns whether it's retried. The
+// server and communication errors are retried, unless it's the last attempt.
+func (s *DeviceService) recordFailure(r registration, code int, message string, last bool) bool {
+	r.decision.Decision = dtos.DiscoveryDecisionFailed
+	r.decision.Reason = fmt.Sprintf("%d: %s", code, message)
+	retryable := code >= http.StatusInternalServerError || code == errors.ClientErrorCode
+	if !retryable || last {
+		s.LoggingClient.Error(fmt.Sprintf("failed to add discovered device %s: %s", r.request.Device.Name, r.decision.Reason))
+		return false
+	}
+	return true
+}
+
+// chunkRegistrations splits the registrations into chunks of at most size registrations,
+// a single chunk if size isn't positive.
+func chunkRegistrations(registrations []registration, size int) [][]registration {
+	if size <= 0 || size >= len(registrations) {
+		return [][]registration{registrations}
+	}
+	var chunks [][]registration
+	for start := 0; start < len(registrations); start += size {
+		end := start + size
+		if end > len(registrations) {
+			end = len(registrations)
+		}
+		chunks = append(chunks, registrations[start:end])
+	}
+	return chunks
+}
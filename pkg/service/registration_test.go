@@ -0,0 +1,216 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/clients/interfaces"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos"
+	commonDTO "github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/requests"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/clients"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
+	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
+)
+
+// deviceAddStub records the chunks of devices added and answers them with add, which is
+// called with the attempt number of the chunk
+type deviceAddStub struct {
+	interfaces.DeviceClient
+	add    func(attempt int, reqs []requests.AddDeviceRequest) ([]commonDTO.BaseWithIdResponse, errors.EdgeX)
+	chunks [][]string
+}
+
+func (d *deviceAddStub) Add(_ context.Context, reqs []requests.AddDeviceRequest) ([]commonDTO.BaseWithIdResponse, errors.EdgeX) {
+	names := make([]string, len(reqs))
+	for i, req := range reqs {
+		names[i] = req.Device.Name
+	}
+	d.chunks = append(d.chunks, names)
+	attempt := 0
+	for _, chunk := range d.chunks {
+		for _, name := range chunk {
+			if name == names[0] {
+				attempt++
+			}
+		}
+	}
+	return d.add(attempt, reqs)
+}
+
+// respond answers each request with the status code returned by code for its device
+func respond(reqs []requests.AddDeviceRequest, code func(name string) int) []commonDTO.BaseWithIdResponse {
+	res := make([]commonDTO.BaseWithIdResponse, len(reqs))
+	for i, req := range reqs {
+		status := code(req.Device.Name)
+		res[i] = commonDTO.NewBaseWithIdResponse(req.RequestId, http.StatusText(status), status, req.Device.Name+"-id")
+	}
+	return res
+}
+
+func TestRegisterDevices(t *testing.T) {
+	created := func(string) int { return http.StatusCreated }
+	failedOnce := func(name string) func(int, []requests.AddDeviceRequest) ([]commonDTO.BaseWithIdResponse, errors.EdgeX) {
+		return func(attempt int, reqs []requests.AddDeviceRequest) ([]commonDTO.BaseWithIdResponse, errors.EdgeX) {
+			return respond(reqs, func(device string) int {
+				if device == name && attempt == 1 {
+					return http.StatusServiceUnavailable
+				}
+				return http.StatusCreated
+			}), nil
+		}
+	}
+
+	tests := []struct {
+		name             string
+		devices          int
+		maxResultCount   int
+		addRetries       int
+		add              func(attempt int, reqs []requests.AddDeviceRequest) ([]commonDTO.BaseWithIdResponse, errors.EdgeX)
+		expectedChunks   [][]string
+		expectedDecision []string
+		expectedAttempts []int
+	}{
+		{"chunked by MaxResultCount", 5, 2, 0,
+			func(_ int, reqs []requests.AddDeviceRequest) ([]commonDTO.BaseWithIdResponse, errors.EdgeX) {
+				return respond(reqs, created), nil
+			},
+			[][]string{{"d0", "d1"}, {"d2", "d3"}, {"d4"}},
+			[]string{dtos.DiscoveryDecisionAccepted, dtos.DiscoveryDecisionAccepted, dtos.DiscoveryDecisionAccepted, dtos.DiscoveryDecisionAccepted, dtos.DiscoveryDecisionAccepted},
+			[]int{1, 1, 1, 1, 1}},
+		{"all at once", 3, 0, 0,
+			func(_ int, reqs []requests.AddDeviceRequest) ([]commonDTO.BaseWithIdResponse, errors.EdgeX) {
+				return respond(reqs, created), nil
+			},
+			[][]string{{"d0", "d1", "d2"}},
+			[]string{dtos.DiscoveryDecisionAccepted, dtos.DiscoveryDecisionAccepted, dtos.DiscoveryDecisionAccepted},
+			[]int{1, 1, 1}},
+		{"responses matched by request id", 3, 0, 1,
+			func(_ int, reqs []requests.AddDeviceRequest) ([]commonDTO.BaseWithIdResponse, errors.EdgeX) {
+				res := respond(reqs, func(name string) int {
+					if name == "d1" {
+						return http.StatusBadRequest
+					}
+					return http.StatusCreated
+				})
+				res[0], res[2] = res[2], res[0]
+				return res, nil
+			},
+			[][]string{{"d0", "d1", "d2"}},
+			[]string{dtos.DiscoveryDecisionAccepted, dtos.DiscoveryDecisionFailed, dtos.DiscoveryDecisionAccepted},
+			[]int{1, 1, 1}},
+		{"responses matched by order", 2, 0, 0,
+			func(_ int, reqs []requests.AddDeviceRequest) ([]commonDTO.BaseWithIdResponse, errors.EdgeX) {
+				return []commonDTO.BaseWithIdResponse{
+					commonDTO.NewBaseWithIdResponse("", "", http.StatusCreated, "d0-id"),
+					commonDTO.NewBaseWithIdResponse("", "", http.StatusBadRequest, ""),
+				}, nil
+			},
+			[][]string{{"d0", "d1"}},
+			[]string{dtos.DiscoveryDecisionAccepted, dtos.DiscoveryDecisionFailed},
+			[]int{1, 1}},
+		{"missing response", 2, 0, 0,
+			func(_ int, reqs []requests.AddDeviceRequest) ([]commonDTO.BaseWithIdResponse, errors.EdgeX) {
+				return respond(reqs[:1], created), nil
+			},
+			[][]string{{"d0", "d1"}},
+			[]string{dtos.DiscoveryDecisionAccepted, dtos.DiscoveryDecisionFailed},
+			[]int{1, 1}},
+		{"server error retried", 4, 2, 1, failedOnce("d3"),
+			[][]string{{"d0", "d1"}, {"d2", "d3"}, {"d3"}},
+			[]string{dtos.DiscoveryDecisionAccepted, dtos.DiscoveryDecisionAccepted, dtos.DiscoveryDecisionAccepted, dtos.DiscoveryDecisionAccepted},
+			[]int{1, 1, 1, 2}},
+		{"retries exhausted", 2, 0, 2,
+			func(_ int, reqs []requests.AddDeviceRequest) ([]commonDTO.BaseWithIdResponse, errors.EdgeX) {
+				return respond(reqs, func(name string) int {
+					if name == "d1" {
+						return http.StatusInternalServerError
+					}
+					return http.StatusCreated
+				}), nil
+			},
+			[][]string{{"d0", "d1"}, {"d1"}, {"d1"}},
+			[]string{dtos.DiscoveryDecisionAccepted, dtos.DiscoveryDecisionFailed},
+			[]int{1, 3}},
+		{"conflict on a retry after a lost response", 3, 0, 1,
+			func(attempt int, reqs []requests.AddDeviceRequest) ([]commonDTO.BaseWithIdResponse, errors.EdgeX) {
+				if attempt == 1 {
+					return nil, errors.NewCommonEdgeX(errors.KindClientError, "connection reset", nil)
+				}
+				return respond(reqs, func(name string) int {
+					if name == "d2" {
+						return http.StatusCreated
+					}
+					return http.StatusConflict
+				}), nil
+			},
+			[][]string{{"d0", "d1", "d2"}, {"d0", "d1", "d2"}},
+			[]string{dtos.DiscoveryDecisionAccepted, dtos.DiscoveryDecisionAccepted, dtos.DiscoveryDecisionAccepted},
+			[]int{2, 2, 2}},
+		{"conflict on the first attempt", 2, 0, 1,
+			func(_ int, reqs []requests.AddDeviceRequest) ([]commonDTO.BaseWithIdResponse, errors.EdgeX) {
+				return respond(reqs, func(name string) int {
+					if name == "d0" {
+						return http.StatusConflict
+					}
+					return http.StatusCreated
+				}), nil
+			},
+			[][]string{{"d0", "d1"}},
+			[]string{dtos.DiscoveryDecisionFailed, dtos.DiscoveryDecisionAccepted},
+			[]int{1, 1}},
+		{"request error not retried", 2, 0, 1,
+			func(_ int, reqs []requests.AddDeviceRequest) ([]commonDTO.BaseWithIdResponse, errors.EdgeX) {
+				return nil, errors.NewCommonEdgeX(errors.KindContractInvalid, "invalid request", nil)
+			},
+			[][]string{{"d0", "d1"}},
+			[]string{dtos.DiscoveryDecisionFailed, dtos.DiscoveryDecisionFailed},
+			[]int{1, 1}},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			stub := &deviceAddStub{add: testCase.add}
+			s := &DeviceService{
+				LoggingClient: logger.NewMockClient(),
+				tedgeClients:  clients.TedgeClients{DeviceClient: stub},
+				config:        &common.ConfigurationStruct{},
+			}
+			s.config.Service.MaxResultCount = testCase.maxResultCount
+			s.config.Service.Timeout = 1
+			s.config.Device.Discovery.AddRetries = testCase.addRetries
+
+			devices := make([]models.Device, testCase.devices)
+			decisions := make([]*dtos.DiscoveredDeviceDecision, testCase.devices)
+			for i := range devices {
+				devices[i] = models.Device{Name: fmt.Sprintf("d%d", i)}
+				decisions[i] = &dtos.DiscoveredDeviceDecision{Name: devices[i].Name, Decision: dtos.DiscoveryDecisionAccepted}
+			}
+			s.registerDevices(context.Background(), devices, decisions)
+
+			assert.Equal(t, testCase.expectedChunks, stub.chunks)
+			require.Len(t, decisions, len(testCase.expectedDecision))
+			for i, decision := range decisions {
+				assert.Equal(t, testCase.expectedDecision[i], decision.Decision, decision.Name)
+				assert.Equal(t, testCase.expectedAttempts[i], decision.Attempts, decision.Name)
+				if decision.Decision == dtos.DiscoveryDecisionAccepted {
+					assert.Empty(t, decision.Reason, decision.Name)
+				} else {
+					assert.NotEmpty(t, decision.Reason, decision.Name)
+				}
+			}
+		})
+	}
+}
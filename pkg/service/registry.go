@@ -0,0 +1,201 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"sort"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/cache"
+)
+
+// DeviceFilter selects the devices returned by Devices, the empty filter selects all devices.
+type DeviceFilter struct {
+	// Labels the devices must all have
+	Labels []string
+	// Protocol the devices must have
+	Protocol string
+	// ProtocolProperties the devices must have with the same values, in the Protocol if
+	// given, in any protocol otherwise
+	ProtocolProperties map[string]string
+}
+
+// GetDeviceByName returns a copy of the cached Device with the given name.
+func (s *DeviceService) GetDeviceByName(name string) (models.Device, bool) {
+	device, ok := cache.Devices().ForName(name)
+	if !ok {
+		return models.Device{}, false
+	}
+	return copyDevice(device), true
+}
+
+// Devices returns copies of the cached Devices selected by the filter, sorted by name.
+func (s *DeviceService) Devices(filter DeviceFilter) []models.Device {
+	var devices []models.Device
+	for _, device := range cache.Devices().All() {
+		if filter.matches(device) {
+			devices = append(devices, copyDevice(device))
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Name < devices[j].Name })
+	return devices
+}
+
+// GetProfileByName returns a copy of the cached DeviceProfile with the given name, flattened
+// with its base and included profiles.
+func (s *DeviceService) GetProfileByName(name string) (models.DeviceProfile, bool) {
+	profile, ok := cache.Profiles().Resolved(name)
+	if !ok {
+		return models.DeviceProfile{}, false
+	}
+	return copyProfile(profile), true
+}
+
+// GetDeviceResource returns a copy of the DeviceResource of the given device's profile.
+func (s *DeviceService) GetDeviceResource(deviceName string, resourceName string) (models.DeviceResource, bool) {
+	device, ok := cache.Devices().ForName(deviceName)
+	if !ok {
+		return models.DeviceResource{}, false
+	}
+	dr, ok := cache.DeviceResourceOf(device, resourceName)
+	if !ok {
+		return models.DeviceResource{}, false
+	}
+	return copyDeviceResource(dr), true
+}
+
+func (f DeviceFilter) matches(device models.Device) bool {
+	for _, label := range f.Labels {
+		if !containsString(device.Labels, label) {
+			return false
+		}
+	}
+
+	if f.Protocol != "" {
+		properties, ok := device.Protocols[f.Protocol]
+		return ok && hasProperties(properties, f.ProtocolProperties)
+	}
+	if len(f.ProtocolProperties) == 0 {
+		return true
+	}
+	for _, properties := range device.Protocols {
+		if hasProperties(properties, f.ProtocolProperties) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasProperties(properties models.ProtocolProperties, expected map[string]string) bool {
+	for k, v := range expected {
+		if value, ok := properties[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// copyDevice deep copies the device so that the caller can't modify the cache, except the
+// Location which is opaque to the device service
+func copyDevice(device models.Device) models.Device {
+	if device.Protocols != nil {
+		protocols := make(map[string]models.ProtocolProperties, len(device.Protocols))
+		for name, properties := range device.Protocols {
+			protocols[name] = models.ProtocolProperties(copyStringMap(properties))
+		}
+		device.Protocols = protocols
+	}
+	device.Labels = copyStrings(device.Labels)
+	if device.AutoEvents != nil {
+		device.AutoEvents = append([]models.AutoEvent{}, device.AutoEvents...)
+	}
+	return device
+}
+
+func copyProfile(profile models.DeviceProfile) models.DeviceProfile {
+	profile.Labels = copyStrings(profile.Labels)
+	if profile.DeviceResources != nil {
+		resources := make([]models.DeviceResource, len(profile.DeviceResources))
+		for i, dr := range profile.DeviceResources {
+			resources[i] = copyDeviceResource(dr)
+		}
+		profile.DeviceResources = resources
+	}
+	if profile.DeviceCommands != nil {
+		commands := make([]models.ProfileResource, len(profile.DeviceCommands))
+		for i, pr := range profile.DeviceCommands {
+			pr.Get = copyResourceOperations(pr.Get)
+			pr.Set = copyResourceOperations(pr.Set)
+			commands[i] = pr
+		}
+		profile.DeviceCommands = commands
+	}
+	if profile.CoreCommands != nil {
+		profile.CoreCommands = append([]models.Command{}, profile.CoreCommands...)
+	}
+	return profile
+}
+
+func copyProvisionWatcher(pw models.ProvisionWatcher) models.ProvisionWatcher {
+	pw.Labels = copyStrings(pw.Labels)
+	pw.Identifiers = copyStringMap(pw.Identifiers)
+	if pw.BlockingIdentifiers != nil {
+		blocking := make(map[string][]string, len(pw.BlockingIdentifiers))
+		for k, v := range pw.BlockingIdentifiers {
+			blocking[k] = copyStrings(v)
+		}
+		pw.BlockingIdentifiers = blocking
+	}
+	if pw.AutoEvents != nil {
+		pw.AutoEvents = append([]models.AutoEvent{}, pw.AutoEvents...)
+	}
+	return pw
+}
+
+func copyDeviceResource(dr models.DeviceResource) models.DeviceResource {
+	dr.Attributes = copyStringMap(dr.Attributes)
+	return dr
+}
+
+func copyResourceOperations(ros []models.ResourceOperation) []models.ResourceOperation {
+	if ros == nil {
+		return nil
+	}
+	copied := make([]models.ResourceOperation, len(ros))
+	for i, ro := range ros {
+		ro.Mappings = copyStringMap(ro.Mappings)
+		copied[i] = ro
+	}
+	return copied
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(m))
+	for k, v := range m {
+		copied[k] = v
+	}
+	return copied
+}
+
+func copyStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append([]string{}, values...)
+}
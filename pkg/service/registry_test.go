@@ -0,0 +1,73 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+)
+
+func TestDeviceFilter_Matches(t *testing.T) {
+	device := models.Device{
+		Name:   "filter-device",
+		Labels: []string{"floor-1", "sensor"},
+		Protocols: map[string]models.ProtocolProperties{
+			"modbus-tcp": {"Address": "10.0.0.1", "Port": "502"},
+			"other":      {"Address": "10.0.0.2"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		filter   DeviceFilter
+		expected bool
+	}{
+		{"empty filter", DeviceFilter{}, true},
+		{"one label", DeviceFilter{Labels: []string{"sensor"}}, true},
+		{"all labels", DeviceFilter{Labels: []string{"sensor", "floor-1"}}, true},
+		{"missing label", DeviceFilter{Labels: []string{"sensor", "floor-2"}}, false},
+		{"protocol", DeviceFilter{Protocol: "modbus-tcp"}, true},
+		{"missing protocol", DeviceFilter{Protocol: "bacnet"}, false},
+		{"properties of the protocol", DeviceFilter{Protocol: "modbus-tcp", ProtocolProperties: map[string]string{"Address": "10.0.0.1", "Port": "502"}}, true},
+		{"properties of another protocol", DeviceFilter{Protocol: "modbus-tcp", ProtocolProperties: map[string]string{"Address": "10.0.0.2"}}, false},
+		{"different property value", DeviceFilter{Protocol: "modbus-tcp", ProtocolProperties: map[string]string{"Port": "503"}}, false},
+		{"properties of any protocol", DeviceFilter{ProtocolProperties: map[string]string{"Address": "10.0.0.2"}}, true},
+		{"properties split across protocols", DeviceFilter{ProtocolProperties: map[string]string{"Address": "10.0.0.2", "Port": "502"}}, false},
+		{"missing property", DeviceFilter{ProtocolProperties: map[string]string{"UnitID": "1"}}, false},
+		{"labels and protocol", DeviceFilter{Labels: []string{"sensor"}, Protocol: "other"}, true},
+		{"labels and properties", DeviceFilter{Labels: []string{"floor-1"}, ProtocolProperties: map[string]string{"Port": "502"}}, true},
+		{"missing label with matching properties", DeviceFilter{Labels: []string{"actuator"}, ProtocolProperties: map[string]string{"Port": "502"}}, false},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, testCase.filter.matches(device))
+		})
+	}
+
+	assert.False(t, DeviceFilter{Labels: []string{"sensor"}}.matches(models.Device{}), "a device without labels")
+	assert.True(t, DeviceFilter{}.matches(models.Device{}))
+}
+
+func TestCopyDevice(t *testing.T) {
+	device := models.Device{
+		Labels:     []string{"sensor"},
+		Protocols:  map[string]models.ProtocolProperties{"modbus-tcp": {"Address": "10.0.0.1"}},
+		AutoEvents: []models.AutoEvent{{Resource: "temperature"}},
+	}
+
+	copied := copyDevice(device)
+	copied.Labels[0] = "actuator"
+	copied.Protocols["modbus-tcp"]["Address"] = "10.0.0.2"
+	copied.AutoEvents[0].Resource = "humidity"
+
+	assert.Equal(t, "sensor", device.Labels[0])
+	assert.Equal(t, "10.0.0.1", device.Protocols["modbus-tcp"]["Address"])
+	assert.Equal(t, "temperature", device.AutoEvents[0].Resource)
+}
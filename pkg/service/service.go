@@ -17,6 +17,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sync"
 
 	bootstrapContainer "github.com/edgexfoundry/go-mod-bootstrap/v2/bootstrap/container"
 	"github.com/edgexfoundry/go-mod-bootstrap/v2/config"
@@ -28,11 +29,13 @@ import (
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/dtos/requests"
 	eErr "github.com/tuya/tuya-edge-driver-sdk-go/contracts/errors"
 	"github.com/tuya/tuya-edge-driver-sdk-go/contracts/models"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/autodiscovery"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/autoevent"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/clients"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/common"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/container"
 	"github.com/tuya/tuya-edge-driver-sdk-go/internal/controller"
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/eventbus"
 	"github.com/tuya/tuya-edge-driver-sdk-go/logger"
 	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
 )
@@ -53,6 +56,9 @@ type DeviceService struct {
 	asyncCh       chan *dsModels.AsyncValues
 	deviceCh      chan []dsModels.DiscoveredDevice
 	initialized   bool
+
+	// mutex guards the deviceService and its configured labels updated by metadata
+	mutex sync.RWMutex
 }
 
 func (s *DeviceService) Initialize(serviceName, serviceVersion string, proto interface{}) {
@@ -77,6 +83,8 @@ func (s *DeviceService) Initialize(serviceName, serviceVersion string, proto int
 
 	if discovery, ok := proto.(dsModels.ProtocolDiscovery); ok {
 		s.discovery = discovery
+	} else if discovery, ok := proto.(dsModels.ContextDiscovery); ok {
+		s.discovery = autodiscovery.ContextDiscovery{ContextDiscovery: discovery}
 	} else {
 		s.discovery = nil
 	}
@@ -130,6 +138,13 @@ func (s *DeviceService) Stop(force bool) {
 	autoevent.GetManager().StopAutoEvents()
 }
 
+// serviceInstance returns the DeviceService instance of the running service.
+func (s *DeviceService) serviceInstance() models.DeviceService {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.deviceService
+}
+
 func (s *DeviceService) updateService() eErr.EdgeX {
 	// 获取驱动实例ID
 	id := s.config.Service.ID
@@ -148,6 +163,9 @@ func (s *DeviceService) updateService() eErr.EdgeX {
 		svc := dtos.ToDeviceServiceModel(dsr.Service)
 		s.LoggingClient.Info(fmt.Sprintf("DeviceService instance with id: %s exists, updating it", dsr.Service.Id))
 
+		s.mutex.RLock()
+		labels := s.config.Service.Labels
+		s.mutex.RUnlock()
 		unlock := models.Unlocked
 		baInfo := config.ClientInfo{
 			Host:     s.config.Service.Host,
@@ -158,7 +176,7 @@ func (s *DeviceService) updateService() eErr.EdgeX {
 		update := dtos.UpdateDeviceService{
 			Id:          &svc.Id,
 			Name:        &svc.Name,
-			Labels:      s.config.Service.Labels,
+			Labels:      labels,
 			BaseAddress: &ba,
 			AdminState:  &unlock,
 		}
@@ -169,14 +187,22 @@ func (s *DeviceService) updateService() eErr.EdgeX {
 		reqs = append(reqs, req)
 		resp, err := s.tedgeClients.DeviceServiceClient.Update(ctx, reqs)
 		if err != nil {
-			s.LoggingClient.Error(fmt.Sprintf("Failed to update  DeviceService %s: %v, %v", s.deviceService.Id, err, resp))
+			s.LoggingClient.Error(fmt.Sprintf("Failed to update  DeviceService %s: %v, %v", svc.Id, err, resp))
 			return err
 		}
 		// update local config
-		svc.Labels = s.config.Service.Labels
+		svc.Labels = labels
 		svc.BaseAddress = ba
 		svc.AdminState = models.Unlocked
+		var before *models.DeviceService
+		s.mutex.Lock()
+		if s.deviceService.Id != "" {
+			previous := s.deviceService
+			before = &previous
+		}
 		s.deviceService = svc
+		s.mutex.Unlock()
+		eventbus.PublishDeviceServiceChange(before, &svc)
 	}
 	return nil
 }
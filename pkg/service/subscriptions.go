@@ -0,0 +1,84 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/eventbus"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+// The handlers are called synchronously, after the cache is updated, in the goroutine
+// handling the change, so they must return quickly. Each handler receives its own copies
+// of the before and after snapshots. The returned function cancels the subscription.
+
+// SubscribeDeviceChanges registers the handler of the added, updated and removed Devices.
+func (s *DeviceService) SubscribeDeviceChanges(handler func(change dsModels.DeviceChange)) (unsubscribe func()) {
+	return eventbus.Subscribe(eventbus.DeviceTopic, func(event interface{}) {
+		change := event.(dsModels.DeviceChange)
+		if change.Before != nil {
+			before := copyDevice(*change.Before)
+			change.Before = &before
+		}
+		if change.After != nil {
+			after := copyDevice(*change.After)
+			change.After = &after
+		}
+		handler(change)
+	})
+}
+
+// SubscribeProfileChanges registers the handler of the added, updated and removed
+// DeviceProfiles, e.g. to rebuild the register maps of the devices using an updated profile.
+func (s *DeviceService) SubscribeProfileChanges(handler func(change dsModels.ProfileChange)) (unsubscribe func()) {
+	return eventbus.Subscribe(eventbus.ProfileTopic, func(event interface{}) {
+		change := event.(dsModels.ProfileChange)
+		if change.Before != nil {
+			before := copyProfile(*change.Before)
+			change.Before = &before
+		}
+		if change.After != nil {
+			after := copyProfile(*change.After)
+			change.After = &after
+		}
+		handler(change)
+	})
+}
+
+// SubscribeProvisionWatcherChanges registers the handler of the added, updated and removed
+// ProvisionWatchers.
+func (s *DeviceService) SubscribeProvisionWatcherChanges(handler func(change dsModels.ProvisionWatcherChange)) (unsubscribe func()) {
+	return eventbus.Subscribe(eventbus.ProvisionWatcherTopic, func(event interface{}) {
+		change := event.(dsModels.ProvisionWatcherChange)
+		if change.Before != nil {
+			before := copyProvisionWatcher(*change.Before)
+			change.Before = &before
+		}
+		if change.After != nil {
+			after := copyProvisionWatcher(*change.After)
+			change.After = &after
+		}
+		handler(change)
+	})
+}
+
+// SubscribeDeviceServiceChanges registers the handler of the updates of the DeviceService.
+func (s *DeviceService) SubscribeDeviceServiceChanges(handler func(change dsModels.DeviceServiceChange)) (unsubscribe func()) {
+	return eventbus.Subscribe(eventbus.DeviceServiceTopic, func(event interface{}) {
+		change := event.(dsModels.DeviceServiceChange)
+		if change.Before != nil {
+			before := *change.Before
+			before.Labels = copyStrings(before.Labels)
+			change.Before = &before
+		}
+		if change.After != nil {
+			after := *change.After
+			after.Labels = copyStrings(after.Labels)
+			change.After = &after
+		}
+		handler(change)
+	})
+}
@@ -0,0 +1,27 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2021 IOTech Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"github.com/tuya/tuya-edge-driver-sdk-go/internal/transformer"
+	dsModels "github.com/tuya/tuya-edge-driver-sdk-go/pkg/models"
+)
+
+// RegisterReadTransform registers a custom read transform, which DeviceResources reference
+// by name in their ds-transform attribute. It is applied to the read values before mask, shift,
+// base, scale, offset, assertion and mapping.
+func (s *DeviceService) RegisterReadTransform(name string, fn dsModels.TransformFunc) error {
+	return transformer.RegisterReadTransform(name, fn)
+}
+
+// RegisterWriteTransform registers a custom write transform, which DeviceResources reference
+// by name in their ds-transform attribute. It is applied to the written values after mapping,
+// offset, scale and base are reverted, the transforms being applied in the reverse order of
+// the attribute.
+func (s *DeviceService) RegisterWriteTransform(name string, fn dsModels.TransformFunc) error {
+	return transformer.RegisterWriteTransform(name, fn)
+}